@@ -1,13 +1,19 @@
 package api
 
 import (
+	"context"
+	"log"
 	"os"
 
 	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/dashboard"
 	"github.com/MrBolas/SupervisorAPI/encryption"
 	"github.com/MrBolas/SupervisorAPI/handlers"
+	"github.com/MrBolas/SupervisorAPI/idempotency"
 	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
 	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/MrBolas/SupervisorAPI/search"
 	"github.com/go-redis/redis/v8"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -20,16 +26,20 @@ type Api struct {
 
 const ENV_PUBLIC_KEY_URL = "AUTH0_PUBLIC_KEY_URL"
 const ENV_CRYPTO_KEY = "CRYPTO_KEY"
+const ENV_BLIND_INDEX_KEY = "BLIND_INDEX_KEY"
 
 func New(db *gorm.DB, redis *redis.Client) *Api {
 
-	err := db.AutoMigrate(models.Task{})
+	err := db.AutoMigrate(models.Task{}, models.TaskRevision{}, models.User{}, models.CustomFieldSchema{}, models.TeamLimits{}, models.TaskSearchToken{}, models.View{})
 	if err != nil {
 		panic(err)
 	}
 
 	e := echo.New()
 
+	// errors not handled by the handlers are sent as problems
+	e.HTTPErrorHandler = problem.HTTPErrorHandler(handlers.ERROR_MAPPINGS...)
+
 	// encryption
 	cryptKey := os.Getenv(ENV_CRYPTO_KEY)
 	if cryptKey == "" {
@@ -37,11 +47,69 @@ func New(db *gorm.DB, redis *redis.Client) *Api {
 	}
 	ce := encryption.NewCryptoEngine(cryptKey)
 
+	// search
+	indexKey := os.Getenv(ENV_BLIND_INDEX_KEY)
+	if indexKey == "" {
+		panic("missing env var: " + ENV_BLIND_INDEX_KEY)
+	}
+	if indexKey == cryptKey {
+		panic(ENV_BLIND_INDEX_KEY + " must be different from " + ENV_CRYPTO_KEY)
+	}
+	index := encryption.NewBlindIndex(indexKey)
+
 	// repositories
 	tasksRepo := repositories.NewTasksRepository(db)
+	usersRepo := repositories.NewUsersRepository(db)
+	customFieldsRepo := repositories.NewCustomFieldsRepository(db)
+	limitsRepo := repositories.NewLimitsRepository(db)
+	viewsRepo := repositories.NewViewsRepository(db)
+	dashboardRepo := repositories.NewDashboardRepository(db)
+
+	// views no longer save searches, their words were stored in plain text
+	purged, err := viewsRepo.PurgeViewSearches()
+	if err != nil {
+		panic(err)
+	}
+	if purged > 0 {
+		log.Printf("removed the search of %d saved views", purged)
+	}
+
+	// tasks stored before users existed belong to the user of their nickname,
+	// when it is unique, admins review the others
+	linked, err := usersRepo.BackfillTaskWorkers()
+	if err != nil {
+		panic(err)
+	}
+	if linked > 0 {
+		log.Printf("linked %d legacy tasks to the users of their nicknames", linked)
+	}
+
+	// index tasks created before the search index existed
+	go func() {
+		indexed, err := search.Backfill(tasksRepo, ce, index, search.BACKFILL_BATCH_SIZE)
+		if err != nil {
+			log.Println("search backfill failed: " + err.Error())
+			return
+		}
+		log.Printf("search backfill done, %d tasks indexed", indexed)
+	}()
 
 	// handlers
-	tasksHandler := handlers.NewTasksHandler(tasksRepo, ce, redis)
+	tasksHandler := handlers.NewTasksHandler(tasksRepo, usersRepo, viewsRepo, customFieldsRepo, limitsRepo, ce, index, redis)
+	usersHandler := handlers.NewUsersHandler(usersRepo)
+	customFieldsHandler := handlers.NewCustomFieldsHandler(customFieldsRepo)
+	limitsHandler := handlers.NewLimitsHandler(limitsRepo)
+	viewsHandler := handlers.NewViewsHandler(viewsRepo, index)
+	calendarHandler := handlers.NewCalendarHandler(usersRepo, tasksRepo, ce)
+
+	// dashboards are cached until a task changes
+	dashboardCache := dashboard.NewCache(redis, dashboard.DEFAULT_TTL)
+	go dashboardCache.Listen(context.Background(), handlers.TASK_EVENTS_CHANNEL)
+	dashboardHandler := handlers.NewDashboardHandler(dashboardRepo, dashboardCache)
+
+	// idempotency keys are kept per user
+	idempotencyStore := idempotency.NewStore(redis, idempotency.DEFAULT_TTL)
+	idempotent := idempotencyStore.Middleware(auth.GetUserId)
 
 	// auth
 	publicKeyUrl := os.Getenv(ENV_PUBLIC_KEY_URL)
@@ -54,16 +122,52 @@ func New(db *gorm.DB, redis *redis.Client) *Api {
 		panic(err)
 	}
 
+	// calendar apps can't send a JWT, the token in the path is the credential
+	e.GET(handlers.CALENDAR_FEED_PATH+":token", calendarHandler.GetCalendarFeed)
+
 	g := e.Group("/v1")
 
 	// middleware
 	g.Use(middleware.JWTWithConfig(jwtConfig))
+	g.Use(usersHandler.SyncUser)
 
-	g.POST("/tasks", tasksHandler.CreateTask)
+	g.POST("/tasks", tasksHandler.CreateTask, idempotent)
 	g.GET("/tasks", tasksHandler.GetTaskList)
+	g.POST("/tasks/bulk", tasksHandler.BulkTasks, idempotent)
+	g.GET("/tasks/stats", tasksHandler.GetTaskStats)
+	g.GET("/tasks/export", tasksHandler.GetTaskExport)
+	g.POST("/tasks/import", tasksHandler.ImportTasks)
+	g.GET("/tasks/import/:id", tasksHandler.GetImportJob)
 	g.GET("/tasks/:id", tasksHandler.GetTaskById)
 	g.PUT("/tasks/:id", tasksHandler.UpdateTask)
+	g.PATCH("/tasks/:id", tasksHandler.PatchTask)
 	g.DELETE("/tasks/:id", tasksHandler.DeleteTask)
+	g.GET("/tasks/:id/revisions", tasksHandler.GetTaskRevisions)
+	g.GET("/tasks/:id/revisions/diff", tasksHandler.GetTaskRevisionsDiff)
+	g.POST("/tasks/:id/revisions/:revision/revert", tasksHandler.RevertTask)
+
+	g.GET("/users", usersHandler.ListUsers)
+	g.GET("/users/:id", usersHandler.GetUserById)
+	g.GET("/me", usersHandler.GetMe)
+	g.PATCH("/me/preferences", usersHandler.UpdateMyPreferences)
+	g.GET("/task-claims", usersHandler.ListTaskClaims)
+	g.POST("/task-claims", usersHandler.ClaimTasks)
+	g.POST("/me/calendar", calendarHandler.CreateCalendarToken)
+	g.DELETE("/me/calendar", calendarHandler.DeleteCalendarToken)
+
+	g.GET("/custom-fields/:team", customFieldsHandler.GetCustomFieldSchema)
+	g.PUT("/custom-fields/:team", customFieldsHandler.SaveCustomFieldSchema)
+
+	g.GET("/limits/:team", limitsHandler.GetTeamLimits)
+	g.PUT("/limits/:team", limitsHandler.SaveTeamLimits)
+
+	g.GET("/dashboard", dashboardHandler.GetDashboard)
+
+	g.GET("/views", viewsHandler.ListViews)
+	g.POST("/views", viewsHandler.CreateView, idempotent)
+	g.GET("/views/:id", viewsHandler.GetViewById)
+	g.PUT("/views/:id", viewsHandler.UpdateView)
+	g.DELETE("/views/:id", viewsHandler.DeleteView)
 
 	return &Api{
 		echo: e,
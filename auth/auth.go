@@ -102,6 +102,34 @@ func IsManager(c echo.Context) bool {
 	return HasRole(c, "manager")
 }
 
+func IsAdmin(c echo.Context) bool {
+	return HasRole(c, "admin")
+}
+
+func GetUserRole(c echo.Context) string {
+
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	role, _ := claims["http://supervisorapi/role"].(string)
+	return role
+}
+
+func GetUserTeam(c echo.Context) string {
+
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	team, _ := claims["http://supervisorapi/team"].(string)
+	return team
+}
+
+func GetUserTimeZone(c echo.Context) string {
+
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	timeZone, _ := claims["zoneinfo"].(string)
+	return timeZone
+}
+
 func GetUserNickname(c echo.Context) string {
 
 	user := c.Get("user").(*jwt.Token)
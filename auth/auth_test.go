@@ -105,3 +105,26 @@ func TestGetUserId(t *testing.T) {
 	// Assert result
 	assert.Equal(t, fetchedId, "mocked_id")
 }
+
+func TestGetUserRole(t *testing.T) {
+
+	// define echo context
+	e := echo.New()
+	c := e.AcquireContext()
+
+	// Add role to claim
+	claims := make(jwt.MapClaims)
+	claims["http://supervisorapi/role"] = "technician"
+
+	// define jwt token
+	tk := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+
+	// add jwt to context
+	c.Set("user", tk)
+
+	// call Get User Role
+	fetchedRole := GetUserRole(c)
+
+	// Assert result
+	assert.Equal(t, fetchedRole, "technician")
+}
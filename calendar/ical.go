@@ -0,0 +1,112 @@
+package calendar
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const CONTENT_TYPE = "text/calendar; charset=utf-8"
+
+const PRODUCT_ID = "-//SupervisorAPI//Tasks//EN"
+
+// MAX_LINE_OCTETS is the longest content line, longer lines are folded.
+const MAX_LINE_OCTETS = 75
+
+const dateTimeFormat = "20060102T150405Z"
+
+// Event is a VEVENT, its times are written in UTC.
+type Event struct {
+	UID         string
+	Stamp       time.Time
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Sequence    int
+}
+
+// Write writes an iCalendar (RFC 5545) of the events, published under name.
+func Write(w io.Writer, name string, events []Event) error {
+
+	cw := writer{w: bufio.NewWriter(w)}
+
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", PRODUCT_ID)
+	cw.line("CALSCALE", "GREGORIAN")
+	cw.line("METHOD", "PUBLISH")
+	cw.line("X-WR-CALNAME", EscapeText(name))
+
+	for _, event := range events {
+		cw.line("BEGIN", "VEVENT")
+		cw.line("UID", event.UID)
+		cw.line("DTSTAMP", event.Stamp.UTC().Format(dateTimeFormat))
+		cw.line("DTSTART", event.Start.UTC().Format(dateTimeFormat))
+		cw.line("DTEND", event.End.UTC().Format(dateTimeFormat))
+		cw.line("SEQUENCE", strconv.Itoa(event.Sequence))
+		cw.line("SUMMARY", EscapeText(event.Summary))
+		if event.Description != "" {
+			cw.line("DESCRIPTION", EscapeText(event.Description))
+		}
+		cw.line("END", "VEVENT")
+	}
+
+	cw.line("END", "VCALENDAR")
+
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.w.Flush()
+}
+
+// EscapeText escapes a TEXT value, new lines become \n.
+func EscapeText(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(value)
+}
+
+// Fold splits a content line into lines of MAX_LINE_OCTETS, the following
+// lines start with a space. Characters are never split.
+func Fold(line string) string {
+
+	var b strings.Builder
+	limit := MAX_LINE_OCTETS
+
+	for len(line) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(line[i]) {
+			i--
+		}
+		b.WriteString(line[:i])
+		b.WriteString("\r\n ")
+		line = line[i:]
+		// the space counts
+		limit = MAX_LINE_OCTETS - 1
+	}
+	b.WriteString(line)
+
+	return b.String()
+}
+
+// writer writes content lines, keeping the first error.
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (cw *writer) line(name string, value string) {
+	if cw.err != nil {
+		return
+	}
+	_, cw.err = cw.w.WriteString(Fold(name+":"+value) + "\r\n")
+}
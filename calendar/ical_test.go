@@ -0,0 +1,79 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+
+	start := time.Date(2022, time.May, 23, 15, 33, 0, 0, time.FixedZone("WEST", 3600))
+	events := []Event{{
+		UID:         "a2d45497-09b4-4da1-a0d0-173d0bd12f13@supervisorapi",
+		Stamp:       time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC),
+		Start:       start,
+		End:         start.Add(90 * time.Minute),
+		Summary:     "Pump, valves; filter",
+		Description: "Fixed the pump\nChecked the valves",
+		Sequence:    2,
+	}}
+
+	var b strings.Builder
+	assert.Nil(t, Write(&b, "Tasks of joseph", events))
+
+	assert.Equal(t, strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//SupervisorAPI//Tasks//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:Tasks of joseph",
+		"BEGIN:VEVENT",
+		"UID:a2d45497-09b4-4da1-a0d0-173d0bd12f13@supervisorapi",
+		"DTSTAMP:20220601T000000Z",
+		"DTSTART:20220523T143300Z",
+		"DTEND:20220523T160300Z",
+		"SEQUENCE:2",
+		`SUMMARY:Pump\, valves\; filter`,
+		`DESCRIPTION:Fixed the pump\nChecked the valves`,
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n"), b.String())
+}
+
+func TestFoldKeepsLinesShortAndCharactersWhole(t *testing.T) {
+
+	line := "DESCRIPTION:" + strings.Repeat("é", 100)
+	folded := Fold(line)
+
+	lines := strings.Split(folded, "\r\n")
+	assert.Greater(t, len(lines), 1)
+	for i, l := range lines {
+		assert.LessOrEqual(t, len(l), MAX_LINE_OCTETS)
+		assert.True(t, strings.ToValidUTF8(l, "?") == l)
+		if i > 0 {
+			assert.True(t, strings.HasPrefix(l, " "))
+		}
+	}
+
+	// unfolding gives the line back
+	assert.Equal(t, line, strings.ReplaceAll(folded, "\r\n ", ""))
+
+	assert.Equal(t, "SUMMARY:short", Fold("SUMMARY:short"))
+}
+
+func TestNewToken(t *testing.T) {
+
+	token, hash, err := NewToken()
+	assert.Nil(t, err)
+	assert.Len(t, token, 43)
+	assert.Equal(t, HashToken(token), hash)
+
+	other, _, err := NewToken()
+	assert.Nil(t, err)
+	assert.NotEqual(t, token, other)
+}
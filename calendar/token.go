@@ -0,0 +1,30 @@
+package calendar
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// TOKEN_BYTES is the size of the random part of feed tokens.
+const TOKEN_BYTES = 32
+
+// NewToken makes the token of a feed and its hash. Only the hash is stored,
+// the token is shown once and is the only way to read the feed.
+func NewToken() (string, string, error) {
+
+	b := make([]byte, TOKEN_BYTES)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken is the hash a token is stored and looked up by.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
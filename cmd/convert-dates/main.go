@@ -0,0 +1,74 @@
+// Command convert-dates converts the task dates written before dates were
+// stored in UTC, by servers running in another time zone, to UTC. It reads
+// the MySQL variables of the API and runs once, before the upgraded API
+// writes any date: the conversion is recorded and never runs again.
+//
+//	convert-dates -from Europe/Lisbon
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/joho/godotenv"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// MYSQL_VARIABLES are the variables of the connection, in the order of the
+// DSN.
+var MYSQL_VARIABLES = []string{"MYSQL_USERNAME", "MYSQL_PASSWORD", "MYSQL_HOSTNAME", "MYSQL_PORT", "MYSQL_DATABASE"}
+
+func main() {
+
+	from := flag.String("from", "", "time zone the servers ran in, such as Europe/Lisbon")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s -from time_zone\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *from == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	loc, err := time.LoadLocation(*from)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("Error loading .env file")
+	}
+
+	values := make([]interface{}, 0, len(MYSQL_VARIABLES))
+	for _, name := range MYSQL_VARIABLES {
+		value := os.Getenv(name)
+		if value == "" {
+			log.Fatal("missing env var: " + name)
+		}
+		values = append(values, value)
+	}
+
+	// dates are read as they are stored, without conversion
+	dsn := fmt.Sprintf("%s:%s@(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=UTC", values...)
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	converted, err := repositories.ConvertLegacyDates(db, loc)
+	if err == repositories.ErrLegacyDatesConverted {
+		log.Fatal("the dates were converted already, nothing was changed")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("converted %d rows from %s to UTC", converted, loc)
+}
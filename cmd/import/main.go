@@ -0,0 +1,200 @@
+// Command import sends a CSV file of tasks to the import endpoint of the API
+// and follows its progress when it is imported in the background.
+//
+//	import -token $TOKEN -map summary:Description -map date:Day -dry-run logs.csv
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/importer"
+)
+
+const ENV_TOKEN = "SUPERVISOR_TOKEN"
+
+// POLL_INTERVAL is how often the progress of background imports is read.
+const POLL_INTERVAL = 2 * time.Second
+
+// mappings are the repeated -map flags.
+type mappings []string
+
+func (m *mappings) String() string {
+	return strings.Join(*m, ",")
+}
+
+func (m *mappings) Set(value string) error {
+	*m = append(*m, value)
+	return nil
+}
+
+func main() {
+
+	var maps mappings
+	api := flag.String("url", "http://localhost:8080", "base URL of the API")
+	token := flag.String("token", os.Getenv(ENV_TOKEN), "access token of a manager, defaults to $"+ENV_TOKEN)
+	dryRun := flag.Bool("dry-run", false, "validate the file and report errors without importing")
+	tz := flag.String("tz", "", "time zone of the dates without zone, defaults to the one of the user")
+	resume := flag.String("resume", "", "id of a failed job of the same file, imports only the rows after its report")
+	flag.Var(&maps, "map", "field:header, maps a column to a task field, can be repeated")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] file.csv\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || *token == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// mappings are checked before sending the file
+	if _, err := importer.ParseMapping(maps); err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	query := url.Values{}
+	query.Set("dry_run", fmt.Sprint(*dryRun))
+	for _, m := range maps {
+		query.Add("map", m)
+	}
+	if *tz != "" {
+		query.Set("tz", *tz)
+	}
+	if *resume != "" {
+		query.Set("resume", *resume)
+	}
+
+	client := &client{base: strings.TrimSuffix(*api, "/"), token: *token}
+
+	req, err := http.NewRequest(http.MethodPost, client.base+"/v1/tasks/import?"+query.Encode(), file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/csv")
+
+	var job importer.Job
+	status, err := client.do(req, &job.Report)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// large files are imported by a job, its report is the response
+	if status == http.StatusAccepted {
+		job, err = client.wait()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if job.Status == importer.JOB_FAILED {
+			printReport(job.Report)
+			log.Fatalf("import failed: %s, send the file again with -resume %s", job.Error, job.Id)
+		}
+	}
+
+	printReport(job.Report)
+	if job.Report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+type client struct {
+	base  string
+	token string
+	// location of the job of the last accepted import
+	job string
+}
+
+// do sends the request and decodes the response into v. Errors of the API
+// are returned with their detail.
+func (cl *client) do(req *http.Request, v interface{}) (int, error) {
+
+	req.Header.Set("Authorization", "Bearer "+cl.token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		var p struct {
+			Detail string `json:"detail"`
+		}
+		if json.Unmarshal(body, &p) == nil && p.Detail != "" {
+			return res.StatusCode, fmt.Errorf("%s: %s", res.Status, p.Detail)
+		}
+		return res.StatusCode, fmt.Errorf("%s", res.Status)
+	}
+
+	if res.StatusCode == http.StatusAccepted {
+		cl.job = res.Header.Get("Location")
+		var job importer.Job
+		if err := json.Unmarshal(body, &job); err != nil {
+			return 0, err
+		}
+		log.Printf("importing in the background, job %s", job.Id)
+		return res.StatusCode, nil
+	}
+
+	return res.StatusCode, json.Unmarshal(body, v)
+}
+
+// wait polls the job of the last accepted import until it ends.
+func (cl *client) wait() (importer.Job, error) {
+
+	for {
+		req, err := http.NewRequest(http.MethodGet, cl.base+cl.job, nil)
+		if err != nil {
+			return importer.Job{}, err
+		}
+
+		var job importer.Job
+		if _, err := cl.do(req, &job); err != nil {
+			return importer.Job{}, err
+		}
+
+		if job.Status == importer.JOB_DONE || job.Status == importer.JOB_FAILED {
+			return job, nil
+		}
+
+		log.Printf("%s, %d rows read, %d imported, %d failed", job.Status, job.Report.Rows, job.Report.Imported, job.Report.Failed)
+		time.Sleep(POLL_INTERVAL)
+	}
+}
+
+func printReport(report importer.Report) {
+
+	for _, le := range report.Errors {
+		for _, fe := range le.Errors {
+			fmt.Printf("line %d: %s\n", le.Line, fe.Message)
+		}
+	}
+	if report.Truncated {
+		fmt.Printf("only the first %d invalid lines are listed\n", len(report.Errors))
+	}
+
+	if report.DryRun {
+		fmt.Printf("dry run: %d rows, %d valid, %d invalid\n", report.Rows, report.Valid, report.Failed)
+		return
+	}
+	fmt.Printf("%d rows, %d imported, %d invalid\n", report.Rows, report.Imported, report.Failed)
+}
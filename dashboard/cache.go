@@ -0,0 +1,98 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const DEFAULT_TTL = 30 * time.Second
+const KEY_PREFIX = "dashboard:"
+
+// GENERATION_KEY counts the invalidations, keys of older generations are
+// never read again and expire with their ttl.
+const GENERATION_KEY = KEY_PREFIX + "generation"
+
+// Cache keeps dashboards in Redis for a short time, they are invalidated as
+// soon as a task changes.
+type Cache struct {
+	rclient *redis.Client
+	ttl     time.Duration
+}
+
+func NewCache(rclient *redis.Client, ttl time.Duration) *Cache {
+	return &Cache{
+		rclient: rclient,
+		ttl:     ttl,
+	}
+}
+
+// Key is the key of the dashboard of a scope in the current generation.
+func (c *Cache) Key(ctx context.Context, scope string) (string, error) {
+
+	generation, err := c.rclient.Get(ctx, GENERATION_KEY).Int64()
+	if err == redis.Nil {
+		generation, err = 0, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return KEY_PREFIX + strconv.FormatInt(generation, 10) + ":" + scope, nil
+}
+
+// Get reads the dashboard of the key into v, it tells whether it was cached.
+func (c *Cache) Get(ctx context.Context, key string, v interface{}) (bool, error) {
+
+	value, err := c.rclient.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, json.Unmarshal(value, v)
+}
+
+func (c *Cache) Set(ctx context.Context, key string, v interface{}) error {
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.rclient.Set(ctx, key, value, c.ttl).Err()
+}
+
+// Invalidate drops every cached dashboard.
+func (c *Cache) Invalidate(ctx context.Context) error {
+	return c.rclient.Incr(ctx, GENERATION_KEY).Err()
+}
+
+// Listen invalidates the dashboards on every message of the channel, until
+// the context is done.
+func (c *Cache) Listen(ctx context.Context, channel string) {
+
+	sub := c.rclient.Subscribe(ctx, channel)
+	defer sub.Close()
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-messages:
+			if !ok {
+				return
+			}
+			if err := c.Invalidate(ctx); err != nil {
+				log.Println("dashboard cache not invalidated: " + err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package dashboard
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCache(t *testing.T) (*Cache, *redis.Client, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rclient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	return NewCache(rclient, time.Minute), rclient, mr
+}
+
+func TestCacheGetAndSet(t *testing.T) {
+	cache, _, mr := newTestCache(t)
+	ctx := context.Background()
+
+	key, err := cache.Key(ctx, "hvac")
+	assert.Nil(t, err)
+	assert.Equal(t, "dashboard:0:hvac", key)
+
+	var value map[string]int
+	found, err := cache.Get(ctx, key, &value)
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, cache.Set(ctx, key, map[string]int{"tasks_today": 3}))
+
+	found, err = cache.Get(ctx, key, &value)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, map[string]int{"tasks_today": 3}, value)
+
+	// dashboards are only cached briefly
+	mr.FastForward(time.Minute)
+	found, err = cache.Get(ctx, key, &value)
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestCacheInvalidateChangesTheKeys(t *testing.T) {
+	cache, _, _ := newTestCache(t)
+	ctx := context.Background()
+
+	key, err := cache.Key(ctx, "hvac")
+	assert.Nil(t, err)
+	assert.Nil(t, cache.Set(ctx, key, 1))
+
+	assert.Nil(t, cache.Invalidate(ctx))
+
+	newKey, err := cache.Key(ctx, "hvac")
+	assert.Nil(t, err)
+	assert.NotEqual(t, key, newKey)
+
+	var value int
+	found, err := cache.Get(ctx, newKey, &value)
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestCacheListenInvalidatesOnEvents(t *testing.T) {
+	cache, rclient, _ := newTestCache(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go cache.Listen(ctx, "task_events")
+
+	key, err := cache.Key(ctx, "hvac")
+	assert.Nil(t, err)
+
+	// the subscription may not be ready for the first messages
+	assert.Eventually(t, func() bool {
+		rclient.Publish(ctx, "task_events", `{"event":"task.updated"}`)
+		newKey, err := cache.Key(ctx, "hvac")
+		return err == nil && newKey != key
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,71 @@
+package encryption
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MIN_TOKEN_CHARS is the length of the shortest word that is indexed.
+const MIN_TOKEN_CHARS = 2
+
+// TOKEN_BYTES is the length of the HMAC kept as token.
+const TOKEN_BYTES = 16
+
+// BlindIndex turns words into keyed HMAC tokens, so equal words can be
+// matched in the database without storing them. Its key must not be the
+// encryption key.
+type BlindIndex struct {
+	key []byte
+}
+
+func NewBlindIndex(key string) BlindIndex {
+	return BlindIndex{
+		key: []byte(key),
+	}
+}
+
+// Tokens returns the sorted tokens of the distinct words of text. Words are
+// runs of letters and digits, compared in lower case.
+func (bi *BlindIndex) Tokens(text string) []string {
+
+	seen := make(map[string]bool)
+	tokens := make([]string, 0)
+
+	for _, word := range Words(text) {
+		token := bi.Token(word)
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Strings(tokens)
+
+	return tokens
+}
+
+// Token returns the token of a single word.
+func (bi *BlindIndex) Token(word string) string {
+	mac := hmac.New(sha256.New, bi.key)
+	mac.Write([]byte(strings.ToLower(word)))
+	return hex.EncodeToString(mac.Sum(nil)[:TOKEN_BYTES])
+}
+
+// Words splits text into the words that are indexed.
+func Words(text string) []string {
+
+	words := make([]string, 0)
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(word) >= MIN_TOKEN_CHARS {
+			words = append(words, strings.ToLower(word))
+		}
+	}
+
+	return words
+}
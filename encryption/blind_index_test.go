@@ -0,0 +1,30 @@
+package encryption
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlindIndexTokens(t *testing.T) {
+
+	bi := NewBlindIndex("J8s0pQ2mXv7LcN4rT1wZ6yB3hF9kD5gA")
+
+	tokens := bi.Tokens("Replaced the **Compressor**; compressor was a 2nd-hand unit")
+	assert.Len(t, tokens, 7)
+	assert.Contains(t, tokens, bi.Token("compressor"))
+	assert.Contains(t, tokens, bi.Token("2nd"))
+	assert.NotContains(t, tokens, bi.Token("a"))
+
+	// tokens don't reveal the word and depend on the key
+	assert.Len(t, bi.Token("compressor"), 2*TOKEN_BYTES)
+	assert.NotContains(t, bi.Token("compressor"), "compressor")
+	other := NewBlindIndex("another key")
+	assert.NotEqual(t, bi.Token("compressor"), other.Token("compressor"))
+
+	assert.Empty(t, bi.Tokens("a - b"))
+}
+
+func TestWords(t *testing.T) {
+	assert.Equal(t, []string{"válvula", "ok", "x2"}, Words("Válvula: OK, x2 !"))
+}
@@ -0,0 +1,133 @@
+// Package filter parses the filter expressions of list endpoints.
+//
+// An expression compares fields to values and combines the comparisons:
+//
+//	worker_name eq 'ana' and date ge 2024-01-01 and not (custom.tag in ('hvac', 'electrical'))
+//
+// The operators are eq, ne, gt, ge, lt, le and in, comparisons are combined
+// with and, or, not and parentheses, and bind in that order. Strings are
+// quoted with single quotes, a quote inside a string is written twice. Numbers
+// and dates are written bare. Keywords are case insensitive.
+//
+// Expressions are checked against a Schema, the whitelist of fields that can
+// be filtered, and every value is converted to the type of its field. Errors
+// point at the token that is wrong.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MAX_EXPRESSION_CHARS is the longest expression accepted.
+const MAX_EXPRESSION_CHARS = 1000
+
+// Operators of comparisons.
+const (
+	OP_EQ = "eq"
+	OP_NE = "ne"
+	OP_GT = "gt"
+	OP_GE = "ge"
+	OP_LT = "lt"
+	OP_LE = "le"
+	OP_IN = "in"
+)
+
+var OPERATORS = []string{OP_EQ, OP_NE, OP_GT, OP_GE, OP_LT, OP_LE, OP_IN}
+
+// Codes of the errors.
+const (
+	CODE_SYNTAX           = "syntax"
+	CODE_UNKNOWN_FIELD    = "unknown_field"
+	CODE_UNKNOWN_OPERATOR = "unknown_operator"
+	CODE_INVALID_VALUE    = "invalid_value"
+	CODE_TOO_LONG         = "too_long"
+)
+
+// Type is the type of the values of a field.
+type Type int
+
+const (
+	TYPE_STRING Type = iota
+	TYPE_NUMBER
+	TYPE_DATE
+	// TYPE_ANY fields take strings, and numbers when they are written bare.
+	TYPE_ANY
+)
+
+// Schema is the whitelist of the fields that can be filtered.
+type Schema struct {
+	Fields map[string]Type
+	// Families are fields named by a prefix and a name, like custom fields.
+	Families map[string]Family
+}
+
+// Family is a group of fields sharing a prefix, their names must match Name.
+type Family struct {
+	Type Type
+	Name *regexp.Regexp
+}
+
+// lookup returns the type of a field.
+func (s Schema) lookup(field string) (Type, bool) {
+
+	if t, ok := s.Fields[field]; ok {
+		return t, true
+	}
+
+	for prefix, family := range s.Families {
+		if len(field) > len(prefix) && field[:len(prefix)] == prefix && family.Name.MatchString(field[len(prefix):]) {
+			return family.Type, true
+		}
+	}
+
+	return 0, false
+}
+
+// Node is a node of the tree of an expression: And, Or, Not or Comparison.
+type Node interface {
+	node()
+}
+
+type And struct {
+	Left  Node
+	Right Node
+}
+
+type Or struct {
+	Left  Node
+	Right Node
+}
+
+type Not struct {
+	Node Node
+}
+
+// Comparison compares a field to values, of the type of the field. Only the
+// in operator has more than one value.
+type Comparison struct {
+	Field  string
+	Op     string
+	Values []interface{}
+}
+
+func (And) node()        {}
+func (Or) node()         {}
+func (Not) node()        {}
+func (Comparison) node() {}
+
+// Error is an invalid expression. Position is the 1-based position of the
+// character the wrong token starts at.
+type Error struct {
+	Code     string
+	Message  string
+	Position int
+	Token    string
+}
+
+func (e *Error) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("filter: %s at position %d", e.Message, e.Position)
+	}
+	return fmt.Sprintf("filter: %s at position %d near %q", e.Message, e.Position, e.Token)
+}
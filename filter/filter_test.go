@@ -0,0 +1,115 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSchema = Schema{
+	Fields: map[string]Type{
+		"worker_name": TYPE_STRING,
+		"date":        TYPE_DATE,
+		"version":     TYPE_NUMBER,
+	},
+	Families: map[string]Family{
+		"custom.": {Type: TYPE_ANY, Name: regexp.MustCompile(`^[a-z_]+$`)},
+	},
+}
+
+func TestParsePrecedence(t *testing.T) {
+
+	node, err := Parse("worker_name eq 'ana' or version gt 2 and not version eq 3", testSchema, time.UTC)
+	assert.Nil(t, err)
+
+	assert.Equal(t, Or{
+		Left: Comparison{Field: "worker_name", Op: OP_EQ, Values: []interface{}{"ana"}},
+		Right: And{
+			Left:  Comparison{Field: "version", Op: OP_GT, Values: []interface{}{int64(2)}},
+			Right: Not{Node: Comparison{Field: "version", Op: OP_EQ, Values: []interface{}{int64(3)}}},
+		},
+	}, node)
+}
+
+func TestParseParenthesesAndIn(t *testing.T) {
+
+	node, err := Parse("(custom.tag IN ('hvac', 'o''neil') OR custom.priority ge 2.5) AND worker_name NE 'ana'", testSchema, time.UTC)
+	assert.Nil(t, err)
+
+	assert.Equal(t, And{
+		Left: Or{
+			Left:  Comparison{Field: "custom.tag", Op: OP_IN, Values: []interface{}{"hvac", "o'neil"}},
+			Right: Comparison{Field: "custom.priority", Op: OP_GE, Values: []interface{}{2.5}},
+		},
+		Right: Comparison{Field: "worker_name", Op: OP_NE, Values: []interface{}{"ana"}},
+	}, node)
+}
+
+func TestParseDatesInLocation(t *testing.T) {
+
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	assert.Nil(t, err)
+
+	node, err := Parse("date ge 2024-06-01 and date lt '2024-06-02T10:00:00Z' and date lt '2024-06-03 01:00:00PM'", testSchema, lisbon)
+	assert.Nil(t, err)
+
+	and := node.(And)
+	assert.Equal(t, []interface{}{time.Date(2024, time.May, 31, 23, 0, 0, 0, time.UTC)}, and.Left.(And).Left.(Comparison).Values)
+	assert.Equal(t, []interface{}{time.Date(2024, time.June, 2, 10, 0, 0, 0, time.UTC)}, and.Left.(And).Right.(Comparison).Values)
+	assert.Equal(t, []interface{}{time.Date(2024, time.June, 3, 12, 0, 0, 0, time.UTC)}, and.Right.(Comparison).Values)
+}
+
+func TestParseErrorsPointAtToken(t *testing.T) {
+
+	cases := []struct {
+		src      string
+		code     string
+		position int
+		token    string
+	}{
+		{"status eq 'done'", CODE_UNKNOWN_FIELD, 1, "status"},
+		{"custom.Tag eq 'x'", CODE_UNKNOWN_FIELD, 1, "custom.Tag"},
+		{"worker_name like 'a%'", CODE_UNKNOWN_OPERATOR, 13, "like"},
+		{"worker_name eq ana", CODE_SYNTAX, 16, "ana"},
+		{"worker_name eq 5", CODE_INVALID_VALUE, 16, "5"},
+		{"version eq 'two'", CODE_INVALID_VALUE, 12, "two"},
+		{"date ge 2024-13-01", CODE_INVALID_VALUE, 9, "2024-13-01"},
+		{"version eq 1 and", CODE_SYNTAX, 17, ""},
+		{"(version eq 1", CODE_SYNTAX, 14, ""},
+		{"version eq 1 version eq 2", CODE_SYNTAX, 14, "version"},
+		{"custom.tag in ('a' 'b')", CODE_SYNTAX, 20, "'b'"},
+		{"worker_name eq 'ana", CODE_SYNTAX, 16, "'ana"},
+		{"version eq 1; drop table tasks", CODE_SYNTAX, 13, ";"},
+		{"and eq 1", CODE_SYNTAX, 1, "and"},
+	}
+
+	for _, c := range cases {
+		_, err := Parse(c.src, testSchema, time.UTC)
+		if assert.Error(t, err, c.src) {
+			filterErr := err.(*Error)
+			assert.Equal(t, c.code, filterErr.Code, c.src)
+			assert.Equal(t, c.position, filterErr.Position, c.src)
+			assert.Equal(t, c.token, filterErr.Token, c.src)
+		}
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+
+	_, err := Parse("status eq 'done'", testSchema, time.UTC)
+	assert.EqualError(t, err, `filter: unknown field at position 1 near "status"`)
+
+	_, err = Parse("version eq", testSchema, time.UTC)
+	assert.EqualError(t, err, "filter: unexpected end, expected a value at position 11")
+}
+
+func TestParseRejectsLongExpressions(t *testing.T) {
+
+	_, err := Parse(strings.Repeat("(", MAX_EXPRESSION_CHARS+1), testSchema, time.UTC)
+	if assert.Error(t, err) {
+		assert.Equal(t, CODE_TOO_LONG, err.(*Error).Code)
+	}
+}
@@ -0,0 +1,108 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	endToken tokenKind = iota
+	wordToken
+	stringToken
+	literalToken
+	openToken
+	closeToken
+	commaToken
+)
+
+// token is a token of an expression. Words are field names, operators and
+// keywords, literals are bare numbers and dates.
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// is tells whether the token is the keyword, ignoring case.
+func (t token) is(keyword string) bool {
+	return t.kind == wordToken && strings.EqualFold(t.text, keyword)
+}
+
+// lex splits an expression into tokens, the last one is an end token.
+func lex(src string) ([]token, error) {
+
+	runes := []rune(src)
+	tokens := make([]token, 0)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+
+		case r == '(':
+			tokens = append(tokens, token{kind: openToken, text: "(", pos: start + 1})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{kind: closeToken, text: ")", pos: start + 1})
+			i++
+
+		case r == ',':
+			tokens = append(tokens, token{kind: commaToken, text: ",", pos: start + 1})
+			i++
+
+		case r == '\'':
+			var text strings.Builder
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\'' {
+					// a quote is escaped by another one
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						text.WriteRune('\'')
+						i++
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				text.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, &Error{Code: CODE_SYNTAX, Message: "unterminated string", Position: start + 1, Token: string(runes[start:])}
+			}
+			tokens = append(tokens, token{kind: stringToken, text: text.String(), pos: start + 1})
+
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (isWordRune(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: wordToken, text: string(runes[start:i]), pos: start + 1})
+
+		case unicode.IsDigit(r) || r == '-' || r == '+':
+			// numbers and dates like 2024-01-01T10:00:00+01:00
+			for i++; i < len(runes) && isLiteralRune(runes[i]); i++ {
+			}
+			tokens = append(tokens, token{kind: literalToken, text: string(runes[start:i]), pos: start + 1})
+
+		default:
+			return nil, &Error{Code: CODE_SYNTAX, Message: "unexpected character", Position: start + 1, Token: string(r)}
+		}
+	}
+
+	return append(tokens, token{kind: endToken, pos: len(runes) + 1}), nil
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func isLiteralRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-+:.", r)
+}
@@ -0,0 +1,277 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+)
+
+type parser struct {
+	tokens []token
+	at     int
+	schema Schema
+	loc    *time.Location
+}
+
+// Parse parses an expression and checks it against the schema. Dates without
+// zone are read in loc.
+func Parse(src string, schema Schema, loc *time.Location) (Node, error) {
+
+	if len([]rune(src)) > MAX_EXPRESSION_CHARS {
+		return nil, &Error{Code: CODE_TOO_LONG, Message: fmt.Sprintf("expression is longer than %d characters", MAX_EXPRESSION_CHARS), Position: MAX_EXPRESSION_CHARS + 1}
+	}
+
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	p := &parser{tokens: tokens, schema: schema, loc: loc}
+
+	node, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != endToken {
+		return nil, unexpected(t, "and, or or the end")
+	}
+
+	return node, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.at]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.at]
+	if t.kind != endToken {
+		p.at++
+	}
+	return t
+}
+
+// or := and ("or" and)*
+func (p *parser) or() (Node, error) {
+
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().is("or") {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// and := unary ("and" unary)*
+func (p *parser) and() (Node, error) {
+
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().is("and") {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// unary := "not" unary | "(" or ")" | comparison
+func (p *parser) unary() (Node, error) {
+
+	t := p.peek()
+
+	if t.is("not") {
+		p.next()
+		node, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Node: node}, nil
+	}
+
+	if t.kind == openToken {
+		p.next()
+		node, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != closeToken {
+			return nil, unexpected(closing, "\")\"")
+		}
+		return node, nil
+	}
+
+	return p.comparison()
+}
+
+// comparison := field operator value | field "in" "(" value ("," value)* ")"
+func (p *parser) comparison() (Node, error) {
+
+	field := p.next()
+	if field.kind != wordToken || isKeyword(field.text) {
+		return nil, unexpected(field, "a field")
+	}
+
+	fieldType, ok := p.schema.lookup(field.text)
+	if !ok {
+		return nil, &Error{Code: CODE_UNKNOWN_FIELD, Message: "unknown field", Position: field.pos, Token: field.text}
+	}
+
+	opToken := p.next()
+	if opToken.kind != wordToken {
+		return nil, unexpected(opToken, "an operator")
+	}
+	op := strings.ToLower(opToken.text)
+	if !isOperator(op) {
+		return nil, &Error{Code: CODE_UNKNOWN_OPERATOR, Message: "unknown operator, use one of " + strings.Join(OPERATORS, ", "), Position: opToken.pos, Token: opToken.text}
+	}
+
+	comparison := Comparison{Field: field.text, Op: op}
+
+	if op != OP_IN {
+		value, err := p.value(field.text, fieldType)
+		if err != nil {
+			return nil, err
+		}
+		comparison.Values = []interface{}{value}
+		return comparison, nil
+	}
+
+	if open := p.next(); open.kind != openToken {
+		return nil, unexpected(open, "\"(\"")
+	}
+	for {
+		value, err := p.value(field.text, fieldType)
+		if err != nil {
+			return nil, err
+		}
+		comparison.Values = append(comparison.Values, value)
+
+		t := p.next()
+		if t.kind == closeToken {
+			break
+		}
+		if t.kind != commaToken {
+			return nil, unexpected(t, "\",\" or \")\"")
+		}
+	}
+
+	return comparison, nil
+}
+
+// value reads a value and converts it to the type of the field.
+func (p *parser) value(field string, fieldType Type) (interface{}, error) {
+
+	t := p.next()
+	if t.kind != stringToken && t.kind != literalToken {
+		return nil, unexpected(t, "a value")
+	}
+
+	invalid := func(message string) error {
+		return &Error{Code: CODE_INVALID_VALUE, Message: field + " " + message, Position: t.pos, Token: t.text}
+	}
+
+	switch fieldType {
+	case TYPE_STRING:
+		if t.kind != stringToken {
+			return nil, invalid("takes a quoted string")
+		}
+		return t.text, nil
+
+	case TYPE_NUMBER:
+		if t.kind != literalToken {
+			return nil, invalid("takes a number")
+		}
+		if n, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return n, nil
+		}
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, invalid("takes a number")
+		}
+		return n, nil
+
+	case TYPE_DATE:
+		date, err := parseDate(t.text, p.loc)
+		if err != nil {
+			return nil, invalid("takes a date like 2024-01-01 or 2024-01-01T10:00:00Z")
+		}
+		return date, nil
+	}
+
+	// bare values of any fields are numbers when they can be
+	if t.kind == literalToken {
+		if n, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return n, nil
+		}
+	}
+
+	return t.text, nil
+}
+
+// parseDate parses a day, the start of the day in loc, or a date in one of
+// the formats of models.ParseDate, and returns it in UTC.
+func parseDate(value string, loc *time.Location) (time.Time, error) {
+
+	if day, err := models.ParseDay(value, loc); err == nil {
+		return day.After, nil
+	}
+
+	return models.ParseDate(value, loc)
+}
+
+// unexpected is the error of a token that isn't what the grammar expects.
+func unexpected(t token, expected string) error {
+
+	if t.kind == endToken {
+		return &Error{Code: CODE_SYNTAX, Message: "unexpected end, expected " + expected, Position: t.pos}
+	}
+
+	text := t.text
+	if t.kind == stringToken {
+		text = "'" + strings.ReplaceAll(text, "'", "''") + "'"
+	}
+
+	return &Error{Code: CODE_SYNTAX, Message: "unexpected token, expected " + expected, Position: t.pos, Token: text}
+}
+
+func isOperator(op string) bool {
+	for _, o := range OPERATORS {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func isKeyword(word string) bool {
+	switch strings.ToLower(word) {
+	case "and", "or", "not":
+		return true
+	}
+	return false
+}
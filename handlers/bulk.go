@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/MrBolas/SupervisorAPI/search"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// errBulkRollback aborts the transaction of an atomic bulk request.
+var errBulkRollback = errors.New("bulk operation failed")
+
+func (th *TasksHandler) BulkTasks(c echo.Context) error {
+
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	req := new(models.BulkRequest)
+	err = c.Bind(req)
+	if err != nil {
+		return problem.Write(c, problem.Malformed("malformed request body"))
+	}
+
+	err = req.Validate()
+	if err != nil {
+		return problem.Write(c, validationProblem(err))
+	}
+
+	limits, err := teamLimits(th.limits, c)
+	if err != nil {
+		return err
+	}
+
+	results := make([]models.BulkResult, len(req.Operations))
+	created := make([]models.Task, 0)
+
+	if req.Atomic {
+		err = th.repo.Transaction(func(tx repositories.Repository) error {
+			creates := make([]int, 0)
+			for i, op := range req.Operations {
+				result, task := th.bulkOperation(c, tx, i, op, loc, limits, true)
+				results[i] = result
+				if result.Error != nil {
+					return errBulkRollback
+				}
+				if op.Op == models.BULK_CREATE {
+					created = append(created, task)
+					creates = append(creates, i)
+				}
+			}
+
+			// the new tasks are inserted together, once every operation passed
+			if err := tx.CreateTasks(created); err != nil {
+				for _, i := range creates {
+					results[i].Id = nil
+					results[i] = bulkFailure(c, results[i], err)
+				}
+				return errBulkRollback
+			}
+			return nil
+		})
+		if err != nil && err != errBulkRollback {
+			return err
+		}
+
+		if err == errBulkRollback {
+			// nothing was committed, flag the operations that did not fail themselves
+			for i := range results {
+				if results[i].Op == "" {
+					results[i] = models.BulkResult{Index: i, Op: req.Operations[i].Op, Status: http.StatusFailedDependency, Error: problem.New(http.StatusFailedDependency, problem.CODE_NOT_EXECUTED, "not executed, another operation failed")}
+				} else if results[i].Error == nil {
+					results[i].Status = http.StatusFailedDependency
+					results[i].Error = problem.New(http.StatusFailedDependency, problem.CODE_ROLLED_BACK, "rolled back, another operation failed")
+					results[i].Task = nil
+				}
+			}
+
+			return c.JSON(http.StatusUnprocessableEntity, models.BulkResponse{
+				Atomic:    true,
+				Committed: false,
+				Results:   results,
+			})
+		}
+	} else {
+		for i, op := range req.Operations {
+			result, task := th.bulkOperation(c, th.repo, i, op, loc, limits, false)
+			results[i] = result
+			if result.Error == nil && op.Op == models.BULK_CREATE {
+				created = append(created, task)
+			}
+		}
+	}
+
+	// events are only published for committed tasks
+	for _, task := range created {
+		th.publishTaskCreated(c, task)
+	}
+	for _, result := range results {
+		if result.Error == nil && result.Id != nil {
+			th.publishTaskEvent(c, BULK_EVENTS[result.Op], *result.Id)
+		}
+	}
+
+	status := http.StatusOK
+	for _, result := range results {
+		if result.Error != nil {
+			status = http.StatusMultiStatus
+		}
+	}
+
+	return c.JSON(status, models.BulkResponse{
+		Atomic:    req.Atomic,
+		Committed: true,
+		Results:   results,
+	})
+}
+
+// bulkOperation executes a single operation of a bulk request against repo,
+// applying the same rules as the single task endpoints. With batch, the task
+// of a create is returned without inserting it, the caller inserts the tasks
+// of all the creates at once.
+func (th *TasksHandler) bulkOperation(c echo.Context, repo repositories.Repository, index int, op models.BulkOperation, loc *time.Location, limits models.Limits, batch bool) (models.BulkResult, models.Task) {
+
+	result := models.BulkResult{
+		Index: index,
+		Op:    op.Op,
+	}
+
+	fail := func(err error) (models.BulkResult, models.Task) {
+		return bulkFailure(c, result, err), models.Task{}
+	}
+
+	switch op.Op {
+	case models.BULK_CREATE:
+		err := op.Task.ValidateFields(models.TASK_FIELDS, limits)
+		if err != nil {
+			return fail(taskProblem(index, err))
+		}
+
+		err = validateCustomFields(th.schemas, th.users, c, auth.GetUserId(c), op.Task.Custom)
+		if err != nil {
+			return fail(err)
+		}
+
+		task, err := op.Task.ToTask(auth.GetUserId(c), auth.GetUserNickname(c), loc)
+		if err != nil {
+			return fail(validationProblem(err))
+		}
+
+		// Index and Encrypt Summary
+		task.SearchTokens = search.Tokens(th.index, task.Summary)
+		task.Summary = th.ce.Encrypt(task.Summary)
+
+		if !batch {
+			task, err = repo.CreateTask(task)
+			if err == gorm.ErrRegistered {
+				return fail(problem.Conflict("task already exists"))
+			}
+			if err != nil {
+				return fail(err)
+			}
+		}
+
+		response := task.ToResponseIn(loc)
+		response.Summary = op.Task.Summary
+		result.Id = &task.Id
+		result.Status = http.StatusCreated
+		result.Task = &response
+		return result, task
+
+	case models.BULK_UPDATE:
+		result.Id = &op.Id
+
+		existingTask, err := repo.GetTaskById(op.Id)
+		if err == gorm.ErrRecordNotFound {
+			return fail(problem.NotFound("task not found"))
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		if auth.GetUserId(c) != existingTask.WorkerId {
+			return fail(problem.Unauthorized())
+		}
+
+		if op.Version > 0 && op.Version != existingTask.Version {
+			return fail(problem.PreconditionFailed())
+		}
+
+		err = op.Task.ValidateFields(models.TASK_FIELDS, limits)
+		if err != nil {
+			return fail(taskProblem(index, err))
+		}
+
+		err = validateCustomFields(th.schemas, th.users, c, existingTask.WorkerId, op.Task.Custom)
+		if err != nil {
+			return fail(err)
+		}
+
+		newTask, err := op.Task.ToTask(existingTask.WorkerId, existingTask.WorkerName, loc)
+		if err != nil {
+			return fail(validationProblem(err))
+		}
+
+		// Index and Encrypt Summary
+		newTask.SearchTokens = search.Tokens(th.index, newTask.Summary)
+		newTask.Summary = th.ce.Encrypt(newTask.Summary)
+
+		task, err := repo.UpdateTask(op.Id, existingTask, newTask, auth.GetUserId(c))
+		if err == repositories.ErrVersionConflict {
+			return fail(problem.PreconditionFailed())
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		response := task.ToResponseIn(loc)
+		response.Summary = op.Task.Summary
+		result.Status = http.StatusOK
+		result.Task = &response
+		return result, task
+
+	case models.BULK_DELETE:
+		result.Id = &op.Id
+
+		// Only Manager can delete
+		if !auth.IsManager(c) {
+			return fail(problem.Unauthorized())
+		}
+
+		err := repo.DeleteTask(op.Id, op.Version)
+		if err == repositories.ErrVersionConflict {
+			return fail(problem.PreconditionFailed())
+		}
+		if err == gorm.ErrRecordNotFound {
+			return fail(problem.NotFound("task not found"))
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		result.Status = http.StatusNoContent
+		return result, models.Task{}
+	}
+
+	return fail(problem.BadRequest(problem.CODE_VALIDATION_FAILED, "op must be create, update or delete"))
+}
+
+// bulkFailure fails the operation of result with the problem of err, mapped
+// as by the HTTPErrorHandler. Internal errors are logged, their problem
+// doesn't tell them.
+func bulkFailure(c echo.Context, result models.BulkResult, err error) models.BulkResult {
+
+	result.Error = problem.FromError(err, ERROR_MAPPINGS...)
+	result.Status = result.Error.Status
+	result.Task = nil
+
+	if result.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %s[%d]: %v", c.Request().Method, c.Request().URL.Path, models.FIELD_OPERATIONS, result.Index, err)
+	}
+
+	return result
+}
+
+// taskProblem reports the validation errors of the task of an operation
+// under its path in the request, such as operations[2].task.summary.
+func taskProblem(index int, err error) *problem.Problem {
+
+	if fieldErrors, ok := models.AsFieldErrors(err); ok {
+		err = fieldErrors.WithPrefix(fmt.Sprintf("%s[%d].task", models.FIELD_OPERATIONS, index))
+	}
+
+	return validationProblem(err)
+}
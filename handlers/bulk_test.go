@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func newBulkContext(t *testing.T, bulkRequest models.BulkRequest, claims map[string]string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	u, err := json.Marshal(bulkRequest)
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks/bulk", strings.NewReader(string(u)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	addClaimsToJWTContext(c, claims)
+	c.SetPath("/tasks/bulk")
+
+	return c, rec
+}
+
+func TestBulkTasksShould200OKWhenAllOperationsSucceed(t *testing.T) {
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+
+	c, rec := newBulkContext(t, models.BulkRequest{
+		Atomic: true,
+		Operations: []models.BulkOperation{
+			{Op: models.BULK_CREATE, Task: &mockedTaskRequest},
+			{Op: models.BULK_DELETE, Id: mockedTask.Id},
+		},
+	}, claims)
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("CreateTasks", mock.MatchedBy(func(tasks []models.Task) bool {
+		return len(tasks) == 1
+	})).Return(nil).Once()
+	mr.On("DeleteTask", mockedTask.Id, 0).Return(nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.BulkTasks(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.BulkResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.True(t, response.Committed)
+		assert.Equal(t, http.StatusCreated, response.Results[0].Status)
+		assert.Equal(t, mockedTaskRequest.Summary, response.Results[0].Task.Summary)
+		assert.Equal(t, http.StatusNoContent, response.Results[1].Status)
+		mr.AssertExpectations(t)
+		mr.AssertNotCalled(t, "CreateTask", mock.Anything)
+	}
+}
+
+func TestBulkTasksShould207MultiStatusWhenSomeOperationsFail(t *testing.T) {
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+
+	missingId := uuid.Must(uuid.NewV4())
+	c, rec := newBulkContext(t, models.BulkRequest{
+		Operations: []models.BulkOperation{
+			{Op: models.BULK_CREATE, Task: &mockedTaskRequest},
+			{Op: models.BULK_UPDATE, Id: missingId, Task: &mockedTaskRequest},
+			{Op: models.BULK_DELETE, Id: mockedTask.Id},
+		},
+	}, claims)
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("CreateTask", mock.Anything).Return(mockedTask, nil)
+	mr.On("GetTaskById", missingId).Return(models.Task{}, gorm.ErrRecordNotFound)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.BulkTasks(c)) {
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+
+		var response models.BulkResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.True(t, response.Committed)
+		assert.Equal(t, http.StatusCreated, response.Results[0].Status)
+		assert.Equal(t, http.StatusNotFound, response.Results[1].Status)
+		assert.Equal(t, http.StatusUnauthorized, response.Results[2].Status)
+		mr.AssertNotCalled(t, "DeleteTask", mock.Anything, mock.Anything)
+	}
+}
+
+func TestBulkTasksShould422UnprocessableEntityWhenAtomicOperationFails(t *testing.T) {
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+
+	invalidRequest := mockedTaskRequest
+	invalidRequest.Date = "2022 09 12"
+
+	c, rec := newBulkContext(t, models.BulkRequest{
+		Atomic: true,
+		Operations: []models.BulkOperation{
+			{Op: models.BULK_CREATE, Task: &mockedTaskRequest},
+			{Op: models.BULK_CREATE, Task: &invalidRequest},
+			{Op: models.BULK_CREATE, Task: &mockedTaskRequest},
+		},
+	}, claims)
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.BulkTasks(c)) {
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+		var response models.BulkResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.False(t, response.Committed)
+		assert.Equal(t, http.StatusFailedDependency, response.Results[0].Status)
+		assert.Nil(t, response.Results[0].Task)
+		assert.Equal(t, http.StatusBadRequest, response.Results[1].Status)
+		assert.Equal(t, http.StatusFailedDependency, response.Results[2].Status)
+		mr.AssertNotCalled(t, "CreateTask", mock.Anything)
+		mr.AssertNotCalled(t, "CreateTasks", mock.Anything)
+	}
+}
+
+func TestBulkTasksShouldMapTheErrorsOfAtomicCreates(t *testing.T) {
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{gorm.ErrRegistered, http.StatusConflict, problem.CODE_CONFLICT},
+		{errors.New("connection lost"), http.StatusInternalServerError, problem.CODE_INTERNAL_ERROR},
+	}
+
+	for _, test := range tests {
+		c, rec := newBulkContext(t, models.BulkRequest{
+			Atomic: true,
+			Operations: []models.BulkOperation{
+				{Op: models.BULK_CREATE, Task: &mockedTaskRequest},
+				{Op: models.BULK_DELETE, Id: mockedTask.Id},
+				{Op: models.BULK_CREATE, Task: &mockedTaskRequest},
+			},
+		}, claims)
+
+		mr := mockRepo{}
+		ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+		mr.On("DeleteTask", mockedTask.Id, 0).Return(nil)
+		mr.On("CreateTasks", mock.MatchedBy(func(tasks []models.Task) bool {
+			return len(tasks) == 2
+		})).Return(test.err).Once()
+		h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+		// Assertions
+		if assert.NoError(t, h.BulkTasks(c)) {
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+			var response models.BulkResponse
+			assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.False(t, response.Committed)
+			for _, i := range []int{0, 2} {
+				assert.Equal(t, test.status, response.Results[i].Status)
+				assert.Equal(t, test.code, response.Results[i].Error.Code)
+				assert.Nil(t, response.Results[i].Id)
+				assert.Nil(t, response.Results[i].Task)
+			}
+			assert.Equal(t, http.StatusFailedDependency, response.Results[1].Status)
+			mr.AssertExpectations(t)
+		}
+	}
+}
+
+func TestBulkTasksShould400BadRequestWhenOperationIsUnknown(t *testing.T) {
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+
+	c, rec := newBulkContext(t, models.BulkRequest{
+		Operations: []models.BulkOperation{
+			{Op: "upsert", Task: &mockedTaskRequest},
+		},
+	}, claims)
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.BulkTasks(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_VALIDATION_FAILED, "operations[0]: op must be one of create, update, delete")
+	}
+}
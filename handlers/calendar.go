@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/calendar"
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// CALENDAR_FEED_PATH is the public path of the feeds, followed by the token.
+const CALENDAR_FEED_PATH = "/v1/calendar/"
+const CALENDAR_EXTENSION = ".ics"
+
+// MAX_CALENDAR_EVENTS is the most tasks in a feed, the latest ones.
+const MAX_CALENDAR_EVENTS = 500
+
+// CALENDAR_PAST_DAYS is how far back feeds go.
+const CALENDAR_PAST_DAYS = 90
+
+// DEFAULT_EVENT_DURATION is the length of the events of tasks without a
+// duration custom field.
+const DEFAULT_EVENT_DURATION = time.Hour
+
+// CALENDAR_EVENT_SUMMARY is the summary of events of users who didn't opt in
+// to summaries.
+const CALENDAR_EVENT_SUMMARY = "Task"
+
+const CALENDAR_UID_DOMAIN = "supervisorapi"
+
+type CalendarHandler struct {
+	users repositories.UsersRepository
+	tasks repositories.Repository
+	ce    encryption.CryptoEngine
+}
+
+func NewCalendarHandler(users repositories.UsersRepository, tasks repositories.Repository, ce encryption.CryptoEngine) *CalendarHandler {
+	return &CalendarHandler{
+		users: users,
+		tasks: tasks,
+		ce:    ce,
+	}
+}
+
+type CalendarFeedResponse struct {
+	Url string `json:"url"`
+}
+
+// CreateCalendarToken creates the feed of the tasks of the user, replacing
+// the previous one. The URL is only shown here, the token isn't stored.
+func (ch *CalendarHandler) CreateCalendarToken(c echo.Context) error {
+
+	token, hash, err := calendar.NewToken()
+	if err != nil {
+		return err
+	}
+
+	err = ch.users.SetCalendarToken(auth.GetUserId(c), hash)
+	if err != nil {
+		return err
+	}
+
+	url := c.Scheme() + "://" + c.Request().Host + CALENDAR_FEED_PATH + token + CALENDAR_EXTENSION
+	return c.JSON(http.StatusCreated, CalendarFeedResponse{Url: url})
+}
+
+// DeleteCalendarToken revokes the feed of the user.
+func (ch *CalendarHandler) DeleteCalendarToken(c echo.Context) error {
+
+	err := ch.users.SetCalendarToken(auth.GetUserId(c), "")
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetCalendarFeed is the iCalendar of the tasks of the owner of the token.
+// Feeds are read by calendar apps without a JWT, the token is the
+// credential. Summaries are only included when the user opted in.
+func (ch *CalendarHandler) GetCalendarFeed(c echo.Context) error {
+
+	token := strings.TrimSuffix(c.Param("token"), CALENDAR_EXTENSION)
+
+	user, err := ch.users.GetUserByCalendarToken(calendar.HashToken(token))
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("calendar not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	query := repositories.NewListQuery()
+	query.Filters["worker_id"] = user.Id
+	query.IntervalFilters[repositories.QUERY_AFTER] = time.Now().AddDate(0, 0, -CALENDAR_PAST_DAYS)
+	query.Sort = repositories.Sort{{Field: "date", Order: repositories.SORT_DESC}}
+	query.StartBatches(MAX_CALENDAR_EVENTS)
+
+	tasks, err := ch.tasks.ListTasks(query)
+	if err != nil {
+		return err
+	}
+	tasks, _ = query.NextBatch(tasks)
+
+	stamp := time.Now()
+	events := make([]calendar.Event, 0, len(tasks))
+	for _, task := range tasks {
+		if !task.Date.Valid {
+			continue
+		}
+		if user.CalendarSummaries {
+			task.Summary = ch.ce.Decrypt(task.Summary)
+		}
+		events = append(events, taskEvent(task, stamp, user.CalendarSummaries))
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, calendar.CONTENT_TYPE)
+	c.Response().WriteHeader(http.StatusOK)
+
+	return calendar.Write(c.Response(), "Tasks of "+user.Nickname, events)
+}
+
+// taskEvent is the event of a task with a date. Events last the duration
+// custom field, in minutes. Summaries are those of decrypted tasks, the
+// first line is the summary of the event and the rest its description.
+func taskEvent(task models.Task, stamp time.Time, withSummary bool) calendar.Event {
+
+	duration := DEFAULT_EVENT_DURATION
+	if minutes, ok := task.Custom[repositories.DURATION_FIELD].(float64); ok && minutes > 0 {
+		duration = time.Duration(minutes * float64(time.Minute))
+	}
+
+	event := calendar.Event{
+		UID:      task.Id.String() + "@" + CALENDAR_UID_DOMAIN,
+		Stamp:    stamp,
+		Start:    task.Date.Time,
+		End:      task.Date.Time.Add(duration),
+		Summary:  CALENDAR_EVENT_SUMMARY,
+		Sequence: task.Version - 1,
+	}
+
+	if withSummary {
+		text := strings.TrimSpace(task.SummaryText())
+		if first := strings.SplitN(text, "\n", 2)[0]; first != "" {
+			event.Summary = first
+		}
+		event.Description = text
+	}
+
+	return event
+}
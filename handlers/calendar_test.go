@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/calendar"
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func calendarTask(ce encryption.CryptoEngine) models.Task {
+	task := mockedTask
+	task.Summary = ce.Encrypt("**Pump** replaced\nValves checked")
+	task.Date = sql.NullTime{Time: time.Date(2022, time.May, 23, 15, 33, 0, 0, time.UTC), Valid: true}
+	task.Custom = models.CustomFields{"duration": float64(90)}
+	task.Version = 3
+	return task
+}
+
+func getCalendarFeed(t *testing.T, user models.User) *httptest.ResponseRecorder {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/v1/calendar/secret.ics", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	c.SetPath("/v1/calendar/:token")
+	c.SetParamNames("token")
+	c.SetParamValues("secret.ics")
+
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+
+	mr := mockRepo{}
+	mr.On("ListTasks", mock.MatchedBy(func(query repositories.ListQuery) bool {
+		_, after := query.IntervalFilters[repositories.QUERY_AFTER]
+		return query.Filters["worker_id"] == "mocked_worker_id" && after && query.Pagination.PageSize == MAX_CALENDAR_EVENTS
+	})).Return([]models.Task{calendarTask(ce), {Id: mockedTask.Id}}, nil)
+
+	mu := mockUsersRepo{}
+	mu.On("GetUserByCalendarToken", calendar.HashToken("secret")).Return(user, nil)
+
+	h := NewCalendarHandler(&mu, &mr, ce)
+
+	assert.NoError(t, h.GetCalendarFeed(c))
+	mr.AssertExpectations(t)
+	mu.AssertExpectations(t)
+
+	return rec
+}
+
+func TestGetCalendarFeedShould200OKWithoutSummaries(t *testing.T) {
+
+	rec := getCalendarFeed(t, mockedUser)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, calendar.CONTENT_TYPE, rec.Header().Get(echo.HeaderContentType))
+
+	body := rec.Body.String()
+	assert.Equal(t, 1, strings.Count(body, "BEGIN:VEVENT"))
+	assert.Contains(t, body, "UID:a2d45497-09b4-4da1-a0d0-173d0bd12f13@supervisorapi\r\n")
+	assert.Contains(t, body, "DTSTART:20220523T153300Z\r\n")
+	assert.Contains(t, body, "DTEND:20220523T170300Z\r\n")
+	assert.Contains(t, body, "SEQUENCE:2\r\n")
+	assert.Contains(t, body, "SUMMARY:Task\r\n")
+	assert.NotContains(t, body, "DESCRIPTION")
+	assert.NotContains(t, body, "Pump")
+}
+
+func TestGetCalendarFeedShould200OKWithSummariesWhenOptedIn(t *testing.T) {
+
+	user := mockedUser
+	user.CalendarSummaries = true
+
+	rec := getCalendarFeed(t, user)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "SUMMARY:Pump replaced\r\n")
+	assert.Contains(t, body, `DESCRIPTION:Pump replaced\nValves checked`+"\r\n")
+}
+
+func TestGetCalendarFeedShould404NotFoundWhenTokenIsUnknown(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/v1/calendar/unknown.ics", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	c.SetPath("/v1/calendar/:token")
+	c.SetParamNames("token")
+	c.SetParamValues("unknown.ics")
+
+	mu := mockUsersRepo{}
+	mu.On("GetUserByCalendarToken", calendar.HashToken("unknown")).Return(nil, gorm.ErrRecordNotFound)
+
+	h := NewCalendarHandler(&mu, &mockRepo{}, encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk"))
+
+	// Assertions
+	if assert.NoError(t, h.GetCalendarFeed(c)) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CODE_NOT_FOUND, "calendar not found")
+	}
+}
+
+func TestCreateCalendarTokenShould201CreatedWithFeedUrl(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/me/calendar", nil)
+	req.Host = "api.example.com"
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	var hash string
+	mu := mockUsersRepo{}
+	mu.On("SetCalendarToken", "mocked_worker_id", mock.AnythingOfType("string")).Run(func(args mock.Arguments) {
+		hash = args.String(1)
+	}).Return(nil)
+
+	h := NewCalendarHandler(&mu, &mockRepo{}, encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk"))
+
+	// Assertions
+	if assert.NoError(t, h.CreateCalendarToken(c)) {
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response CalendarFeedResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.True(t, strings.HasPrefix(response.Url, "http://api.example.com/v1/calendar/"))
+
+		// only the hash of the token in the URL is stored
+		token := strings.TrimSuffix(strings.TrimPrefix(response.Url, "http://api.example.com/v1/calendar/"), ".ics")
+		assert.Equal(t, calendar.HashToken(token), hash)
+	}
+}
+
+func TestDeleteCalendarTokenShould204NoContent(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/me/calendar", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	mu := mockUsersRepo{}
+	mu.On("SetCalendarToken", "mocked_worker_id", "").Return(nil)
+
+	h := NewCalendarHandler(&mu, &mockRepo{}, encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk"))
+
+	// Assertions
+	if assert.NoError(t, h.DeleteCalendarToken(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		mu.AssertExpectations(t)
+	}
+}
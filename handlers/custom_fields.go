@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var errNoCustomFields = models.NewFieldError(models.FIELD_CUSTOM, "not_allowed", "custom fields are not defined for the team of the worker")
+
+type CustomFieldsHandler struct {
+	repo repositories.CustomFieldsRepository
+}
+
+func NewCustomFieldsHandler(repo repositories.CustomFieldsRepository) *CustomFieldsHandler {
+	return &CustomFieldsHandler{
+		repo: repo,
+	}
+}
+
+func (ch *CustomFieldsHandler) GetCustomFieldSchema(c echo.Context) error {
+
+	team := c.Param("team")
+
+	// Users can read the schema of their own team
+	if !auth.IsAdmin(c) && !auth.IsManager(c) && auth.GetUserTeam(c) != team {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	schema, err := ch.repo.GetCustomFieldSchema(team)
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("custom fields schema not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, schema.ToResponse())
+}
+
+func (ch *CustomFieldsHandler) SaveCustomFieldSchema(c echo.Context) error {
+
+	// Only Admin can define custom fields
+	if !auth.IsAdmin(c) {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return problem.Write(c, problem.Malformed("malformed request body"))
+	}
+
+	err = models.ValidateCustomFieldSchema(string(body))
+	if err != nil {
+		return problem.Write(c, validationProblem(err))
+	}
+
+	schema, err := ch.repo.SaveCustomFieldSchema(models.CustomFieldSchema{
+		Team:      c.Param("team"),
+		Schema:    string(body),
+		UpdatedBy: auth.GetUserId(c),
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, schema.ToResponse())
+}
+
+// validateCustomFields validates the custom fields of a task of the worker
+// against the schema of the team of the worker, who isn't the user for the
+// tasks of others. Teams without schema accept no custom fields. Invalid
+// custom fields are reported as a problem.
+func validateCustomFields(schemas repositories.CustomFieldsRepository, users repositories.UsersRepository, c echo.Context, workerId string, custom models.CustomFields) error {
+
+	team, err := workerTeam(users, c, workerId)
+	if err != nil {
+		return err
+	}
+
+	schema, err := schemas.GetCustomFieldSchema(team)
+	if err == gorm.ErrRecordNotFound {
+		if len(custom) > 0 {
+			return validationProblem(errNoCustomFields)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = schema.Validate(custom)
+	if err != nil {
+		return validationProblem(err)
+	}
+
+	return nil
+}
+
+// workerTeam is the team of the worker, from the token when the worker is the
+// user. Workers without user have no team.
+func workerTeam(users repositories.UsersRepository, c echo.Context, workerId string) (string, error) {
+
+	if workerId == auth.GetUserId(c) {
+		return auth.GetUserTeam(c), nil
+	}
+
+	user, err := users.GetUserById(workerId)
+	if err == gorm.ErrRecordNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return user.Team, nil
+}
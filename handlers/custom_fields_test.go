@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type mockCustomFieldsRepo struct {
+	mock.Mock
+}
+
+var mockedCustomFieldSchema = models.CustomFieldSchema{
+	Team:      "mocked_team",
+	Schema:    `{"type":"object","properties":{"work_order":{"type":"string"}},"additionalProperties":false}`,
+	UpdatedBy: "mocked_admin_id",
+	UpdatedAt: time.Date(2022, time.May, 23, 15, 33, 1, 0, time.UTC),
+}
+
+func (mr *mockCustomFieldsRepo) GetCustomFieldSchema(team string) (models.CustomFieldSchema, error) {
+	args := mr.Called(team)
+
+	mockedSchema := args.Get(0)
+	if mockedSchema == nil {
+		return models.CustomFieldSchema{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.CustomFieldSchema), args.Error(1)
+}
+
+func (mr *mockCustomFieldsRepo) SaveCustomFieldSchema(schema models.CustomFieldSchema) (models.CustomFieldSchema, error) {
+	args := mr.Called(schema)
+
+	mockedSchema := args.Get(0)
+	if mockedSchema == nil {
+		return models.CustomFieldSchema{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.CustomFieldSchema), args.Error(1)
+}
+
+// noCustomFieldsRepo returns a repository where no team has custom fields.
+func noCustomFieldsRepo() *mockCustomFieldsRepo {
+	mr := mockCustomFieldsRepo{}
+	mr.On("GetCustomFieldSchema", mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+	return &mr
+}
+
+func TestGetCustomFieldSchemaShould200OK(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "worker"
+	claims["http://supervisorapi/team"] = "mocked_team"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/custom-fields/:team")
+	c.SetParamNames("team")
+	c.SetParamValues("mocked_team")
+
+	mr := mockCustomFieldsRepo{}
+	mr.On("GetCustomFieldSchema", "mocked_team").Return(mockedCustomFieldSchema, nil)
+	h := NewCustomFieldsHandler(&mr)
+
+	u, err := json.Marshal(mockedCustomFieldSchema.ToResponse())
+	assert.Nil(t, err)
+
+	// Assertions
+	if assert.NoError(t, h.GetCustomFieldSchema(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(u)+"\n", rec.Body.String())
+	}
+}
+
+func TestGetCustomFieldSchemaShould401UnauthorizedForAnotherTeam(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "worker"
+	claims["http://supervisorapi/team"] = "other_team"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/custom-fields/:team")
+	c.SetParamNames("team")
+	c.SetParamValues("mocked_team")
+
+	h := NewCustomFieldsHandler(&mockCustomFieldsRepo{})
+
+	// Assertions
+	if assert.NoError(t, h.GetCustomFieldSchema(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestSaveCustomFieldSchemaShould200OK(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(mockedCustomFieldSchema.Schema))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "admin"
+	claims["sub"] = "mocked_admin_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/custom-fields/:team")
+	c.SetParamNames("team")
+	c.SetParamValues("mocked_team")
+
+	mr := mockCustomFieldsRepo{}
+	mr.On("SaveCustomFieldSchema", mock.MatchedBy(func(s models.CustomFieldSchema) bool {
+		return s.Team == "mocked_team" && s.UpdatedBy == "mocked_admin_id"
+	})).Return(mockedCustomFieldSchema, nil)
+	h := NewCustomFieldsHandler(&mr)
+
+	// Assertions
+	if assert.NoError(t, h.SaveCustomFieldSchema(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestSaveCustomFieldSchemaShould401UnauthorizedWhenNotAdmin(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(mockedCustomFieldSchema.Schema))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/custom-fields/:team")
+	c.SetParamNames("team")
+	c.SetParamValues("mocked_team")
+
+	h := NewCustomFieldsHandler(&mockCustomFieldsRepo{})
+
+	// Assertions
+	if assert.NoError(t, h.SaveCustomFieldSchema(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestSaveCustomFieldSchemaShould400BadRequestWhenSchemaIsInvalid(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"type":"array"}`))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "admin"
+	claims["sub"] = "mocked_admin_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/custom-fields/:team")
+	c.SetParamNames("team")
+	c.SetParamValues("mocked_team")
+
+	h := NewCustomFieldsHandler(&mockCustomFieldsRepo{})
+
+	// Assertions
+	if assert.NoError(t, h.SaveCustomFieldSchema(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestCreateTaskShould400BadRequestWhenCustomFieldsAreInvalid(t *testing.T) {
+	e := echo.New()
+	taskRequest := mockedTaskRequest
+	taskRequest.Custom = models.CustomFields{"vehicle_plate": "AA-00-BB"}
+	u, err := json.Marshal(taskRequest)
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/task", strings.NewReader(string(u)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "worker"
+	claims["http://supervisorapi/team"] = "mocked_team"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	schemas := mockCustomFieldsRepo{}
+	schemas.On("GetCustomFieldSchema", "mocked_team").Return(mockedCustomFieldSchema, nil)
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), &schemas, noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.CreateTask(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		mr.AssertNotCalled(t, "CreateTask", mock.Anything)
+
+		var p problem.Problem
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &p))
+		assert.Equal(t, problem.CODE_VALIDATION_FAILED, p.Code)
+		if assert.Len(t, p.Errors, 1) {
+			assert.Equal(t, "custom", p.Errors[0].Field)
+			assert.Equal(t, "additional_property_not_allowed", p.Errors[0].Code)
+		}
+	}
+}
+
+func TestCreateTaskShould400BadRequestWhenTeamHasNoCustomFields(t *testing.T) {
+	e := echo.New()
+	taskRequest := mockedTaskRequest
+	taskRequest.Custom = models.CustomFields{"work_order": "WO-1"}
+	u, err := json.Marshal(taskRequest)
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/task", strings.NewReader(string(u)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "worker"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.CreateTask(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		mr.AssertNotCalled(t, "CreateTask", mock.Anything)
+	}
+}
+
+func TestValidateCustomFieldsShouldUseTheSchemaOfTheTeamOfTheWorker(t *testing.T) {
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodPut, "/tasks", nil), httptest.NewRecorder())
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["http://supervisorapi/team"] = "mocked_other_team"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	users := mockUsersRepo{}
+	users.On("GetUserById", "mocked_worker_id").Return(models.User{Id: "mocked_worker_id", Team: "mocked_team"}, nil)
+	users.On("GetUserById", "mocked_unknown_id").Return(nil, gorm.ErrRecordNotFound)
+	schemas := mockCustomFieldsRepo{}
+	schemas.On("GetCustomFieldSchema", "mocked_team").Return(mockedCustomFieldSchema, nil)
+	schemas.On("GetCustomFieldSchema", "").Return(nil, gorm.ErrRecordNotFound)
+
+	// Assertions
+	assert.Nil(t, validateCustomFields(&schemas, &users, c, "mocked_worker_id", models.CustomFields{"work_order": "WO-1"}))
+	assert.Error(t, validateCustomFields(&schemas, &users, c, "mocked_worker_id", models.CustomFields{"vehicle_plate": "AA-00-BB"}))
+	assert.Error(t, validateCustomFields(&schemas, &users, c, "mocked_unknown_id", models.CustomFields{"work_order": "WO-1"}))
+	schemas.AssertNotCalled(t, "GetCustomFieldSchema", "mocked_other_team")
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/dashboard"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+)
+
+// HEADER_CACHE_STATUS tells whether the dashboard came from the cache (RFC
+// 9211).
+const HEADER_CACHE_STATUS = "Cache-Status"
+
+type DashboardHandler struct {
+	repo  repositories.DashboardRepository
+	cache *dashboard.Cache
+}
+
+func NewDashboardHandler(repo repositories.DashboardRepository, cache *dashboard.Cache) *DashboardHandler {
+	return &DashboardHandler{
+		repo:  repo,
+		cache: cache,
+	}
+}
+
+// GetDashboard summarizes the tasks of the team of the manager, Admins see
+// every team or the one of the team parameter. Managers without a team have
+// no dashboard. Dashboards are cached until a
+// task changes, a cache that can't be reached is skipped.
+func (dh *DashboardHandler) GetDashboard(c echo.Context) error {
+
+	// Only Manager and Admin have a dashboard
+	if !auth.IsManager(c) && !auth.IsAdmin(c) {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	team := auth.GetUserTeam(c)
+	switch {
+	case auth.IsAdmin(c):
+		team = c.QueryParam("team")
+	case team == "":
+		// the empty team is every team, managers only see their own
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	now := time.Now()
+	day := now.In(loc).Format(models.DAY_FORMAT)
+	today, _ := models.NamedRange(models.RANGE_TODAY, now, loc)
+	week, _ := models.NamedRange(models.RANGE_THIS_WEEK, now, loc)
+
+	ctx := c.Request().Context()
+	key, err := dh.cache.Key(ctx, team+"|"+loc.String()+"|"+day)
+	if err != nil {
+		log.Println("dashboard cache unavailable: " + err.Error())
+	}
+
+	if key != "" {
+		var response models.DashboardResponse
+		found, err := dh.cache.Get(ctx, key, &response)
+		if err != nil {
+			log.Println("dashboard cache unavailable: " + err.Error())
+		}
+		if found {
+			c.Response().Header().Set(HEADER_CACHE_STATUS, "supervisorapi; hit")
+			return c.JSON(http.StatusOK, response)
+		}
+	}
+
+	summary, err := dh.repo.GetDashboard(team, today, week, day)
+	if err != nil {
+		return err
+	}
+
+	response := summary.ToResponse(team, today, week, loc)
+
+	if key != "" {
+		if err := dh.cache.Set(ctx, key, response); err != nil {
+			log.Println("dashboard not cached: " + err.Error())
+		}
+	}
+
+	c.Response().Header().Set(HEADER_CACHE_STATUS, "supervisorapi; fwd=miss")
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/dashboard"
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockDashboardRepo struct {
+	mock.Mock
+}
+
+var mockedDashboard = models.Dashboard{
+	TasksToday:      2,
+	TasksThisWeek:   9,
+	Overdue:         1,
+	PendingReview:   3,
+	IdleTechnicians: []models.User{mockedUser},
+	RecentActivity:  []models.Task{mockedTask},
+}
+
+func (mr *mockDashboardRepo) GetDashboard(team string, today models.DateRange, week models.DateRange, day string) (models.Dashboard, error) {
+	args := mr.Called(team, today, week, day)
+
+	mockedDashboard := args.Get(0)
+	if mockedDashboard == nil {
+		return models.Dashboard{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.Dashboard), args.Error(1)
+}
+
+func newTestRedisClient(t *testing.T) *redis.Client {
+	mr := miniredis.RunT(t)
+	return redis.NewClient(&redis.Options{Addr: mr.Addr()})
+}
+
+func getDashboard(h *DashboardHandler, role string, target string) *httptest.ResponseRecorder {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = role
+	claims["http://supervisorapi/team"] = "mocked_team"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/dashboard")
+
+	h.GetDashboard(c)
+	return rec
+}
+
+func TestGetDashboardShould200OKFromCacheUntilTasksChange(t *testing.T) {
+
+	rclient := newTestRedisClient(t)
+	cache := dashboard.NewCache(rclient, dashboard.DEFAULT_TTL)
+
+	mr := mockDashboardRepo{}
+	mr.On("GetDashboard", "mocked_team", mock.Anything, mock.Anything, mock.Anything).Return(mockedDashboard, nil)
+	h := NewDashboardHandler(&mr, cache)
+
+	rec := getDashboard(h, "manager", "/dashboard?tz=Europe/Lisbon")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "supervisorapi; fwd=miss", rec.Header().Get(HEADER_CACHE_STATUS))
+
+	var response models.DashboardResponse
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "mocked_team", response.Team)
+	assert.Equal(t, "Europe/Lisbon", response.TimeZone)
+	assert.Equal(t, int64(2), response.TasksToday)
+	assert.Equal(t, int64(9), response.TasksThisWeek)
+	assert.Equal(t, int64(1), response.Overdue)
+	assert.Equal(t, int64(3), response.PendingReview)
+	assert.Equal(t, []models.UserResponse{mockedUser.ToResponse()}, response.IdleTechnicians)
+	if assert.Len(t, response.RecentActivity, 1) {
+		assert.Equal(t, mockedTask.Id, response.RecentActivity[0].TaskId)
+	}
+	assert.NotContains(t, rec.Body.String(), "summary")
+
+	rec = getDashboard(h, "manager", "/dashboard?tz=Europe/Lisbon")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "supervisorapi; hit", rec.Header().Get(HEADER_CACHE_STATUS))
+	mr.AssertNumberOfCalls(t, "GetDashboard", 1)
+
+	// a task event invalidates the dashboards
+	assert.Nil(t, cache.Invalidate(rclient.Context()))
+	rec = getDashboard(h, "manager", "/dashboard?tz=Europe/Lisbon")
+	assert.Equal(t, "supervisorapi; fwd=miss", rec.Header().Get(HEADER_CACHE_STATUS))
+	mr.AssertNumberOfCalls(t, "GetDashboard", 2)
+}
+
+func TestGetDashboardShould200OKWithoutCache(t *testing.T) {
+
+	cache := dashboard.NewCache(createRedisClient(), dashboard.DEFAULT_TTL)
+
+	mr := mockDashboardRepo{}
+	mr.On("GetDashboard", "other_team", mock.Anything, mock.Anything, mock.Anything).Return(mockedDashboard, nil)
+	h := NewDashboardHandler(&mr, cache)
+
+	// Admins choose the team
+	rec := getDashboard(h, "admin", "/dashboard?team=other_team")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestGetDashboardResolvesTodayInTimeZone(t *testing.T) {
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.Nil(t, err)
+	now := time.Now()
+	today, err := models.NamedRange(models.RANGE_TODAY, now, tokyo)
+	assert.Nil(t, err)
+
+	mr := mockDashboardRepo{}
+	mr.On("GetDashboard", "mocked_team", today, mock.Anything, now.In(tokyo).Format(models.DAY_FORMAT)).Return(mockedDashboard, nil)
+	h := NewDashboardHandler(&mr, dashboard.NewCache(newTestRedisClient(t), dashboard.DEFAULT_TTL))
+
+	rec := getDashboard(h, "manager", "/dashboard?tz=Asia/Tokyo")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mr.AssertExpectations(t)
+}
+
+func TestGetDashboardShould401UnauthorizedForTechnician(t *testing.T) {
+
+	mr := mockDashboardRepo{}
+	h := NewDashboardHandler(&mr, dashboard.NewCache(newTestRedisClient(t), dashboard.DEFAULT_TTL))
+
+	rec := getDashboard(h, "technician", "/dashboard")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	mr.AssertNotCalled(t, "GetDashboard", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetDashboardShould401UnauthorizedForManagerWithoutTeam(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/dashboard")
+
+	mr := mockDashboardRepo{}
+	h := NewDashboardHandler(&mr, dashboard.NewCache(newTestRedisClient(t), dashboard.DEFAULT_TTL))
+
+	// Assertions
+	if assert.NoError(t, h.GetDashboard(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		mr.AssertNotCalled(t, "GetDashboard", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	}
+}
+
+func TestUpdateTaskShouldPublishTaskEvent(t *testing.T) {
+	e := echo.New()
+	u, err := json.Marshal(mockedTaskRequest)
+	assert.Nil(t, err)
+	req := httptest.NewRequest(http.MethodPut, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13", strings.NewReader(string(u)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	rclient := newTestRedisClient(t)
+	sub := rclient.Subscribe(rclient.Context(), TASK_EVENTS_CHANNEL)
+	defer sub.Close()
+	_, err = sub.Receive(rclient.Context())
+	assert.Nil(t, err)
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	mr.On("UpdateTask", mockedTask.Id, mock.Anything).Return(mockedTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, rclient)
+
+	// Assertions
+	if assert.NoError(t, h.UpdateTask(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		select {
+		case msg := <-sub.Channel():
+			var event models.TaskEvent
+			assert.Nil(t, json.Unmarshal([]byte(msg.Payload), &event))
+			assert.Equal(t, models.EVENT_TASK_UPDATED, event.Event)
+			assert.Equal(t, mockedTask.Id, event.TaskId)
+			assert.Equal(t, "mocked_worker_id", event.ChangedBy)
+		case <-time.After(time.Second):
+			t.Fatal("task event not published")
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/MrBolas/SupervisorAPI/filter"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/patch"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// ERROR_MAPPINGS are the problems of the errors of the repositories, for the
+// HTTPErrorHandler and for the operations of bulk requests.
+var ERROR_MAPPINGS = []problem.Mapping{
+	problem.Map(gorm.ErrRecordNotFound, http.StatusNotFound, problem.CODE_NOT_FOUND),
+	problem.Map(gorm.ErrRegistered, http.StatusConflict, problem.CODE_CONFLICT),
+	problem.Map(repositories.ErrVersionConflict, http.StatusPreconditionFailed, problem.CODE_PRECONDITION_FAILED),
+	problem.Map(patch.ErrInvalidPatch, http.StatusBadRequest, problem.CODE_INVALID_PATCH),
+}
+
+// validationProblem turns the validation errors of the models into a problem
+// pointing at every invalid field.
+func validationProblem(err error) *problem.Problem {
+
+	if fieldErrors, ok := models.AsFieldErrors(err); ok {
+		return problem.Validation(err.Error(), toProblemFieldErrors(fieldErrors)...)
+	}
+
+	return problem.New(http.StatusBadRequest, problem.CODE_VALIDATION_FAILED, err.Error())
+}
+
+func toProblemFieldErrors(fieldErrors models.FieldErrors) []problem.FieldError {
+	errs := make([]problem.FieldError, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		errs = append(errs, problem.FieldError{
+			Field:   fe.Field,
+			Code:    fe.Code,
+			Message: fe.Error(),
+		})
+	}
+	return errs
+}
+
+// queryProblem is the problem of invalid query parameters.
+func queryProblem(err error) *problem.Problem {
+	return problem.BadRequest(problem.CODE_INVALID_QUERY, err.Error())
+}
+
+// QUERY_FILTER is the query parameter of filter expressions.
+const QUERY_FILTER = "filter"
+
+// filterProblem is the problem of an invalid filter expression, it points at
+// the wrong token of the expression.
+func filterProblem(err error) *problem.Problem {
+
+	var filterErr *filter.Error
+	if !errors.As(err, &filterErr) {
+		return queryProblem(err)
+	}
+
+	return queryProblem(err).WithErrors(problem.FieldError{
+		Field:    QUERY_FILTER,
+		Code:     filterErr.Code,
+		Message:  filterErr.Message,
+		Position: filterErr.Position,
+	})
+}
+
+// writeError writes problems as the response and leaves any other error to
+// the HTTPErrorHandler.
+func writeError(c echo.Context, err error) error {
+
+	var p *problem.Problem
+	if errors.As(err, &p) {
+		return problem.Write(c, p)
+	}
+
+	return err
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/labstack/echo/v4"
+)
+
+const HEADER_ETAG = "ETag"
+const HEADER_IF_MATCH = "If-Match"
+const HEADER_IF_NONE_MATCH = "If-None-Match"
+
+// matchesETag reports whether a conditional header value (a list of entity
+// tags or "*") matches etag. The weak comparison of If-None-Match compares
+// weak validators by their opaque tag. The strong comparison of If-Match
+// never matches weak validators, and matches the tags of every
+// representation of the version, see representationETag.
+func matchesETag(header string, etag string, strong bool) bool {
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if strong {
+			if !strings.HasPrefix(candidate, "W/") && versionETag(candidate) == etag {
+				return true
+			}
+			continue
+		}
+
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// representationETag is the entity tag of a representation of the version
+// tagged etag, representations of the same version differ by their variant.
+func representationETag(etag string, variant string) string {
+	if variant == "" {
+		return etag
+	}
+	return strings.TrimSuffix(etag, "\"") + "-" + variant + "\""
+}
+
+// versionETag is the tag of the version of a representation tag.
+func versionETag(etag string) string {
+	if i := strings.Index(etag, "-"); i >= 0 {
+		return etag[:i] + "\""
+	}
+	return etag
+}
+
+// variant tells apart the representations of a task, by fields, includes,
+// summary format and time zone. The default representation has none. The
+// embedded assignee, nil when the worker has no profile, changes without the
+// task, so its version, the last update of its profile, is part of it.
+func (v taskView) variant(format string, loc *time.Location, assignee *models.User) string {
+
+	if v.fields == nil && v.include == nil && format == FORMAT_MARKDOWN && (loc == nil || loc.String() == "UTC") {
+		return ""
+	}
+
+	h := sha256.New()
+	h.Write([]byte(strings.Join(v.fields, ",")))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(v.include, ",")))
+	h.Write([]byte{0})
+	h.Write([]byte(format))
+	h.Write([]byte{0})
+	if loc != nil {
+		h.Write([]byte(loc.String()))
+	}
+	if assignee != nil {
+		h.Write([]byte{0})
+		h.Write([]byte(assignee.Id))
+		h.Write([]byte{0})
+		h.Write([]byte(assignee.UpdatedAt.UTC().Format(time.RFC3339Nano)))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// ifMatchFails reports whether the request carries an If-Match header that
+// does not match etag.
+func ifMatchFails(c echo.Context, etag string) bool {
+
+	header := c.Request().Header.Get(HEADER_IF_MATCH)
+	if header == "" {
+		return false
+	}
+
+	return !matchesETag(header, etag, true)
+}
+
+// ifNoneMatchHits reports whether the request carries an If-None-Match header
+// that matches etag.
+func ifNoneMatchHits(c echo.Context, etag string) bool {
+
+	header := c.Request().Header.Get(HEADER_IF_NONE_MATCH)
+	if header == "" {
+		return false
+	}
+
+	return matchesETag(header, etag, false)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesETag(t *testing.T) {
+	assert.True(t, matchesETag("\"1\"", "\"1\"", false))
+	assert.True(t, matchesETag("*", "\"1\"", false))
+	assert.True(t, matchesETag("\"3\", \"1\"", "\"1\"", false))
+	assert.True(t, matchesETag("W/\"1\"", "\"1\"", false))
+	assert.False(t, matchesETag("\"2\"", "\"1\"", false))
+	assert.False(t, matchesETag("1", "\"1\"", false))
+	assert.False(t, matchesETag("\"1-abc\"", "\"1\"", false))
+}
+
+func TestMatchesETagStrongly(t *testing.T) {
+	assert.True(t, matchesETag("\"1\"", "\"1\"", true))
+	assert.True(t, matchesETag("*", "\"1\"", true))
+	assert.False(t, matchesETag("W/\"1\"", "\"1\"", true))
+	assert.False(t, matchesETag("\"2\"", "\"1\"", true))
+
+	// any representation of the version
+	assert.True(t, matchesETag("\"1-abc\"", "\"1\"", true))
+	assert.False(t, matchesETag("\"2-abc\"", "\"1\"", true))
+	assert.False(t, matchesETag("W/\"1-abc\"", "\"1\"", true))
+}
+
+func TestRepresentationETag(t *testing.T) {
+	assert.Equal(t, "\"1\"", representationETag("\"1\"", taskView{}.variant(FORMAT_MARKDOWN, time.UTC, nil)))
+
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	assert.Nil(t, err)
+
+	variants := map[string]bool{}
+	for _, variant := range []string{
+		taskView{fields: []string{"summary"}}.variant(FORMAT_MARKDOWN, time.UTC, nil),
+		taskView{include: []string{"worker"}}.variant(FORMAT_MARKDOWN, time.UTC, nil),
+		taskView{}.variant(FORMAT_HTML, time.UTC, nil),
+		taskView{}.variant(FORMAT_MARKDOWN, lisbon, nil),
+	} {
+		assert.NotEqual(t, "", variant)
+		variants[variant] = true
+	}
+	assert.Len(t, variants, 4)
+
+	assert.Equal(t, "\"1-abc\"", representationETag("\"1\"", "abc"))
+}
+
+func TestRepresentationETagFollowsTheAssignee(t *testing.T) {
+	view := taskView{include: []string{INCLUDE_ASSIGNEE}}
+	assignee := models.User{Id: "mocked_worker_id", UpdatedAt: time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)}
+
+	before := view.variant(FORMAT_MARKDOWN, time.UTC, &assignee)
+	assert.Equal(t, before, view.variant(FORMAT_MARKDOWN, time.UTC, &assignee))
+	assert.NotEqual(t, view.variant(FORMAT_MARKDOWN, time.UTC, nil), before)
+
+	// a nickname change updates the profile, not the task
+	assignee.Nickname = "renamed"
+	assignee.UpdatedAt = assignee.UpdatedAt.Add(time.Second)
+	assert.NotEqual(t, before, view.variant(FORMAT_MARKDOWN, time.UTC, &assignee))
+}
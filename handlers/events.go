@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// TASK_EVENTS_CHANNEL is the Redis channel of the changes of the tasks, the
+// notifications channel only has the new tasks.
+const TASK_EVENTS_CHANNEL = "task_events"
+
+// BULK_EVENTS are the task events of the bulk operations.
+var BULK_EVENTS = map[string]string{
+	models.BULK_CREATE: models.EVENT_TASK_CREATED,
+	models.BULK_UPDATE: models.EVENT_TASK_UPDATED,
+	models.BULK_DELETE: models.EVENT_TASK_DELETED,
+}
+
+// publishTaskEvent publishes the change of a task once it is committed.
+// Events are best effort, a failure doesn't fail the request.
+func (th *TasksHandler) publishTaskEvent(c echo.Context, event string, taskId uuid.UUID) {
+	th.publishEvent(c.Request().Context(), event, taskId, auth.GetUserId(c))
+}
+
+// publishEvent publishes the change of a task outside of a request, such as
+// in background jobs.
+func (th *TasksHandler) publishEvent(ctx context.Context, event string, taskId uuid.UUID, changedBy string) {
+
+	msg, err := json.Marshal(models.NewTaskEvent(event, taskId, changedBy))
+	if err != nil {
+		log.Println("task event not published: " + err.Error())
+		return
+	}
+
+	err = th.rclient.Publish(ctx, TASK_EVENTS_CHANNEL, msg).Err()
+	if err != nil {
+		log.Println("task event not published: " + err.Error())
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+)
+
+// QUERY_EXPORT_FORMAT is the query parameter clients use to choose the file
+// format of exports, csv or ndjson. It shares its name with QUERY_FORMAT,
+// exports have no summary representation to choose.
+const QUERY_EXPORT_FORMAT = "format"
+
+const EXPORT_CSV = "csv"
+const EXPORT_NDJSON = "ndjson"
+
+const MIME_TEXT_CSV = "text/csv"
+const MIME_NDJSON = "application/x-ndjson"
+
+// EXPORT_BATCH_SIZE is the number of tasks read, decrypted and written at a
+// time by exports.
+const EXPORT_BATCH_SIZE = 500
+
+var errInvalidExportFormat = errors.New("format must be csv or ndjson")
+
+// exportFormat resolves the format of an export. It is the format query
+// parameter, or ndjson when the Accept header lists it, or csv.
+func exportFormat(c echo.Context) (string, error) {
+
+	switch format := c.QueryParam(QUERY_EXPORT_FORMAT); format {
+	case EXPORT_CSV, EXPORT_NDJSON:
+		return format, nil
+	case "":
+	default:
+		return "", errInvalidExportFormat
+	}
+
+	for _, accepted := range strings.Split(c.Request().Header.Get(echo.HeaderAccept), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == MIME_NDJSON {
+			return EXPORT_NDJSON, nil
+		}
+	}
+
+	return EXPORT_CSV, nil
+}
+
+// GetTaskExport streams every task of the task list, with the same filters,
+// sort and saved views, as CSV or NDJSON. Tasks are read and decrypted in
+// batches, so exports aren't limited by the page size. Once the response
+// started, errors abort the connection, so the download fails instead of
+// looking complete.
+func (th *TasksHandler) GetTaskExport(c echo.Context) error {
+
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	format, err := exportFormat(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	err = th.applyView(c, loc)
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	// exports read the whole list, pagination doesn't apply
+	params := url.Values{}
+	for key, values := range c.QueryParams() {
+		if key != "page" && key != "page_size" && key != "cursor" {
+			params[key] = values
+		}
+	}
+
+	query, err := buildTaskListQuery(params, auth.IsManager(c), loc, th.index)
+	if err != nil {
+		return problem.Write(c, filterProblem(err))
+	}
+
+	// auth
+	if !auth.IsManager(c) {
+		query.Filters["worker_id"] = auth.GetUserId(c)
+	}
+
+	query.StartBatches(EXPORT_BATCH_SIZE)
+
+	// the first batch is read before the response starts, so its errors
+	// still get a status
+	tasks, err := th.repo.ListTasks(query)
+	if err != nil {
+		return err
+	}
+
+	w := newExportWriter(c.Response(), format, loc)
+
+	filename := fmt.Sprintf("tasks-%s.%s", time.Now().In(loc).Format(models.DAY_FORMAT), format)
+	c.Response().Header().Set(echo.HeaderContentType, w.contentType())
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	c.Response().WriteHeader(http.StatusOK)
+
+	// the status is sent, an error can only cut the download short
+	if err := th.streamExport(c, w, query, tasks); err != nil {
+		log.Printf("%s %s: export aborted: %v", c.Request().Method, c.Request().URL.Path, err)
+		panic(http.ErrAbortHandler)
+	}
+
+	return nil
+}
+
+// streamExport writes the tasks of the query, from the first batch read.
+func (th *TasksHandler) streamExport(c echo.Context, w *exportWriter, query repositories.ListQuery, tasks []models.Task) error {
+
+	if err := w.header(); err != nil {
+		return err
+	}
+
+	for {
+		batch, more := query.NextBatch(tasks)
+
+		for _, task := range batch {
+			task.Summary = th.ce.Decrypt(task.Summary)
+			if err := w.write(task); err != nil {
+				return err
+			}
+		}
+
+		if err := w.flush(); err != nil {
+			return err
+		}
+		c.Response().Flush()
+
+		if !more {
+			return nil
+		}
+
+		var err error
+		tasks, err = th.repo.ListTasks(query)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// exportWriter writes the tasks of an export in its format.
+type exportWriter struct {
+	format string
+	loc    *time.Location
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+func newExportWriter(w *echo.Response, format string, loc *time.Location) *exportWriter {
+
+	if format == EXPORT_NDJSON {
+		return &exportWriter{format: format, loc: loc, json: json.NewEncoder(w)}
+	}
+
+	return &exportWriter{format: format, loc: loc, csv: csv.NewWriter(w)}
+}
+
+func (ew *exportWriter) contentType() string {
+	if ew.format == EXPORT_NDJSON {
+		return MIME_NDJSON
+	}
+	return MIME_TEXT_CSV + "; charset=utf-8"
+}
+
+// header writes the header row of CSV exports, NDJSON has none.
+func (ew *exportWriter) header() error {
+	if ew.csv == nil {
+		return nil
+	}
+	return ew.csv.Write(models.EXPORT_COLUMNS)
+}
+
+// write writes a task with a decrypted summary. NDJSON lines are the task
+// responses, with markdown summaries.
+func (ew *exportWriter) write(task models.Task) error {
+	if ew.csv == nil {
+		return ew.json.Encode(task.ToResponseIn(ew.loc))
+	}
+	return ew.csv.Write(task.ExportRecord(ew.loc))
+}
+
+func (ew *exportWriter) flush() error {
+	if ew.csv == nil {
+		return nil
+	}
+	ew.csv.Flush()
+	return ew.csv.Error()
+}
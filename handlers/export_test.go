@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetTaskExportShouldStreamCSVInBatches(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/export?range=last_month&page_size=100&page=3", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/export")
+
+	// a full batch with the extra row, then the last task
+	firstBatch := make([]models.Task, 0, EXPORT_BATCH_SIZE+1)
+	for i := 0; i <= EXPORT_BATCH_SIZE; i++ {
+		firstBatch = append(firstBatch, mockedTask)
+	}
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("ListTasks", mock.MatchedBy(func(query repositories.ListQuery) bool {
+		return query.Pagination.Cursor == nil && query.Pagination.PageSize == EXPORT_BATCH_SIZE && !query.DateRange().After.IsZero()
+	})).Return(firstBatch, nil).Once()
+	mr.On("ListTasks", mock.MatchedBy(func(query repositories.ListQuery) bool {
+		return query.Pagination.Cursor != nil && query.Pagination.Cursor.Id == mockedTask.Id
+	})).Return([]models.Task{mockedTask}, nil).Once()
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskExport(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		assert.Contains(t, rec.Header().Get(echo.HeaderContentDisposition), ".csv")
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		assert.Nil(t, err)
+		if assert.Len(t, records, EXPORT_BATCH_SIZE+2) {
+			assert.Equal(t, models.EXPORT_COLUMNS, records[0])
+			decrypted := mockedTask
+			decrypted.Summary = ce.Decrypt(mockedTask.Summary)
+			assert.Equal(t, decrypted.ExportRecord(nil), records[1])
+		}
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestGetTaskExportShouldAbortWhenABatchFails(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/export?format=ndjson", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/export")
+
+	firstBatch := make([]models.Task, 0, EXPORT_BATCH_SIZE+1)
+	for i := 0; i <= EXPORT_BATCH_SIZE; i++ {
+		firstBatch = append(firstBatch, mockedTask)
+	}
+
+	mr := mockRepo{}
+	mr.On("ListTasks", mock.MatchedBy(func(query repositories.ListQuery) bool {
+		return query.Pagination.Cursor == nil
+	})).Return(firstBatch, nil).Once()
+	mr.On("ListTasks", mock.MatchedBy(func(query repositories.ListQuery) bool {
+		return query.Pagination.Cursor != nil
+	})).Return([]models.Task{}, errors.New("connection lost")).Once()
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk"), testBlindIndex, createRedisClient())
+
+	// Assertions
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.GetTaskExport(c)
+	})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, EXPORT_BATCH_SIZE, strings.Count(rec.Body.String(), "\n"))
+	mr.AssertExpectations(t)
+}
+
+func TestGetTaskExportShouldStreamNDJSONOfOwnTasksOfTechnician(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/export?worker_id=someone_else", nil)
+	req.Header.Set(echo.HeaderAccept, "application/x-ndjson")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/export")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("ListTasks", mock.MatchedBy(func(query repositories.ListQuery) bool {
+		return query.Filters["worker_id"] == "mocked_worker_id"
+	})).Return([]models.Task{mockedTask, mockedTask}, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskExport(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, MIME_NDJSON, rec.Header().Get(echo.HeaderContentType))
+
+		lines := 0
+		scanner := bufio.NewScanner(strings.NewReader(rec.Body.String()))
+		for scanner.Scan() {
+			var task models.TaskResponse
+			assert.Nil(t, json.Unmarshal(scanner.Bytes(), &task))
+			assert.Equal(t, mockedTask.Id, task.Id)
+			assert.Equal(t, ce.Decrypt(mockedTask.Summary), task.Summary)
+			lines++
+		}
+		assert.Equal(t, 2, lines)
+		mr.AssertNumberOfCalls(t, "ListTasks", 1)
+	}
+}
+
+func TestGetTaskExportShould400BadRequestWhenFormatIsInvalid(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/export?format=xlsx", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/export")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskExport(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_INVALID_QUERY, "format must be csv or ndjson")
+		mr.AssertNotCalled(t, "ListTasks", mock.Anything)
+	}
+}
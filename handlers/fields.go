@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/labstack/echo/v4"
+)
+
+// QUERY_FIELDS is the query parameter clients use to limit the fields of the
+// tasks in responses.
+const QUERY_FIELDS = "fields"
+
+// QUERY_INCLUDE is the query parameter clients use to embed related resources
+// in the tasks of responses.
+const QUERY_INCLUDE = "include"
+
+const INCLUDE_ASSIGNEE = "assignee"
+
+// INCLUDES are the related resources that can be included.
+var INCLUDES = []string{INCLUDE_ASSIGNEE}
+
+// taskView is what a request wants to see of tasks. No fields means all of
+// them.
+type taskView struct {
+	fields  []string
+	include []string
+}
+
+// parseTaskView reads the fields and include query parameters, both are
+// lists separated by commas.
+func parseTaskView(params url.Values) (taskView, error) {
+
+	var view taskView
+
+	if param := params.Get(QUERY_FIELDS); param != "" {
+		for _, field := range strings.Split(param, ",") {
+			field = strings.TrimSpace(field)
+			if !contains(models.TASK_RESPONSE_FIELDS, field) {
+				return taskView{}, fmt.Errorf("unknown field %q in fields, use %s", field, strings.Join(models.TASK_RESPONSE_FIELDS, ", "))
+			}
+			view.fields = append(view.fields, field)
+		}
+	}
+
+	if param := params.Get(QUERY_INCLUDE); param != "" {
+		for _, include := range strings.Split(param, ",") {
+			include = strings.TrimSpace(include)
+			if !contains(INCLUDES, include) {
+				return taskView{}, fmt.Errorf("unknown include %q, use %s", include, strings.Join(INCLUDES, ", "))
+			}
+			view.include = append(view.include, include)
+		}
+	}
+
+	return view, nil
+}
+
+// wants tells whether the field is part of the response.
+func (v taskView) wants(field string) bool {
+	return v.fields == nil || contains(v.fields, field)
+}
+
+// isSparse tells whether only some fields are wanted.
+func (v taskView) isSparse() bool {
+	return v.fields != nil
+}
+
+func (v taskView) includes(include string) bool {
+	return contains(v.include, include)
+}
+
+// assignees reads the users of the workers by id, workers without a profile
+// are left out.
+func (th *TasksHandler) assignees(workerIds []string) (map[string]models.User, error) {
+
+	ids := make([]string, 0, len(workerIds))
+	for _, id := range workerIds {
+		if id != "" && !contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	users, err := th.users.GetUsersByIds(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[string]models.User, len(users))
+	for _, user := range users {
+		byId[user.Id] = user
+	}
+
+	return byId, nil
+}
+
+// addAssignees embeds the profile of the worker of each task, tasks of
+// workers without a profile are left without one.
+func (th *TasksHandler) addAssignees(tasks []models.TaskResponse) error {
+
+	workerIds := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		workerIds = append(workerIds, task.WorkerId)
+	}
+
+	users, err := th.assignees(workerIds)
+	if err != nil {
+		return err
+	}
+
+	for i := range tasks {
+		if user, ok := users[tasks[i].WorkerId]; ok {
+			profile := user.ToResponse()
+			tasks[i].Assignee = &profile
+		}
+	}
+
+	return nil
+}
+
+// writeTask sends a task with the includes and fields of the view.
+func (th *TasksHandler) writeTask(c echo.Context, view taskView, task models.TaskResponse) error {
+
+	if view.includes(INCLUDE_ASSIGNEE) {
+		tasks := []models.TaskResponse{task}
+		if err := th.addAssignees(tasks); err != nil {
+			return err
+		}
+		task = tasks[0]
+	}
+
+	return writeTaskFields(c, view, task)
+}
+
+// writeTaskFields sends a task with the fields of the view, its includes are
+// embedded already.
+func writeTaskFields(c echo.Context, view taskView, task models.TaskResponse) error {
+
+	if view.isSparse() {
+		return c.JSON(http.StatusOK, task.Sparse(view.fields))
+	}
+
+	return c.JSON(http.StatusOK, task)
+}
+
+// writeTaskList sends a list of tasks with the includes and fields of the
+// view.
+func (th *TasksHandler) writeTaskList(c echo.Context, view taskView, list models.TaskListResponse) error {
+
+	if view.includes(INCLUDE_ASSIGNEE) {
+		if err := th.addAssignees(list.Data); err != nil {
+			return err
+		}
+	}
+
+	if view.isSparse() {
+		return c.JSON(http.StatusOK, list.Sparse(view.fields))
+	}
+
+	return c.JSON(http.StatusOK, list)
+}
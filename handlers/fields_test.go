@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetTaskListShould200OKWithSparseFields(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks?fields=worker_name,date&format=html", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	// the summary isn't decrypted, so it doesn't have to be valid
+	task := mockedTask
+	task.Summary = "not encrypted"
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("ListTasks", mock.Anything).Return([]models.Task{task}, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, []map[string]interface{}{{
+			"id":          mockedTask.Id.String(),
+			"worker_name": "mocked_worker_name",
+			"date":        "2020-04-15T10:50:00Z",
+		}}, response.Data)
+		assert.Contains(t, rec.Body.String(), `"metadata"`)
+	}
+}
+
+func TestGetTaskListShould200OKIncludingAssignees(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks?include=assignee&fields=summary", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	legacy := mockedTask
+	legacy.WorkerId = "legacy_worker"
+
+	mr := mockRepo{}
+	ur := mockUsersRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("ListTasks", mock.Anything).Return([]models.Task{mockedTask, mockedTask, legacy}, nil)
+	ur.On("GetUsersByIds", []string{"mocked_worker_id", "legacy_worker"}).Return([]models.User{mockedUser}, nil)
+	h := NewTasksHandler(&mr, &ur, noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.TaskListResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		if assert.Len(t, response.Data, 3) {
+			assignee := mockedUser.ToResponse()
+			assert.Equal(t, &assignee, response.Data[0].Assignee)
+			assert.Equal(t, &assignee, response.Data[1].Assignee)
+			assert.Nil(t, response.Data[2].Assignee)
+			assert.Equal(t, ce.Decrypt(mockedTask.Summary), response.Data[0].Summary)
+		}
+		ur.AssertNumberOfCalls(t, "GetUsersByIds", 1)
+	}
+}
+
+func TestGetTaskByIdShould200OKWithSparseFieldsAndAssignee(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13?fields=version&include=assignee", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ur := mockUsersRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	ur.On("GetUsersByIds", []string{"mocked_worker_id"}).Return([]models.User{mockedUser}, nil)
+	h := NewTasksHandler(&mr, &ur, noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	assignee := mockedUser.ToResponse()
+	u, err := json.Marshal(map[string]interface{}{"id": mockedTask.Id, "version": 1, "assignee": assignee})
+	assert.Nil(t, err)
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskById(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(u)+"\n", rec.Body.String())
+	}
+}
+
+func TestGetTaskByIdShould200OKWithAssigneeChangedSinceTheTag(t *testing.T) {
+
+	get := func(assignee models.User, ifNoneMatch string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13?include=assignee", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set(HEADER_IF_NONE_MATCH, ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		claims := make(map[string]string, 0)
+		claims["http://supervisorapi/role"] = "manager"
+		addClaimsToJWTContext(c, claims)
+
+		c.SetPath("/tasks/:id")
+		c.SetParamNames("id")
+		c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+		mr := mockRepo{}
+		ur := mockUsersRepo{}
+		mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+		ur.On("GetUsersByIds", []string{"mocked_worker_id"}).Return([]models.User{assignee}, nil).Once()
+		h := NewTasksHandler(&mr, &ur, noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk"), testBlindIndex, createRedisClient())
+
+		assert.NoError(t, h.GetTaskById(c))
+		ur.AssertExpectations(t)
+		return rec
+	}
+
+	assignee := mockedUser
+	first := get(assignee, "")
+	etag := first.Header().Get(HEADER_ETAG)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, http.StatusNotModified, get(assignee, etag).Code)
+
+	// the task keeps its version
+	assignee.Nickname = "renamed"
+	assignee.UpdatedAt = assignee.UpdatedAt.Add(time.Minute)
+	renamed := get(assignee, etag)
+	if assert.Equal(t, http.StatusOK, renamed.Code) {
+		assert.NotEqual(t, etag, renamed.Header().Get(HEADER_ETAG))
+		assert.Contains(t, renamed.Body.String(), "renamed")
+	}
+}
+
+func TestGetTaskListShould400BadRequestWhenFieldsAreUnknown(t *testing.T) {
+
+	cases := map[string]string{
+		"/tasks?fields=summary,secret": `unknown field "secret" in fields, use id, worker_id, worker_name, summary, date, custom, version`,
+		"/tasks?include=comments":      `unknown include "comments", use assignee`,
+	}
+
+	for target, detail := range cases {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		claims := make(map[string]string, 0)
+		claims["http://supervisorapi/role"] = "manager"
+		claims["sub"] = "mocked_manager_id"
+		addClaimsToJWTContext(c, claims)
+
+		c.SetPath("/tasks")
+
+		mr := mockRepo{}
+		ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+		h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+		// Assertions
+		if assert.NoError(t, h.GetTaskList(c)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assertProblem(t, rec, problem.CODE_INVALID_QUERY, detail)
+		}
+	}
+}
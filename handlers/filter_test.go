@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/filter"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetTaskListShould200OKWithFilter(t *testing.T) {
+	e := echo.New()
+	query := url.Values{QUERY_FILTER: {"custom.status eq 'done' and date ge 2024-01-01"}}
+	req := httptest.NewRequest(http.MethodGet, "/tasks?"+query.Encode(), nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("ListTasks", mock.MatchedBy(func(q repositories.ListQuery) bool {
+		and, ok := q.Filter.(filter.And)
+		return ok && and.Left.(filter.Comparison).Field == "custom.status"
+	})).Return([]models.Task{mockedTask}, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestGetTaskListShould400BadRequestPointingAtTheFilterToken(t *testing.T) {
+	e := echo.New()
+	query := url.Values{QUERY_FILTER: {"worker_name eq 'ana' and status eq 'done'"}}
+	req := httptest.NewRequest(http.MethodGet, "/tasks?"+query.Encode(), nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_INVALID_QUERY, `filter: unknown field at position 26 near "status"`)
+
+		var p problem.Problem
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &p))
+		assert.Equal(t, []problem.FieldError{{
+			Field:    QUERY_FILTER,
+			Code:     filter.CODE_UNKNOWN_FIELD,
+			Message:  "unknown field",
+			Position: 26,
+		}}, p.Errors)
+		mr.AssertNotCalled(t, "ListTasks", mock.Anything)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/labstack/echo/v4"
+)
+
+// QUERY_FORMAT is the query parameter clients use to choose the
+// representation of the summaries in responses. Exports read the same name
+// as QUERY_EXPORT_FORMAT, their file format.
+const QUERY_FORMAT = "format"
+
+const FORMAT_MARKDOWN = "markdown"
+const FORMAT_HTML = "html"
+
+const MIME_TEXT_HTML = "text/html"
+
+var errInvalidFormat = errors.New("format must be markdown or html")
+
+// summaryFormat resolves the representation of the summaries of a request.
+// It is the format query parameter, or html when the Accept header lists
+// text/html, or markdown. Summaries are always sent as markdown, html adds
+// their rendered and sanitized version.
+func summaryFormat(c echo.Context) (string, error) {
+
+	switch format := c.QueryParam(QUERY_FORMAT); format {
+	case FORMAT_MARKDOWN, FORMAT_HTML:
+		return format, nil
+	case "":
+	default:
+		return "", errInvalidFormat
+	}
+
+	for _, accepted := range strings.Split(c.Request().Header.Get(echo.HeaderAccept), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == MIME_TEXT_HTML {
+			return FORMAT_HTML, nil
+		}
+	}
+
+	return FORMAT_MARKDOWN, nil
+}
+
+// taskResponse builds the response of a task in the time zone and summary
+// format of the request.
+func taskResponse(task models.Task, loc *time.Location, format string) models.TaskResponse {
+
+	response := task.ToResponseIn(loc)
+	if format == FORMAT_HTML {
+		response.RenderSummaryHTML()
+	}
+
+	return response
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSummaryFormatPrefersQueryThenAccept(t *testing.T) {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks?format=markdown", nil)
+	req.Header.Set(echo.HeaderAccept, "text/html")
+	format, err := summaryFormat(e.NewContext(req, httptest.NewRecorder()))
+	assert.Nil(t, err)
+	assert.Equal(t, FORMAT_MARKDOWN, format)
+
+	req = httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	req.Header.Set(echo.HeaderAccept, "application/json, text/html;q=0.5")
+	format, err = summaryFormat(e.NewContext(req, httptest.NewRecorder()))
+	assert.Nil(t, err)
+	assert.Equal(t, FORMAT_HTML, format)
+
+	req = httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	req.Header.Set(echo.HeaderAccept, "application/json")
+	format, err = summaryFormat(e.NewContext(req, httptest.NewRecorder()))
+	assert.Nil(t, err)
+	assert.Equal(t, FORMAT_MARKDOWN, format)
+
+	req = httptest.NewRequest(http.MethodGet, "/tasks?format=pdf", nil)
+	_, err = summaryFormat(e.NewContext(req, httptest.NewRecorder()))
+	assert.Equal(t, errInvalidFormat, err)
+}
+
+func TestGetTaskByIdShould200OKWithSummaryHTML(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/?format=html", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	task := mockedTask
+	task.Summary = ce.Encrypt("**Pump** replaced <br>")
+
+	mr := mockRepo{}
+	mr.On("GetTaskById", mock.Anything).Return(task, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskById(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.TaskResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "**Pump** replaced <br>", response.Summary)
+		assert.Equal(t, "<p><strong>Pump</strong> replaced &lt;br&gt;</p>\n", response.SummaryHTML)
+	}
+}
+
+func TestGetTaskByIdShould400BadRequestWhenFormatIsInvalid(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/?format=pdf", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskById(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_INVALID_QUERY, errInvalidFormat.Error())
+	}
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/importer"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const QUERY_DRY_RUN = "dry_run"
+const QUERY_MAP = "map"
+const QUERY_RESUME = "resume"
+
+// MAX_IMPORT_BYTES is the largest file that can be imported.
+const MAX_IMPORT_BYTES = 64 << 20
+
+// IMPORT_SYNC_BYTES is the largest file imported during the request, larger
+// files are imported by a background job.
+const IMPORT_SYNC_BYTES = 256 << 10
+
+// IMPORT_JOBS_PATH is the path of the import jobs, for their Location.
+const IMPORT_JOBS_PATH = "/v1/tasks/import/"
+
+var errInvalidDryRun = errors.New("dry_run must be true or false")
+var errInvalidResume = errors.New("resume must be the id of an import job")
+var errResumeDryRun = errors.New("dry runs can't resume an import job")
+
+// ImportTasks creates tasks from the rows of a CSV file, see importer.Import.
+// The file is kept in a temporary file so large imports can outlive the
+// request, they answer 202 with the job tracking them. With resume, the file
+// of a failed job is imported from where the job stopped.
+func (th *TasksHandler) ImportTasks(c echo.Context) error {
+
+	// Only Manager can import
+	if !auth.IsManager(c) {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != MIME_TEXT_CSV {
+		return problem.Write(c, problem.New(http.StatusUnsupportedMediaType, problem.CODE_UNSUPPORTED_MEDIA_TYPE, "Content-Type must be "+MIME_TEXT_CSV))
+	}
+
+	dryRun := false
+	if param := c.QueryParam(QUERY_DRY_RUN); param != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(param)
+		if err != nil {
+			return problem.Write(c, queryProblem(errInvalidDryRun))
+		}
+	}
+
+	mapping, err := importer.ParseMapping(c.QueryParams()[QUERY_MAP])
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	opts, err := th.importOptions(c, loc, dryRun)
+	if err != nil {
+		return err
+	}
+
+	jobs := importer.NewJobs(th.rclient, importer.JOB_TTL)
+
+	var resumed importer.Job
+	if param := c.QueryParam(QUERY_RESUME); param != "" {
+		id, err := uuid.FromString(param)
+		if err != nil {
+			return problem.Write(c, queryProblem(errInvalidResume))
+		}
+		if dryRun {
+			return problem.Write(c, queryProblem(errResumeDryRun))
+		}
+
+		resumed, err = jobs.Get(c.Request().Context(), id)
+		if err == importer.ErrJobNotFound || (err == nil && resumed.CreatedBy != auth.GetUserId(c)) {
+			return problem.Write(c, problem.NotFound("import job not found"))
+		}
+		if err != nil {
+			return err
+		}
+		if resumed.Status != importer.JOB_FAILED || resumed.Report.DryRun {
+			return problem.Write(c, problem.Conflict(importer.ErrJobNotResumable.Error()))
+		}
+		opts.Resume = &resumed.Report
+	}
+
+	file, size, digest, err := spoolImport(c.Request().Body)
+	if err != nil {
+		return err
+	}
+	if size > MAX_IMPORT_BYTES {
+		removeImport(file)
+		return problem.Write(c, problem.New(http.StatusRequestEntityTooLarge, problem.CODE_TOO_LARGE, fmt.Sprintf("files are imported up to %d MiB", MAX_IMPORT_BYTES>>20)))
+	}
+	if opts.Resume != nil && digest != resumed.Digest {
+		removeImport(file)
+		return problem.Write(c, problem.Validation("the file isn't the one of the resumed import job"))
+	}
+
+	// a wrong header fails the whole file, before any job starts
+	if _, err := importer.NewReader(file, mapping); err != nil {
+		removeImport(file)
+		return problem.Write(c, problem.Validation(err.Error()))
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		removeImport(file)
+		return err
+	}
+
+	// resumed imports run as jobs, so they can be resumed in turn
+	if size <= IMPORT_SYNC_BYTES && opts.Resume == nil {
+		defer removeImport(file)
+
+		report, err := th.runImport(c.Request().Context(), file, mapping, opts, nil)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, report)
+	}
+
+	start := importer.Report{DryRun: dryRun, Errors: make([]importer.LineError, 0)}
+	if opts.Resume != nil {
+		start = *opts.Resume
+	}
+
+	job, err := jobs.Create(c.Request().Context(), auth.GetUserId(c), digest, start)
+	if err != nil {
+		removeImport(file)
+		return err
+	}
+
+	// a failed job is resumed once, by the first import that claims it
+	if opts.Resume != nil {
+		_, err := jobs.Claim(c.Request().Context(), resumed.Id, job.Id)
+		if err != nil {
+			removeImport(file)
+			jobs.Delete(c.Request().Context(), job.Id)
+		}
+		if err == importer.ErrJobNotResumable {
+			return problem.Write(c, problem.Conflict(err.Error()))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	go th.runImportJob(jobs, job, file, mapping, opts)
+
+	c.Response().Header().Set(echo.HeaderLocation, IMPORT_JOBS_PATH+job.Id.String())
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// GetImportJob reports the progress of an import job to the user who started
+// it, or to admins.
+func (th *TasksHandler) GetImportJob(c echo.Context) error {
+
+	if !auth.IsManager(c) && !auth.IsAdmin(c) {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return problem.Write(c, problem.InvalidId("invalid import job id"))
+	}
+
+	job, err := importer.NewJobs(th.rclient, importer.JOB_TTL).Get(c.Request().Context(), id)
+	if err == importer.ErrJobNotFound || (err == nil && job.CreatedBy != auth.GetUserId(c) && !auth.IsAdmin(c)) {
+		return problem.Write(c, problem.NotFound("import job not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// importOptions are the limits, custom fields schema and worker of the team
+// of the user.
+func (th *TasksHandler) importOptions(c echo.Context, loc *time.Location, dryRun bool) (importer.Options, error) {
+
+	limits, err := teamLimits(th.limits, c)
+	if err != nil {
+		return importer.Options{}, err
+	}
+
+	schema, err := th.schemas.GetCustomFieldSchema(auth.GetUserTeam(c))
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return importer.Options{}, err
+	}
+
+	return importer.Options{
+		DryRun:     dryRun,
+		Limits:     limits,
+		Schema:     schema,
+		Location:   loc,
+		WorkerId:   auth.GetUserId(c),
+		WorkerName: auth.GetUserNickname(c),
+	}, nil
+}
+
+// runImport imports a file and publishes the events of the tasks created,
+// onBatch follows the progress. Imported tasks aren't sent to the queue of
+// new tasks, they are past work.
+func (th *TasksHandler) runImport(ctx context.Context, r io.Reader, mapping importer.Mapping, opts importer.Options, onBatch func(importer.Report)) (importer.Report, error) {
+
+	im := importer.NewImporter(th.repo, th.users, th.ce, th.index)
+
+	return im.Import(r, mapping, opts, func(report importer.Report, tasks []models.Task) {
+		if !opts.DryRun {
+			for _, task := range tasks {
+				th.publishEvent(ctx, models.EVENT_TASK_CREATED, task.Id, opts.WorkerId)
+			}
+		}
+		if onBatch != nil {
+			onBatch(report)
+		}
+	})
+}
+
+// runImportJob imports a file in the background, saving the progress of the
+// job after every batch. The file is removed once imported. A failed job
+// keeps the report of its last batch, what was imported, so it can be
+// resumed without importing rows twice. The import stops when the job no
+// longer runs, it went stale and may have been resumed.
+func (th *TasksHandler) runImportJob(jobs *importer.Jobs, job importer.Job, file *os.File, mapping importer.Mapping, opts importer.Options) {
+
+	defer removeImport(file)
+
+	ctx := context.Background()
+	save := func() {
+		if err := jobs.Update(ctx, job); err != nil {
+			log.Printf("import job %s not saved: %s", job.Id, err.Error())
+		}
+	}
+	fail := func(err error) {
+		job.Status = importer.JOB_FAILED
+		job.Error = err.Error()
+		log.Printf("import job %s failed: %s", job.Id, err.Error())
+		save()
+	}
+
+	// a panic would end the job running forever, and the server with it
+	defer func() {
+		if r := recover(); r != nil {
+			fail(fmt.Errorf("%v", r))
+		}
+	}()
+
+	job.Status = importer.JOB_RUNNING
+	save()
+
+	opts.BeforeBatch = func() error {
+		return jobs.Running(ctx, job.Id)
+	}
+
+	report, err := th.runImport(ctx, file, mapping, opts, func(report importer.Report) {
+		job.Report = report
+		save()
+	})
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	job.Report = report
+	job.Status = importer.JOB_DONE
+	save()
+}
+
+// spoolImport copies the body of an import to a temporary file, up to a byte
+// past MAX_IMPORT_BYTES so larger files are told apart. The digest is the
+// hex SHA-256 of the file.
+func spoolImport(body io.Reader) (*os.File, int64, string, error) {
+
+	file, err := os.CreateTemp("", "import-*.csv")
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(body, MAX_IMPORT_BYTES+1))
+	if err != nil {
+		removeImport(file)
+		return nil, 0, "", err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		removeImport(file)
+		return nil, 0, "", err
+	}
+
+	return file, size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func removeImport(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/importer"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func importRequest(role string, sub string, target string, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, MIME_TEXT_CSV)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = role
+	claims["http://supervisorapi/nickname"] = "maria"
+	claims["sub"] = sub
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/import")
+
+	return c, rec
+}
+
+func TestImportTasksShould200OKWithReport(t *testing.T) {
+	body := "Description,Day\nFixed the pump,2022-05-23 03:33:01PM\nNo date,\n"
+	c, rec := importRequest("manager", "mocked_manager_id", "/tasks/import?map=summary:Description&map=date:Day", body)
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("CreateTasks", mock.MatchedBy(func(tasks []models.Task) bool {
+		return len(tasks) == 1 && tasks[0].WorkerId == "mocked_manager_id" && ce.Decrypt(tasks[0].Summary) == "Fixed the pump"
+	})).Return(nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.ImportTasks(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var report importer.Report
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		assert.Equal(t, 2, report.Rows)
+		assert.Equal(t, 1, report.Imported)
+		assert.Equal(t, 1, report.Failed)
+		if assert.Len(t, report.Errors, 1) {
+			assert.Equal(t, 3, report.Errors[0].Line)
+			assert.Equal(t, "date", report.Errors[0].Errors[0].Field)
+		}
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestImportTasksDryRunShouldNotCreateTasks(t *testing.T) {
+	c, rec := importRequest("manager", "mocked_manager_id", "/tasks/import?dry_run=true", "summary,date\nFixed the pump,2022-05-23T10:00:00Z\n")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.ImportTasks(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"dry_run":true`)
+		assert.Contains(t, rec.Body.String(), `"valid":1`)
+		mr.AssertNotCalled(t, "CreateTasks", mock.Anything)
+	}
+}
+
+func TestImportTasksShould400BadRequestWhenHeaderDoesNotFit(t *testing.T) {
+	c, rec := importRequest("manager", "mocked_manager_id", "/tasks/import?map=date:Day", "summary,date\n")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.ImportTasks(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_VALIDATION_FAILED, `invalid header, column "Day" of date is not in the file`)
+	}
+}
+
+func TestImportTasksShould415WhenNotCSV(t *testing.T) {
+	c, rec := importRequest("manager", "mocked_manager_id", "/tasks/import", "[]")
+	c.Request().Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.ImportTasks(c)) {
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	}
+}
+
+func TestImportTasksShould401WhenTechnician(t *testing.T) {
+	c, rec := importRequest("technician", "mocked_worker_id", "/tasks/import", "summary,date\n")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.ImportTasks(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestImportTasksShould202AcceptedForLargeFiles(t *testing.T) {
+	var body strings.Builder
+	body.WriteString("summary,date\n")
+	for body.Len() <= IMPORT_SYNC_BYTES {
+		body.WriteString("Checked the valves,2022-05-23T10:00:00Z\n")
+	}
+	c, rec := importRequest("manager", "mocked_manager_id", "/tasks/import", body.String())
+
+	rclient := newTestRedisClient(t)
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("CreateTasks", mock.Anything).Return(nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, rclient)
+
+	// Assertions
+	if assert.NoError(t, h.ImportTasks(c)) {
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+
+		var job importer.Job
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &job))
+		assert.Equal(t, IMPORT_JOBS_PATH+job.Id.String(), rec.Header().Get(echo.HeaderLocation))
+
+		job = waitForImportJob(t, rclient, job)
+		assert.Equal(t, importer.JOB_DONE, job.Status)
+		assert.Greater(t, job.Report.Imported, importer.DEFAULT_BATCH_SIZE)
+		assert.Equal(t, job.Report.Rows, job.Report.Imported)
+
+		// only the user who started the job can follow it
+		assert.Equal(t, http.StatusOK, getImportJob(h, "mocked_manager_id", job).Code)
+		assert.Equal(t, http.StatusNotFound, getImportJob(h, "another_manager_id", job).Code)
+	}
+}
+
+func TestImportTasksShouldMarkTheJobFailedWhenItPanics(t *testing.T) {
+	var body strings.Builder
+	body.WriteString("summary,date\n")
+	for body.Len() <= IMPORT_SYNC_BYTES {
+		body.WriteString("Checked the valves,2022-05-23T10:00:00Z\n")
+	}
+	c, rec := importRequest("manager", "mocked_manager_id", "/tasks/import", body.String())
+
+	rclient := newTestRedisClient(t)
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("CreateTasks", mock.Anything).Run(func(args mock.Arguments) {
+		panic("connection pool closed")
+	})
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, rclient)
+
+	// Assertions
+	if assert.NoError(t, h.ImportTasks(c)) {
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+
+		var job importer.Job
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &job))
+
+		job = waitForImportJob(t, rclient, job)
+		assert.Equal(t, importer.JOB_FAILED, job.Status)
+		assert.Equal(t, "connection pool closed", job.Error)
+		assert.Equal(t, 0, job.Report.Imported)
+	}
+}
+
+func TestImportTasksShouldResumeFailedJobs(t *testing.T) {
+	body := "summary,date\nFixed the pump,2022-05-23T10:00:00Z\nChecked the valves,2022-05-23T11:00:00Z\nCleaned the filter,2022-05-23T12:00:00Z\n"
+	sum := sha256.Sum256([]byte(body))
+
+	rclient := newTestRedisClient(t)
+	jobs := importer.NewJobs(rclient, importer.JOB_TTL)
+	ctx := context.Background()
+
+	// the job stopped after importing the first two rows
+	failed, _ := jobs.Create(ctx, "mocked_manager_id", hex.EncodeToString(sum[:]), importer.Report{Rows: 2, Valid: 2, Imported: 2, Errors: make([]importer.LineError, 0)})
+	failed.Status = importer.JOB_FAILED
+	assert.Nil(t, jobs.Save(ctx, failed))
+
+	running, _ := jobs.Create(ctx, "mocked_manager_id", hex.EncodeToString(sum[:]), importer.Report{})
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("CreateTasks", mock.MatchedBy(func(tasks []models.Task) bool {
+		return len(tasks) == 1 && ce.Decrypt(tasks[0].Summary) == "Cleaned the filter"
+	})).Return(nil).Once()
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, rclient)
+
+	tests := []struct {
+		name   string
+		sub    string
+		resume string
+		body   string
+		status int
+	}{
+		{"another file", "mocked_manager_id", failed.Id.String(), body + "Extra,2022-05-24T10:00:00Z\n", http.StatusBadRequest},
+		{"job of another user", "another_manager_id", failed.Id.String(), body, http.StatusNotFound},
+		{"job still running", "mocked_manager_id", running.Id.String(), body, http.StatusConflict},
+		{"invalid id", "mocked_manager_id", "42", body, http.StatusBadRequest},
+		{"same file", "mocked_manager_id", failed.Id.String(), body, http.StatusAccepted},
+		{"job resumed already", "mocked_manager_id", failed.Id.String(), body, http.StatusConflict},
+	}
+
+	var resumedBy importer.Job
+	for _, test := range tests {
+		c, rec := importRequest("manager", test.sub, "/tasks/import?resume="+test.resume, test.body)
+
+		// Assertions
+		if assert.NoError(t, h.ImportTasks(c), test.name) {
+			assert.Equal(t, test.status, rec.Code, test.name)
+		}
+
+		if test.status == http.StatusAccepted {
+			assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resumedBy))
+		}
+	}
+
+	// the rows after the report are imported once
+	job := waitForImportJob(t, rclient, resumedBy)
+	assert.Equal(t, importer.JOB_DONE, job.Status)
+	assert.Equal(t, 3, job.Report.Rows)
+	assert.Equal(t, 3, job.Report.Imported)
+
+	failed, err := jobs.Get(ctx, failed.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, importer.JOB_RESUMED, failed.Status)
+	assert.Equal(t, &resumedBy.Id, failed.ResumedBy)
+
+	mr.AssertExpectations(t)
+	mr.AssertNumberOfCalls(t, "CreateTasks", 1)
+}
+
+func TestImportTasksShouldResumeAFailedJobOnceWhenResumedAtOnce(t *testing.T) {
+	body := "summary,date\nFixed the pump,2022-05-23T10:00:00Z\nChecked the valves,2022-05-23T11:00:00Z\n"
+	sum := sha256.Sum256([]byte(body))
+
+	rclient := newTestRedisClient(t)
+	jobs := importer.NewJobs(rclient, importer.JOB_TTL)
+	ctx := context.Background()
+
+	failed, _ := jobs.Create(ctx, "mocked_manager_id", hex.EncodeToString(sum[:]), importer.Report{Rows: 1, Valid: 1, Imported: 1, Errors: make([]importer.LineError, 0)})
+	failed.Status = importer.JOB_FAILED
+	assert.Nil(t, jobs.Save(ctx, failed))
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("CreateTasks", mock.Anything).Return(nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, rclient)
+
+	const resumes = 5
+	codes := make(chan int, resumes)
+	jobIds := make(chan importer.Job, resumes)
+	var wg sync.WaitGroup
+	for i := 0; i < resumes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, rec := importRequest("manager", "mocked_manager_id", "/tasks/import?resume="+failed.Id.String(), body)
+			h.ImportTasks(c)
+			codes <- rec.Code
+			if rec.Code == http.StatusAccepted {
+				var job importer.Job
+				json.Unmarshal(rec.Body.Bytes(), &job)
+				jobIds <- job
+			}
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	close(jobIds)
+
+	accepted := 0
+	for code := range codes {
+		if code == http.StatusAccepted {
+			accepted++
+		} else {
+			assert.Equal(t, http.StatusConflict, code)
+		}
+	}
+	assert.Equal(t, 1, accepted)
+
+	// Assertions
+	for job := range jobIds {
+		job = waitForImportJob(t, rclient, job)
+		assert.Equal(t, 2, job.Report.Imported)
+	}
+	mr.AssertNumberOfCalls(t, "CreateTasks", 1)
+}
+
+func waitForImportJob(t *testing.T, rclient *redis.Client, job importer.Job) importer.Job {
+	jobs := importer.NewJobs(rclient, importer.JOB_TTL)
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := jobs.Get(context.Background(), job.Id)
+		assert.Nil(t, err)
+		if job.Status == importer.JOB_DONE || job.Status == importer.JOB_FAILED {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("import job did not finish")
+	return job
+}
+
+func getImportJob(h *TasksHandler, sub string, job importer.Job) *httptest.ResponseRecorder {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = sub
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/import/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(job.Id.String())
+
+	h.GetImportJob(c)
+	return rec
+}
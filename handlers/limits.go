@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type LimitsHandler struct {
+	repo repositories.LimitsRepository
+}
+
+func NewLimitsHandler(repo repositories.LimitsRepository) *LimitsHandler {
+	return &LimitsHandler{
+		repo: repo,
+	}
+}
+
+func (lh *LimitsHandler) GetTeamLimits(c echo.Context) error {
+
+	team := c.Param("team")
+
+	// Users can read the limits of their own team
+	if !auth.IsAdmin(c) && !auth.IsManager(c) && auth.GetUserTeam(c) != team {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	limits, err := lh.repo.GetTeamLimits(team)
+	if err == gorm.ErrRecordNotFound {
+		limits = models.TeamLimits{Team: team}
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, limits.ToResponse())
+}
+
+func (lh *LimitsHandler) SaveTeamLimits(c echo.Context) error {
+
+	// Only Admin can change the limits
+	if !auth.IsAdmin(c) {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	var req models.TeamLimitsRequest
+	err := c.Bind(&req)
+	if err != nil {
+		return problem.Write(c, problem.Malformed("malformed request body"))
+	}
+
+	err = req.Validate()
+	if err != nil {
+		return problem.Write(c, validationProblem(err))
+	}
+
+	limits, err := lh.repo.SaveTeamLimits(req.ToTeamLimits(c.Param("team"), auth.GetUserId(c)))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, limits.ToResponse())
+}
+
+// teamLimits returns the validation limits of the team of the user, teams
+// without limits of their own use the defaults.
+func teamLimits(repo repositories.LimitsRepository, c echo.Context) (models.Limits, error) {
+
+	limits, err := repo.GetTeamLimits(auth.GetUserTeam(c))
+	if err == gorm.ErrRecordNotFound {
+		return models.DEFAULT_LIMITS, nil
+	}
+	if err != nil {
+		return models.Limits{}, err
+	}
+
+	return limits.ToLimits(), nil
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type mockLimitsRepo struct {
+	mock.Mock
+}
+
+var mockedTeamLimits = models.TeamLimits{
+	Team:            "mocked_team",
+	MaxSummaryChars: 20,
+	UpdatedBy:       "mocked_admin_id",
+	UpdatedAt:       time.Date(2022, time.May, 23, 15, 33, 1, 0, time.UTC),
+}
+
+func (mr *mockLimitsRepo) GetTeamLimits(team string) (models.TeamLimits, error) {
+	args := mr.Called(team)
+
+	mockedLimits := args.Get(0)
+	if mockedLimits == nil {
+		return models.TeamLimits{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.TeamLimits), args.Error(1)
+}
+
+func (mr *mockLimitsRepo) SaveTeamLimits(limits models.TeamLimits) (models.TeamLimits, error) {
+	args := mr.Called(limits)
+
+	mockedLimits := args.Get(0)
+	if mockedLimits == nil {
+		return models.TeamLimits{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.TeamLimits), args.Error(1)
+}
+
+// noTeamLimitsRepo returns a repository where every team uses the defaults.
+func noTeamLimitsRepo() *mockLimitsRepo {
+	mr := mockLimitsRepo{}
+	mr.On("GetTeamLimits", mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+	return &mr
+}
+
+func TestGetTeamLimitsShould200OKWithDefaults(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "worker"
+	claims["http://supervisorapi/team"] = "mocked_team"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/limits/:team")
+	c.SetParamNames("team")
+	c.SetParamValues("mocked_team")
+
+	h := NewLimitsHandler(noTeamLimitsRepo())
+
+	// Assertions
+	if assert.NoError(t, h.GetTeamLimits(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.TeamLimitsResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "mocked_team", response.Team)
+		assert.Equal(t, models.MAX_SUMMARY_CHARS, response.MaxSummaryChars)
+	}
+}
+
+func TestGetTeamLimitsShould401UnauthorizedForAnotherTeam(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "worker"
+	claims["http://supervisorapi/team"] = "other_team"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/limits/:team")
+	c.SetParamNames("team")
+	c.SetParamValues("mocked_team")
+
+	h := NewLimitsHandler(&mockLimitsRepo{})
+
+	// Assertions
+	if assert.NoError(t, h.GetTeamLimits(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestSaveTeamLimitsShould200OK(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"max_summary_chars":20}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "admin"
+	claims["sub"] = "mocked_admin_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/limits/:team")
+	c.SetParamNames("team")
+	c.SetParamValues("mocked_team")
+
+	mr := mockLimitsRepo{}
+	mr.On("SaveTeamLimits", mock.MatchedBy(func(l models.TeamLimits) bool {
+		return l.Team == "mocked_team" && l.MaxSummaryChars == 20 && l.UpdatedBy == "mocked_admin_id"
+	})).Return(mockedTeamLimits, nil)
+	h := NewLimitsHandler(&mr)
+
+	// Assertions
+	if assert.NoError(t, h.SaveTeamLimits(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestSaveTeamLimitsShould400BadRequestWhenOutOfRange(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"max_summary_chars":0}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "admin"
+	claims["sub"] = "mocked_admin_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/limits/:team")
+	c.SetParamNames("team")
+	c.SetParamValues("mocked_team")
+
+	h := NewLimitsHandler(&mockLimitsRepo{})
+
+	// Assertions
+	if assert.NoError(t, h.SaveTeamLimits(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_VALIDATION_FAILED, "max_summary_chars must be between 1 and 10000")
+	}
+}
+
+func TestSaveTeamLimitsShould401UnauthorizedWhenNotAdmin(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"max_summary_chars":20}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/limits/:team")
+	c.SetParamNames("team")
+	c.SetParamValues("mocked_team")
+
+	h := NewLimitsHandler(&mockLimitsRepo{})
+
+	// Assertions
+	if assert.NoError(t, h.SaveTeamLimits(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestCreateTaskShould400BadRequestWhenSummaryExceedsTeamLimit(t *testing.T) {
+	e := echo.New()
+	u, err := json.Marshal(mockedTaskRequest)
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/task", strings.NewReader(string(u)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "worker"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["http://supervisorapi/team"] = "mocked_team"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	limits := mockedTeamLimits
+	limits.MaxSummaryChars = 4
+	lr := mockLimitsRepo{}
+	lr.On("GetTeamLimits", "mocked_team").Return(limits, nil)
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), &lr, ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.CreateTask(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_VALIDATION_FAILED, "summary max size is 4 characters")
+		mr.AssertNotCalled(t, "CreateTask", mock.Anything)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/labstack/echo/v4"
+)
+
+// QUERY_TOTAL_COUNT is the query parameter clients use to ask for the total
+// count of a list, which costs an extra query.
+const QUERY_TOTAL_COUNT = "total_count"
+
+const HEADER_LINK = "Link"
+
+var errInvalidTotalCount = errors.New("total_count must be true or false")
+
+// wantsTotalCount tells whether the client asked for the total count.
+func wantsTotalCount(c echo.Context) (bool, error) {
+
+	param := c.QueryParam(QUERY_TOTAL_COUNT)
+	if param == "" {
+		return false, nil
+	}
+
+	wants, err := strconv.ParseBool(param)
+	if err != nil {
+		return false, errInvalidTotalCount
+	}
+
+	return wants, nil
+}
+
+// addListLinks adds the links of the pages around a page of a list to the
+// metadata and to the Link header (RFC 8288). Links are the url of the
+// request with only the pagination parameters changed, by page number or by
+// cursor as the request was.
+func addListLinks(c echo.Context, metadata *models.Metadata) {
+
+	requestUrl := *c.Request().URL
+
+	link := func(param string, value string) string {
+		query := requestUrl.Query()
+		query.Del("page")
+		query.Del("cursor")
+		query.Set(param, value)
+		u := requestUrl
+		u.RawQuery = query.Encode()
+		return u.RequestURI()
+	}
+
+	links := models.Links{
+		Self:  requestUrl.RequestURI(),
+		First: link("page", "1"),
+	}
+
+	if metadata.Page > 0 {
+		if metadata.HasNext {
+			links.Next = link("page", strconv.Itoa(metadata.Page+1))
+		}
+		if metadata.Page > 1 {
+			links.Prev = link("page", strconv.Itoa(metadata.Page-1))
+		}
+	} else {
+		if metadata.Next != "" {
+			links.Next = link("cursor", metadata.Next)
+		}
+		if metadata.Prev != "" {
+			links.Prev = link("cursor", metadata.Prev)
+		}
+	}
+
+	metadata.Links = &links
+
+	header := []string{
+		fmt.Sprintf(`<%s>; rel="self"`, links.Self),
+		fmt.Sprintf(`<%s>; rel="first"`, links.First),
+	}
+	if links.Next != "" {
+		header = append(header, fmt.Sprintf(`<%s>; rel="next"`, links.Next))
+	}
+	if links.Prev != "" {
+		header = append(header, fmt.Sprintf(`<%s>; rel="prev"`, links.Prev))
+	}
+	c.Response().Header().Set(HEADER_LINK, strings.Join(header, ", "))
+}
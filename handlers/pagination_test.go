@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAddListLinksByPage(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/v1/tasks?page=2&page_size=5&worker_name=ana", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	metadata := models.Metadata{Page: 2, PageSize: 5, HasNext: true}
+	addListLinks(c, &metadata)
+
+	assert.Equal(t, &models.Links{
+		Self:  "/v1/tasks?page=2&page_size=5&worker_name=ana",
+		First: "/v1/tasks?page=1&page_size=5&worker_name=ana",
+		Next:  "/v1/tasks?page=3&page_size=5&worker_name=ana",
+		Prev:  "/v1/tasks?page=1&page_size=5&worker_name=ana",
+	}, metadata.Links)
+
+	assert.Equal(t,
+		`</v1/tasks?page=2&page_size=5&worker_name=ana>; rel="self", `+
+			`</v1/tasks?page=1&page_size=5&worker_name=ana>; rel="first", `+
+			`</v1/tasks?page=3&page_size=5&worker_name=ana>; rel="next", `+
+			`</v1/tasks?page=1&page_size=5&worker_name=ana>; rel="prev"`,
+		rec.Header().Get(HEADER_LINK))
+}
+
+func TestAddListLinksOnLastPage(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/v1/users", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	metadata := models.Metadata{Page: 1, PageSize: 20}
+	addListLinks(c, &metadata)
+
+	assert.Equal(t, &models.Links{Self: "/v1/users", First: "/v1/users?page=1"}, metadata.Links)
+	assert.Equal(t, `</v1/users>; rel="self", </v1/users?page=1>; rel="first"`, rec.Header().Get(HEADER_LINK))
+}
+
+func TestAddListLinksByCursor(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/v1/tasks?cursor=b&page_size=5", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	metadata := models.Metadata{PageSize: 5, HasNext: true, Next: "c", Prev: "a"}
+	addListLinks(c, &metadata)
+
+	assert.Equal(t, &models.Links{
+		Self:  "/v1/tasks?cursor=b&page_size=5",
+		First: "/v1/tasks?page=1&page_size=5",
+		Next:  "/v1/tasks?cursor=c&page_size=5",
+		Prev:  "/v1/tasks?cursor=a&page_size=5",
+	}, metadata.Links)
+}
+
+func TestGetTaskListShould200OKWithTotalCount(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks?page_size=2&total_count=true", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("ListTasks", mock.Anything).Return([]models.Task{mockedTask, mockedTask, mockedTask}, nil)
+	mr.On("CountTasks", mock.Anything).Return(int64(7), nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.TaskListResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Len(t, response.Data, 2)
+		assert.True(t, response.Metadata.HasNext)
+		assert.Equal(t, int64(7), *response.Metadata.TotalCount)
+		assert.Equal(t, "/tasks?page=2&page_size=2&total_count=true", response.Metadata.Links.Next)
+		assert.Contains(t, rec.Header().Get(HEADER_LINK), `</tasks?page=2&page_size=2&total_count=true>; rel="next"`)
+	}
+}
+
+func TestGetTaskListShouldNotCountByDefault(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("ListTasks", mock.Anything).Return([]models.Task{mockedTask}, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "total_count")
+		assert.Contains(t, rec.Body.String(), `"has_next":false`)
+		mr.AssertNotCalled(t, "CountTasks", mock.Anything)
+	}
+}
+
+func TestGetTaskListShould400BadRequestWhenTotalCountIsInvalid(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks?total_count=maybe", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_INVALID_QUERY, errInvalidTotalCount.Error())
+	}
+}
+
+func TestListUsersShould200OKWithTotalCount(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/users?total_count=1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/users")
+
+	mr := mockUsersRepo{}
+	mr.On("ListUsers", mock.Anything).Return([]models.User{mockedUser}, nil)
+	mr.On("CountUsers", mock.MatchedBy(func(query repositories.ListQuery) bool {
+		return query.Pagination.Page == 1
+	})).Return(int64(1), nil)
+	h := NewUsersHandler(&mr)
+
+	// Assertions
+	if assert.NoError(t, h.ListUsers(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.UserListResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.False(t, response.Metadata.HasNext)
+		assert.Equal(t, int64(1), *response.Metadata.TotalCount)
+	}
+}
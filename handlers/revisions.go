@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/MrBolas/SupervisorAPI/search"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func (th *TasksHandler) GetTaskRevisions(c echo.Context) error {
+
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return problem.Write(c, problem.InvalidId("invalid task id"))
+	}
+
+	task, err := th.repo.GetTaskById(id)
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("task not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	// If User does not have manager Role or owns task is unAuthorized
+	if !auth.IsManager(c) && auth.GetUserId(c) != task.WorkerId {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	revisions, err := th.repo.ListTaskRevisions(id)
+	if err != nil {
+		return err
+	}
+
+	// Descrypt Summaries
+	for i := range revisions {
+		revisions[i].Summary = th.ce.Decrypt(revisions[i].Summary)
+	}
+
+	return c.JSON(http.StatusOK, models.ToRevisionListResponse(revisions, loc))
+}
+
+func (th *TasksHandler) GetTaskRevisionsDiff(c echo.Context) error {
+
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return problem.Write(c, problem.InvalidId("invalid task id"))
+	}
+
+	task, err := th.repo.GetTaskById(id)
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("task not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	// If User does not have manager Role or owns task is unAuthorized
+	if !auth.IsManager(c) && auth.GetUserId(c) != task.WorkerId {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	fromParam := c.QueryParam("from")
+	if fromParam == "" {
+		return problem.Write(c, problem.BadRequest(problem.CODE_INVALID_QUERY, "from revision is required"))
+	}
+	toParam := c.QueryParam("to")
+	if toParam == "" {
+		toParam = models.CURRENT_REVISION
+	}
+
+	from, err := th.taskAtRevision(task, fromParam)
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("revision not found"))
+	}
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	to, err := th.taskAtRevision(task, toParam)
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("revision not found"))
+	}
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	return c.JSON(http.StatusOK, models.TaskDiffResponse{
+		From:    fromParam,
+		To:      toParam,
+		Changes: models.DiffTasks(from, to, loc),
+	})
+}
+
+func (th *TasksHandler) RevertTask(c echo.Context) error {
+
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	format, err := summaryFormat(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	// Only Manager can revert
+	if !auth.IsManager(c) {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return problem.Write(c, problem.InvalidId("invalid task id"))
+	}
+
+	revisionNumber, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		return problem.Write(c, problem.InvalidId("invalid revision"))
+	}
+
+	existingTask, err := th.repo.GetTaskById(id)
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("task not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	revision, err := th.repo.GetTaskRevision(id, revisionNumber)
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("revision not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	if ifMatchFails(c, existingTask.ETag()) {
+		return problem.Write(c, problem.PreconditionFailed())
+	}
+
+	// the revision summary is already encrypted
+	revertedTask := revision.ToTask()
+
+	// revisions written before tasks had worker ids keep the current worker
+	if revertedTask.WorkerId == "" {
+		revertedTask.WorkerId = existingTask.WorkerId
+		revertedTask.WorkerName = existingTask.WorkerName
+	}
+	revertedTask.SearchTokens = search.Tokens(th.index, th.ce.Decrypt(revertedTask.Summary))
+
+	task, err := th.repo.UpdateTask(id, existingTask, revertedTask, auth.GetUserId(c))
+	if err == repositories.ErrVersionConflict {
+		return problem.Write(c, problem.PreconditionFailed())
+	}
+	if err != nil {
+		return err
+	}
+
+	th.publishTaskEvent(c, models.EVENT_TASK_UPDATED, task.Id)
+
+	// Descrypt Summary
+	task.Summary = th.ce.Decrypt(task.Summary)
+
+	c.Response().Header().Set(HEADER_ETAG, task.ETag())
+	return c.JSON(http.StatusOK, taskResponse(task, loc, format))
+}
+
+// taskAtRevision returns the decrypted task as it was at the given revision,
+// or its current state when revision is "current".
+func (th *TasksHandler) taskAtRevision(task models.Task, revision string) (models.Task, error) {
+
+	if revision == models.CURRENT_REVISION {
+		task.Summary = th.ce.Decrypt(task.Summary)
+		return task, nil
+	}
+
+	revisionNumber, err := strconv.Atoi(revision)
+	if err != nil || revisionNumber < 1 {
+		return models.Task{}, errors.New("revision must be a positive number or current")
+	}
+
+	taskRevision, err := th.repo.GetTaskRevision(task.Id, revisionNumber)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	revisedTask := taskRevision.ToTask()
+	revisedTask.Summary = th.ce.Decrypt(revisedTask.Summary)
+
+	return revisedTask, nil
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestGetTaskRevisionsShould200OK(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13/revisions", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id/revisions")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+
+	revision, err := models.NewTaskRevision(mockedTask, 1, "mocked_worker_id")
+	assert.Nil(t, err)
+
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	mr.On("ListTaskRevisions", mockedTask.Id).Return([]models.TaskRevision{revision}, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	revision.Summary = ce.Decrypt(revision.Summary)
+	u, err := json.Marshal(models.ToRevisionListResponse([]models.TaskRevision{revision}, time.UTC))
+	assert.Nil(t, err)
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskRevisions(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(u)+"\n", rec.Body.String())
+	}
+}
+
+func TestGetTaskRevisionsShould401UnauthorizedWhenNotOwner(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13/revisions", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/nickname"] = "another_worker_id"
+	claims["sub"] = "another_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id/revisions")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskRevisions(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestGetTaskRevisionsDiffShould200OK(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13/revisions/diff?from=1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["http://supervisorapi/nickname"] = "mocked_manager_id"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id/revisions/diff")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+
+	oldTask := mockedTask
+	oldTask.Summary = ce.Encrypt("old mocked summary")
+	oldTask.Date.Time = time.Date(2020, time.April, 14, 10, 50, 0, 0, time.UTC)
+	revision, err := models.NewTaskRevision(oldTask, 1, "mocked_worker_id")
+	assert.Nil(t, err)
+
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	mr.On("GetTaskRevision", mockedTask.Id, 1).Return(revision, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	u, err := json.Marshal(models.TaskDiffResponse{
+		From: "1",
+		To:   models.CURRENT_REVISION,
+		Changes: []models.FieldChange{
+			{Field: "summary", From: "old mocked summary", To: ce.Decrypt(mockedTask.Summary)},
+			{Field: "date", From: "2020-04-14T10:50:00Z", To: "2020-04-15T10:50:00Z"},
+		},
+	})
+	assert.Nil(t, err)
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskRevisionsDiff(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(u)+"\n", rec.Body.String())
+	}
+}
+
+func TestGetTaskRevisionsDiffShould404NotFoundWhenRevisionDoesNotExist(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13/revisions/diff?from=1&to=7", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id/revisions/diff")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+
+	revision, err := models.NewTaskRevision(mockedTask, 1, "mocked_worker_id")
+	assert.Nil(t, err)
+
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	mr.On("GetTaskRevision", mockedTask.Id, 1).Return(revision, nil)
+	mr.On("GetTaskRevision", mockedTask.Id, 7).Return(models.TaskRevision{}, gorm.ErrRecordNotFound)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskRevisionsDiff(c)) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CODE_NOT_FOUND, "revision not found")
+	}
+}
+
+func TestRevertTaskShould200OK(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13/revisions/1/revert", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["http://supervisorapi/nickname"] = "mocked_manager_id"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id/revisions/:revision/revert")
+	c.SetParamNames("id", "revision")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13", "1")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+
+	oldTask := mockedTask
+	oldTask.Summary = ce.Encrypt("old mocked summary")
+	revision, err := models.NewTaskRevision(oldTask, 1, "mocked_worker_id")
+	assert.Nil(t, err)
+
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	mr.On("GetTaskRevision", mockedTask.Id, 1).Return(revision, nil)
+	mr.On("UpdateTask", mockedTask.Id, mock.Anything).Return(oldTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	revertedTask := oldTask
+	revertedTask.Summary = "old mocked summary"
+	u, err := json.Marshal(revertedTask.ToResponse())
+	assert.Nil(t, err)
+
+	// Assertions
+	if assert.NoError(t, h.RevertTask(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(u)+"\n", rec.Body.String())
+	}
+}
+
+func TestRevertTaskShouldKeepWorkerOfLegacyRevisions(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13/revisions/1/revert", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["http://supervisorapi/nickname"] = "mocked_manager_id"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id/revisions/:revision/revert")
+	c.SetParamNames("id", "revision")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13", "1")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+
+	// revisions written before worker ids only have the nickname
+	legacyTask := mockedTask
+	legacyTask.WorkerId = ""
+	legacyTask.WorkerName = "old_nickname"
+	legacyTask.Summary = ce.Encrypt("old mocked summary")
+	revision, err := models.NewTaskRevision(legacyTask, 1, "")
+	assert.Nil(t, err)
+
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	mr.On("GetTaskRevision", mockedTask.Id, 1).Return(revision, nil)
+	mr.On("UpdateTask", mockedTask.Id, mock.MatchedBy(func(task models.Task) bool {
+		return task.WorkerId == mockedTask.WorkerId && task.WorkerName == mockedTask.WorkerName
+	})).Return(mockedTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.RevertTask(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestRevertTaskShould401UnauthorizedWhenNotManager(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13/revisions/1/revert", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id/revisions/:revision/revert")
+	c.SetParamNames("id", "revision")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13", "1")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.RevertTask(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+)
+
+const QUERY_GROUP_BY = "group_by"
+const QUERY_METRICS = "metrics"
+
+// GetTaskStats aggregates the tasks of the task list, with the same filters
+// and saved views. Pagination and sorting don't apply.
+func (th *TasksHandler) GetTaskStats(c echo.Context) error {
+
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	err = th.applyView(c, loc)
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	stats, err := repositories.ParseStats(c.QueryParam(QUERY_GROUP_BY), c.QueryParam(QUERY_METRICS))
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	query, err := buildTaskListQuery(c.QueryParams(), auth.IsManager(c), loc, th.index)
+	if err != nil {
+		return problem.Write(c, filterProblem(err))
+	}
+
+	// auth
+	if !auth.IsManager(c) {
+		query.Filters["worker_id"] = auth.GetUserId(c)
+	}
+
+	groups, err := th.repo.TaskStats(query, stats, loc)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, models.StatsResponse{
+		Data: groups,
+		Metadata: models.StatsMetadata{
+			GroupBy:  stats.GroupBy,
+			Metrics:  stats.Metrics,
+			TimeZone: loc.String(),
+			Dates:    query.DateRange().ToBounds(loc),
+		},
+	})
+}
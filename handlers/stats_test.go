@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetTaskStatsShould200OK(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/stats?group_by=worker,week&metrics=count&range=last_month&tz=Europe/Lisbon&worker_name=ana", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/stats")
+
+	count := int64(3)
+	groups := []models.StatsGroup{{
+		Group: map[string]string{"worker_id": "mocked_worker_id", "worker_name": "ana", "week": "2024-02-26"},
+		Count: &count,
+	}}
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("TaskStats", mock.MatchedBy(func(query repositories.ListQuery) bool {
+		return query.Filters["worker_name"] == "ana" && !query.DateRange().After.IsZero()
+	}), repositories.Stats{GroupBy: []string{"worker", "week"}, Metrics: []string{"count"}}).Return(groups, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskStats(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.StatsResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, groups, response.Data)
+		assert.Equal(t, "Europe/Lisbon", response.Metadata.TimeZone)
+		assert.Equal(t, []string{"worker", "week"}, response.Metadata.GroupBy)
+		assert.NotNil(t, response.Metadata.Dates)
+		assert.NotContains(t, rec.Body.String(), `"duration"`)
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestGetTaskStatsShouldOnlyCountOwnTasksOfTechnician(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/stats?worker_id=someone_else", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/stats")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("TaskStats", mock.MatchedBy(func(query repositories.ListQuery) bool {
+		return query.Filters["worker_id"] == "mocked_worker_id"
+	}), mock.Anything).Return([]models.StatsGroup{}, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskStats(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"data":[]`)
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestGetTaskStatsShould400BadRequestWhenGroupByIsInvalid(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/stats?group_by=day,week", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/stats")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskStats(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_INVALID_QUERY, "group by a single period, day, week or month")
+		mr.AssertNotCalled(t, "TaskStats", mock.Anything, mock.Anything)
+	}
+}
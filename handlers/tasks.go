@@ -1,14 +1,22 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/MrBolas/SupervisorAPI/auth"
 	"github.com/MrBolas/SupervisorAPI/encryption"
 	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/patch"
+	"github.com/MrBolas/SupervisorAPI/problem"
 	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/MrBolas/SupervisorAPI/search"
 	"github.com/go-redis/redis/v8"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
@@ -18,70 +26,134 @@ import (
 
 type TasksHandler struct {
 	repo    repositories.Repository
+	users   repositories.UsersRepository
+	views   repositories.ViewsRepository
+	schemas repositories.CustomFieldsRepository
+	limits  repositories.LimitsRepository
 	ce      encryption.CryptoEngine
+	index   encryption.BlindIndex
 	rclient *redis.Client
 }
 
-func NewTasksHandler(repo repositories.Repository, ce encryption.CryptoEngine, rclient *redis.Client) *TasksHandler {
+func NewTasksHandler(repo repositories.Repository, users repositories.UsersRepository, views repositories.ViewsRepository, schemas repositories.CustomFieldsRepository, limits repositories.LimitsRepository, ce encryption.CryptoEngine, index encryption.BlindIndex, rclient *redis.Client) *TasksHandler {
 	return &TasksHandler{
 		repo:    repo,
+		users:   users,
+		views:   views,
+		schemas: schemas,
+		limits:  limits,
 		ce:      ce,
+		index:   index,
 		rclient: rclient,
 	}
 }
 
 func (th *TasksHandler) GetTaskById(c echo.Context) error {
 
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	format, err := summaryFormat(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	view, err := parseTaskView(c.QueryParams())
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
 	id, err := uuid.FromString(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusNotFound, "Invalid Id")
+		return problem.Write(c, problem.InvalidId("invalid task id"))
 	}
 
 	task, err := th.repo.GetTaskById(id)
 	if err == gorm.ErrRecordNotFound {
-		return c.JSON(http.StatusNotFound, "Task not found")
+		return problem.Write(c, problem.NotFound("task not found"))
 	}
 	if err != nil {
 		return err
 	}
 
 	// If User does not have manager Role or owns task is unAuthorized
-	if !auth.IsManager(c) && auth.GetUserNickname(c) != task.WorkerId {
-		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	if !auth.IsManager(c) && auth.GetUserId(c) != task.WorkerId {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	// the assignee is read before the tag, its profile changes apart
+	var assignee *models.User
+	if view.includes(INCLUDE_ASSIGNEE) {
+		users, err := th.assignees([]string{task.WorkerId})
+		if err != nil {
+			return err
+		}
+		if user, ok := users[task.WorkerId]; ok {
+			assignee = &user
+		}
+	}
+
+	// the body depends on the fields, includes, format and time zone, so
+	// does the tag, and the format can come from Accept
+	etag := representationETag(task.ETag(), view.variant(format, loc, assignee))
+	c.Response().Header().Set(HEADER_ETAG, etag)
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	if ifNoneMatchHits(c, etag) {
+		return c.NoContent(http.StatusNotModified)
 	}
 
 	// Descrypt Summary
-	task.Summary = th.ce.Decrypt(task.Summary)
+	if view.wants("summary") {
+		task.Summary = th.ce.Decrypt(task.Summary)
+	}
 
-	return c.JSON(http.StatusOK, task.ToResponse())
+	response := taskResponse(task, loc, format)
+	if assignee != nil {
+		profile := assignee.ToResponse()
+		response.Assignee = &profile
+	}
+
+	return writeTaskFields(c, view, response)
 }
 
 func (th *TasksHandler) GetTaskList(c echo.Context) error {
 
-	query := repositories.NewListQuery()
-	isManager := auth.IsManager(c)
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
 
-	// auth
-	if !isManager {
-		query.Filters["worker_name"] = auth.GetUserNickname(c)
+	format, err := summaryFormat(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
 	}
 
-	// pagination
-	err := query.AddPageAndPageSize(c.QueryParam("page"), c.QueryParam("page_size"))
+	// saved views fill in the parameters the request doesn't have
+	err = th.applyView(c, loc)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return writeError(c, err)
 	}
 
-	// sorting
-	err = query.AddSorting(c.QueryParam("sort_by"), c.QueryParam("sort_order"))
+	view, err := parseTaskView(c.QueryParams())
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return problem.Write(c, queryProblem(err))
 	}
 
-	// create filters
-	err = query.AddListTaskFilters(c.QueryParams(), isManager)
+	query, err := buildTaskListQuery(c.QueryParams(), auth.IsManager(c), loc, th.index)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return problem.Write(c, filterProblem(err))
+	}
+
+	// auth
+	if !auth.IsManager(c) {
+		query.Filters["worker_id"] = auth.GetUserId(c)
+	}
+
+	withTotalCount, err := wantsTotalCount(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
 	}
 
 	// Call to repository
@@ -90,96 +162,218 @@ func (th *TasksHandler) GetTaskList(c echo.Context) error {
 		return err
 	}
 
+	tasks, next, prev := query.PageTasks(tasks)
+
+	metadata := models.Metadata{
+		Page:     query.Pagination.Page,
+		PageSize: query.Pagination.PageSize,
+		HasNext:  next != "",
+		Next:     next,
+		Prev:     prev,
+		Dates:    query.DateRange().ToBounds(loc),
+	}
+
+	if withTotalCount {
+		count, err := th.repo.CountTasks(query)
+		if err != nil {
+			return err
+		}
+		metadata.TotalCount = &count
+	}
+
+	addListLinks(c, &metadata)
+
 	var decryptedTaskList = make([]models.Task, 0)
 
-	// Descrypt Summary
+	// Descrypt Summary, unless it isn't wanted
 	for _, task := range tasks {
-		decryptedTaskList = append(decryptedTaskList, models.Task{
-			Id:       task.Id,
-			WorkerId: task.WorkerId,
-			Summary:  th.ce.Decrypt(task.Summary),
-			Date:     task.Date,
-		})
+		if view.wants("summary") {
+			task.Summary = th.ce.Decrypt(task.Summary)
+		}
+		decryptedTaskList = append(decryptedTaskList, task)
+	}
+
+	response := models.ToListResponse(decryptedTaskList, metadata, loc)
+	if format == FORMAT_HTML && view.wants("summary") {
+		response.RenderSummaryHTML()
+	}
+
+	return th.writeTaskList(c, view, response)
+}
+
+// buildTaskListQuery builds the query of the task list from its parameters.
+// Dates without zone are read in loc.
+func buildTaskListQuery(params url.Values, isManager bool, loc *time.Location, index encryption.BlindIndex) (repositories.ListQuery, error) {
+
+	query := repositories.NewListQuery()
+
+	// pagination
+	err := query.AddPageAndPageSize(params.Get("page"), params.Get("page_size"))
+	if err != nil {
+		return query, err
+	}
+
+	// sorting, sort replaces sort_by and sort_order
+	err = query.AddSorting(params.Get("sort_by"), params.Get("sort_order"))
+	if err != nil {
+		return query, err
+	}
+
+	err = query.AddSort(params.Get("sort"))
+	if err != nil {
+		return query, err
+	}
+
+	// keyset pagination
+	err = query.AddCursor(params.Get("cursor"))
+	if err != nil {
+		return query, err
+	}
+
+	// create filters
+	err = query.AddListTaskFilters(params, isManager, loc)
+	if err != nil {
+		return query, err
+	}
+
+	// filter expression
+	err = query.AddFilter(params.Get(QUERY_FILTER), loc)
+	if err != nil {
+		return query, err
+	}
+
+	// keyword search
+	err = query.AddSearch(params.Get("q"), index)
+	if err != nil {
+		return query, err
 	}
 
-	return c.JSON(http.StatusOK, models.ToListResponse(decryptedTaskList, query.Pagination.Page, query.Pagination.PageSize))
+	return query, nil
 }
 
 func (th *TasksHandler) CreateTask(c echo.Context) error {
 
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	format, err := summaryFormat(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
 	req := new(models.TaskRequest)
-	err := c.Bind(req)
+	err = c.Bind(req)
+	if err != nil {
+		return problem.Write(c, problem.Malformed("malformed request body"))
+	}
+
+	limits, err := teamLimits(th.limits, c)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, "Malformed JSON")
+		return err
+	}
+
+	err = req.ValidateFields(models.TASK_FIELDS, limits)
+	if err != nil {
+		return problem.Write(c, validationProblem(err))
 	}
 
-	err = req.Validate()
+	err = validateCustomFields(th.schemas, th.users, c, auth.GetUserId(c), req.Custom)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return writeError(c, err)
 	}
 
-	task, err := req.ToTask(auth.GetUserNickname(c))
+	task, err := req.ToTask(auth.GetUserId(c), auth.GetUserNickname(c), loc)
 	if err != nil {
 		return err
 	}
 
-	// Encrypt Summary
+	// Index and Encrypt Summary
+	task.SearchTokens = search.Tokens(th.index, task.Summary)
 	task.Summary = th.ce.Encrypt(task.Summary)
 
 	task, err = th.repo.CreateTask(task)
 	if err == gorm.ErrRegistered {
-		return c.JSON(http.StatusConflict, "conflict creating task")
+		return problem.Write(c, problem.Conflict("task already exists"))
 	}
 	if err != nil {
 		return err
 	}
 
 	// Add task to Queue
-	msg := fmt.Sprintf("The tech %s performed the task %s on date %s ", task.WorkerId, task.Id.String(), task.Date.Time.String())
-	log.Println(msg)
-	th.rclient.Publish(c.Request().Context(), "notifications", msg)
+	th.publishTaskCreated(c, task)
+	th.publishTaskEvent(c, models.EVENT_TASK_CREATED, task.Id)
 
-	return c.JSON(http.StatusCreated, task.ToResponse())
+	c.Response().Header().Set(HEADER_ETAG, task.ETag())
+	return c.JSON(http.StatusCreated, taskResponse(task, loc, format))
 }
 
 func (th *TasksHandler) DeleteTask(c echo.Context) error {
 
 	// Only Manager can delete
 	if !auth.IsManager(c) {
-		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+		return problem.Write(c, problem.Unauthorized())
 	}
 
 	id, err := uuid.FromString(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusNotFound, "Invalid Id")
+		return problem.Write(c, problem.InvalidId("invalid task id"))
 	}
 
-	_, err = th.repo.GetTaskById(id)
+	existingTask, err := th.repo.GetTaskById(id)
 	if err == gorm.ErrRecordNotFound {
-		return c.JSON(http.StatusNotFound, "Task not found")
+		return problem.Write(c, problem.NotFound("task not found"))
 	}
 	if err != nil {
 		return err
 	}
 
-	err = th.repo.DeleteTask(id)
+	// only delete the version the client has seen when it asks for it
+	version := 0
+	if c.Request().Header.Get(HEADER_IF_MATCH) != "" {
+		if ifMatchFails(c, existingTask.ETag()) {
+			return problem.Write(c, problem.PreconditionFailed())
+		}
+		version = existingTask.Version
+	}
+
+	err = th.repo.DeleteTask(id, version)
+	if err == repositories.ErrVersionConflict {
+		return problem.Write(c, problem.PreconditionFailed())
+	}
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("task not found"))
+	}
 	if err != nil {
 		return err
 	}
 
+	th.publishTaskEvent(c, models.EVENT_TASK_DELETED, id)
+
 	return c.NoContent(http.StatusNoContent)
 }
 
 func (th *TasksHandler) UpdateTask(c echo.Context) error {
 
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	format, err := summaryFormat(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
 	id, err := uuid.FromString(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusNotFound, "invalid id")
+		return problem.Write(c, problem.InvalidId("invalid task id"))
 	}
 
 	existingTask, err := th.repo.GetTaskById(id)
 	if err == gorm.ErrRecordNotFound {
-		return c.JSON(http.StatusNotFound, "Not found")
+		return problem.Write(c, problem.NotFound("task not found"))
 	}
 	if err != nil {
 		return err
@@ -188,33 +382,188 @@ func (th *TasksHandler) UpdateTask(c echo.Context) error {
 	req := new(models.TaskRequest)
 	err = c.Bind(req)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, "Malformed request")
+		return problem.Write(c, problem.Malformed("malformed request body"))
+	}
+
+	limits, err := teamLimits(th.limits, c)
+	if err != nil {
+		return err
+	}
+
+	err = req.ValidateFields(models.TASK_FIELDS, limits)
+	if err != nil {
+		return problem.Write(c, validationProblem(err))
 	}
 
-	err = req.Validate()
+	err = validateCustomFields(th.schemas, th.users, c, existingTask.WorkerId, req.Custom)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, err)
+		return writeError(c, err)
+	}
+
+	if auth.GetUserId(c) != existingTask.WorkerId {
+		return problem.Write(c, problem.Unauthorized())
 	}
 
-	if auth.GetUserNickname(c) != existingTask.WorkerId {
-		return c.JSON(http.StatusUnauthorized, "Unauthorized")
+	if ifMatchFails(c, existingTask.ETag()) {
+		return problem.Write(c, problem.PreconditionFailed())
 	}
 
-	newTask, err := req.ToTask(existingTask.WorkerId)
+	newTask, err := req.ToTask(existingTask.WorkerId, existingTask.WorkerName, loc)
 	if err != nil {
 		return err
 	}
 
-	// Encrypt Summary
+	// Index and Encrypt Summary
+	newTask.SearchTokens = search.Tokens(th.index, newTask.Summary)
 	newTask.Summary = th.ce.Encrypt(newTask.Summary)
 
-	task, err := th.repo.UpdateTask(id, existingTask, newTask)
+	task, err := th.repo.UpdateTask(id, existingTask, newTask, auth.GetUserId(c))
+	if err == repositories.ErrVersionConflict {
+		return problem.Write(c, problem.PreconditionFailed())
+	}
 	if err == gorm.ErrRegistered {
-		return c.JSON(http.StatusConflict, err)
+		return problem.Write(c, problem.Conflict(err.Error()))
+	}
+	if err != nil {
+		return err
+	}
+
+	th.publishTaskEvent(c, models.EVENT_TASK_UPDATED, task.Id)
+
+	c.Response().Header().Set(HEADER_ETAG, task.ETag())
+	return c.JSON(http.StatusOK, taskResponse(task, loc, format))
+}
+
+func (th *TasksHandler) PatchTask(c echo.Context) error {
+
+	loc, err := location(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	format, err := summaryFormat(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return problem.Write(c, problem.InvalidId("invalid task id"))
+	}
+
+	existingTask, err := th.repo.GetTaskById(id)
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("task not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	if auth.GetUserId(c) != existingTask.WorkerId {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	if ifMatchFails(c, existingTask.ETag()) {
+		return problem.Write(c, problem.PreconditionFailed())
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return problem.Write(c, problem.Malformed("malformed request body"))
+	}
+
+	// the patch is applied to the decrypted task, as the client sees it
+	decryptedTask := existingTask
+	decryptedTask.Summary = th.ce.Decrypt(existingTask.Summary)
+	original := decryptedTask.ToRequest()
+
+	doc, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+
+	var patchedDoc []byte
+	contentType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	switch contentType {
+	case patch.MERGE_PATCH_CONTENT_TYPE, echo.MIMEApplicationJSON:
+		patchedDoc, err = patch.MergePatch(doc, body)
+	case patch.JSON_PATCH_CONTENT_TYPE:
+		patchedDoc, err = patch.ApplyJSONPatch(doc, body)
+	default:
+		return problem.Write(c, problem.New(http.StatusUnsupportedMediaType, problem.CODE_UNSUPPORTED_MEDIA_TYPE, "Content-Type must be "+patch.MERGE_PATCH_CONTENT_TYPE+" or "+patch.JSON_PATCH_CONTENT_TYPE))
+	}
+	if err != nil {
+		return problem.Write(c, problem.BadRequest(problem.CODE_INVALID_PATCH, err.Error()))
+	}
+
+	var patched models.TaskRequest
+	err = json.Unmarshal(patchedDoc, &patched)
+	if err != nil {
+		return problem.Write(c, problem.Malformed("malformed request body"))
+	}
+
+	// only the fields touched by the patch are validated and applied
+	fields := original.ChangedFields(patched)
+	if len(fields) == 0 {
+		c.Response().Header().Set(HEADER_ETAG, existingTask.ETag())
+		return c.JSON(http.StatusOK, taskResponse(decryptedTask, loc, format))
+	}
+
+	limits, err := teamLimits(th.limits, c)
+	if err != nil {
+		return err
+	}
+
+	err = patched.ValidateFields(fields, limits)
+	if err != nil {
+		return problem.Write(c, validationProblem(err))
+	}
+
+	if contains(fields, models.FIELD_CUSTOM) {
+		err := validateCustomFields(th.schemas, th.users, c, existingTask.WorkerId, patched.Custom)
+		if err != nil {
+			return writeError(c, err)
+		}
+	}
+
+	newTask, err := patched.ApplyTo(existingTask, fields, loc)
+	if err != nil {
+		return problem.Write(c, validationProblem(err))
+	}
+
+	// Index and Encrypt Summary only when it changed
+	if newTask.Summary != existingTask.Summary {
+		newTask.SearchTokens = search.Tokens(th.index, newTask.Summary)
+		newTask.Summary = th.ce.Encrypt(newTask.Summary)
+	}
+
+	task, err := th.repo.UpdateTask(id, existingTask, newTask, auth.GetUserId(c))
+	if err == repositories.ErrVersionConflict {
+		return problem.Write(c, problem.PreconditionFailed())
 	}
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusOK, task.ToResponse())
+	th.publishTaskEvent(c, models.EVENT_TASK_UPDATED, task.Id)
+
+	task.Summary = patched.Summary
+
+	c.Response().Header().Set(HEADER_ETAG, task.ETag())
+	return c.JSON(http.StatusOK, taskResponse(task, loc, format))
+}
+
+func (th *TasksHandler) publishTaskCreated(c echo.Context, task models.Task) {
+	msg := fmt.Sprintf("The tech %s performed the task %s on date %s ", task.WorkerName, task.Id.String(), task.Date.Time.String())
+	log.Println(msg)
+	th.rclient.Publish(c.Request().Context(), "notifications", msg)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
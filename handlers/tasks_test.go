@@ -11,6 +11,8 @@ import (
 
 	"github.com/MrBolas/SupervisorAPI/encryption"
 	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/patch"
+	"github.com/MrBolas/SupervisorAPI/problem"
 	"github.com/MrBolas/SupervisorAPI/repositories"
 	"github.com/go-redis/redis/v8"
 	"github.com/gofrs/uuid"
@@ -31,13 +33,15 @@ var (
 		Date:    "2022-05-23 03:33:01PM",
 	}
 	mockedTask = models.Task{
-		Id:       uuid.FromStringOrNil("a2d45497-09b4-4da1-a0d0-173d0bd12f13"),
-		WorkerId: "mocked_worker_id",
-		Summary:  "wTThqMkifM_XNUE8WPnFLjhDOIlGD9cur5loFiQN",
+		Id:         uuid.FromStringOrNil("a2d45497-09b4-4da1-a0d0-173d0bd12f13"),
+		WorkerId:   "mocked_worker_id",
+		WorkerName: "mocked_worker_name",
+		Summary:    "wTThqMkifM_XNUE8WPnFLjhDOIlGD9cur5loFiQN",
 		Date: sql.NullTime{
 			Valid: true,
 			Time:  time.Date(2020, time.April, 15, 10, 50, 0, 0, time.UTC),
 		},
+		Version: 1,
 	}
 )
 
@@ -63,8 +67,13 @@ func (mr *mockRepo) CreateTask(t models.Task) (models.Task, error) {
 	return args.Get(0).(models.Task), args.Error(1)
 }
 
+func (mr *mockRepo) CreateTasks(tasks []models.Task) error {
+	args := mr.Called(tasks)
+	return args.Error(0)
+}
+
 func (mr *mockRepo) ListTasks(filters repositories.ListQuery) ([]models.Task, error) {
-	args := mr.Called()
+	args := mr.Called(filters)
 
 	mockedTask := args.Get(0)
 	if mockedTask == nil {
@@ -74,8 +83,24 @@ func (mr *mockRepo) ListTasks(filters repositories.ListQuery) ([]models.Task, er
 	return args.Get(0).([]models.Task), args.Error(1)
 }
 
-func (mr *mockRepo) UpdateTask(id uuid.UUID, oldTask models.Task, newTask models.Task) (models.Task, error) {
-	args := mr.Called(id)
+func (mr *mockRepo) CountTasks(filters repositories.ListQuery) (int64, error) {
+	args := mr.Called(filters)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (mr *mockRepo) TaskStats(filters repositories.ListQuery, stats repositories.Stats, loc *time.Location) ([]models.StatsGroup, error) {
+	args := mr.Called(filters, stats)
+
+	mockedGroups := args.Get(0)
+	if mockedGroups == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]models.StatsGroup), args.Error(1)
+}
+
+func (mr *mockRepo) UpdateTask(id uuid.UUID, oldTask models.Task, newTask models.Task, changedBy string) (models.Task, error) {
+	args := mr.Called(id, newTask)
 
 	mockedID := args.Get(0)
 	if mockedID == nil {
@@ -85,12 +110,38 @@ func (mr *mockRepo) UpdateTask(id uuid.UUID, oldTask models.Task, newTask models
 	return args.Get(0).(models.Task), args.Error(1)
 }
 
-func (mr *mockRepo) DeleteTask(id uuid.UUID) error {
-	args := mr.Called(id)
+func (mr *mockRepo) DeleteTask(id uuid.UUID, version int) error {
+	args := mr.Called(id, version)
 
 	return args.Error(0)
 }
 
+func (mr *mockRepo) ListTaskRevisions(taskId uuid.UUID) ([]models.TaskRevision, error) {
+	args := mr.Called(taskId)
+
+	mockedRevisions := args.Get(0)
+	if mockedRevisions == nil {
+		return []models.TaskRevision{}, args.Error(1)
+	}
+
+	return args.Get(0).([]models.TaskRevision), args.Error(1)
+}
+
+func (mr *mockRepo) GetTaskRevision(taskId uuid.UUID, revision int) (models.TaskRevision, error) {
+	args := mr.Called(taskId, revision)
+
+	mockedRevision := args.Get(0)
+	if mockedRevision == nil {
+		return models.TaskRevision{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.TaskRevision), args.Error(1)
+}
+
+func (mr *mockRepo) Transaction(fn func(repo repositories.Repository) error) error {
+	return fn(mr)
+}
+
 func addClaimsToJWTContext(c echo.Context, mockedClaims map[string]string) {
 
 	// Add role to claim
@@ -107,6 +158,19 @@ func addClaimsToJWTContext(c echo.Context, mockedClaims map[string]string) {
 	c.Set("user", tk)
 }
 
+// assertProblem checks that the response is a problem with the given code and detail.
+func assertProblem(t *testing.T, rec *httptest.ResponseRecorder, code string, detail string) {
+	assert.Equal(t, problem.CONTENT_TYPE, rec.Header().Get(echo.HeaderContentType))
+
+	var p problem.Problem
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	assert.Equal(t, rec.Code, p.Status)
+	assert.Equal(t, code, p.Code)
+	assert.Equal(t, detail, p.Detail)
+}
+
+var testBlindIndex = encryption.NewBlindIndex("J8s0pQ2mXv7LcN4rT1wZ6yB3hF9kD5gA")
+
 func createRedisClient() *redis.Client {
 	return redis.NewClient(&redis.Options{
 		Addr:     "localhost:6357",
@@ -132,7 +196,7 @@ func TestGetTaskByIdShould200OK(t *testing.T) {
 	mr := mockRepo{}
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
 	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	taskResponse := mockedTask.ToResponse()
 	taskResponse.Summary = ce.Decrypt(taskResponse.Summary)
@@ -163,7 +227,7 @@ func TestGetTaskByIdShould404NotFoundWhenDoesntExist(t *testing.T) {
 	mr := mockRepo{}
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
 	mr.On("GetTaskById", mock.Anything).Return(models.Task{}, gorm.ErrRecordNotFound)
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	// Assertions
 	if assert.NoError(t, h.GetTaskById(c)) {
@@ -184,6 +248,7 @@ func TestCreateTaskShould201Created(t *testing.T) {
 	claims := make(map[string]string, 0)
 	claims["http://supervisorapi/role"] = "manager"
 	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
 	addClaimsToJWTContext(c, claims)
 
 	c.SetPath("/tasks")
@@ -191,7 +256,7 @@ func TestCreateTaskShould201Created(t *testing.T) {
 	mr := mockRepo{}
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
 	mr.On("CreateTask", mock.Anything).Return(mockedTask, nil)
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	mockedTaskResponse := mockedTask.ToResponse()
 	u, err = json.Marshal(mockedTaskResponse)
@@ -219,6 +284,7 @@ func TestCreateTaskShould400BadRequestWhenTimeFormatIsInvalid(t *testing.T) {
 	claims := make(map[string]string, 0)
 	claims["http://supervisorapi/role"] = "manager"
 	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
 	addClaimsToJWTContext(c, claims)
 
 	c.SetPath("/tasks")
@@ -226,7 +292,7 @@ func TestCreateTaskShould400BadRequestWhenTimeFormatIsInvalid(t *testing.T) {
 	mr := mockRepo{}
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
 	mr.On("CreateTask", mock.Anything).Return(mockedTask, nil)
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	mockedTaskResponse := mockedTask.ToResponse()
 	u, err = json.Marshal(mockedTaskResponse)
@@ -235,7 +301,7 @@ func TestCreateTaskShould400BadRequestWhenTimeFormatIsInvalid(t *testing.T) {
 	// Assertions
 	if assert.NoError(t, h.CreateTask(c)) {
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.Equal(t, "\"invalid date format, use yyyy-mm-dd hh:mm:ssPM\"\n", rec.Body.String())
+		assertProblem(t, rec, problem.CODE_VALIDATION_FAILED, models.ErrInvalidDate.Error())
 	}
 }
 
@@ -254,6 +320,7 @@ func TestCreateTaskShould400BadRequestWhenSummaryIsInvalid(t *testing.T) {
 	claims := make(map[string]string, 0)
 	claims["http://supervisorapi/role"] = "manager"
 	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
 	addClaimsToJWTContext(c, claims)
 
 	c.SetPath("/tasks")
@@ -261,7 +328,7 @@ func TestCreateTaskShould400BadRequestWhenSummaryIsInvalid(t *testing.T) {
 	mr := mockRepo{}
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
 	mr.On("CreateTask", mock.Anything).Return(mockedTask, nil)
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	mockedTaskResponse := mockedTask.ToResponse()
 	u, err = json.Marshal(mockedTaskResponse)
@@ -270,7 +337,7 @@ func TestCreateTaskShould400BadRequestWhenSummaryIsInvalid(t *testing.T) {
 	// Assertions
 	if assert.NoError(t, h.CreateTask(c)) {
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.Equal(t, "\"summary max size is 2500 characters\"\n", rec.Body.String())
+		assertProblem(t, rec, problem.CODE_VALIDATION_FAILED, "summary max size is 2500 characters")
 	}
 }
 
@@ -287,6 +354,7 @@ func TestCreateTaskShould409ConflictWhenTaskAlreadyExists(t *testing.T) {
 	claims := make(map[string]string, 0)
 	claims["http://supervisorapi/role"] = "manager"
 	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
 	addClaimsToJWTContext(c, claims)
 
 	c.SetPath("/tasks")
@@ -294,12 +362,12 @@ func TestCreateTaskShould409ConflictWhenTaskAlreadyExists(t *testing.T) {
 	mr := mockRepo{}
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
 	mr.On("CreateTask", mock.Anything).Return(models.Task{}, gorm.ErrRegistered)
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	// Assertions
 	if assert.NoError(t, h.CreateTask(c)) {
 		assert.Equal(t, http.StatusConflict, rec.Code)
-		assert.Equal(t, "\"conflict creating task\"\n", rec.Body.String())
+		assertProblem(t, rec, problem.CODE_CONFLICT, "task already exists")
 	}
 }
 
@@ -312,6 +380,7 @@ func TestGetTaskListShould200OKListingFilteredTasks(t *testing.T) {
 	claims := make(map[string]string, 0)
 	claims["http://supervisorapi/role"] = "manager"
 	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
 	addClaimsToJWTContext(c, claims)
 
 	c.SetPath("/tasks")
@@ -321,7 +390,7 @@ func TestGetTaskListShould200OKListingFilteredTasks(t *testing.T) {
 	mr := mockRepo{}
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
 	mr.On("ListTasks", mock.Anything).Return(taskList, nil)
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	decryptedTaskList := []models.Task{}
 	for _, task := range taskList {
@@ -329,7 +398,12 @@ func TestGetTaskListShould200OKListingFilteredTasks(t *testing.T) {
 		decryptedTaskList = append(decryptedTaskList, task)
 	}
 
-	taskListResponse := models.ToListResponse(decryptedTaskList, 1, 20)
+	metadata := models.Metadata{
+		Page:     1,
+		PageSize: 20,
+		Links:    &models.Links{Self: "/tasks", First: "/tasks?page=1"},
+	}
+	taskListResponse := models.ToListResponse(decryptedTaskList, metadata, time.UTC)
 	u, err := json.Marshal(taskListResponse)
 	assert.Nil(t, err)
 
@@ -349,6 +423,7 @@ func TestGetTaskListShould400BadRequestWhenPageNumberIsLessThan1(t *testing.T) {
 	claims := make(map[string]string, 0)
 	claims["http://supervisorapi/role"] = "manager"
 	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
 	addClaimsToJWTContext(c, claims)
 
 	c.SetPath("/tasks")
@@ -359,12 +434,12 @@ func TestGetTaskListShould400BadRequestWhenPageNumberIsLessThan1(t *testing.T) {
 	mr := mockRepo{}
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
 	mr.On("ListTasks", mock.Anything).Return(taskList, nil)
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	// Assertions
 	if assert.NoError(t, h.GetTaskList(c)) {
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.Equal(t, "\"page must be bigger than 0\"\n", rec.Body.String())
+		assertProblem(t, rec, problem.CODE_INVALID_QUERY, "page must be bigger than 0")
 	}
 }
 
@@ -377,6 +452,7 @@ func TestGetTaskListShould400BadRequestWhenPageSizeNumberIsLessThan1(t *testing.
 	claims := make(map[string]string, 0)
 	claims["http://supervisorapi/role"] = "manager"
 	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
 	addClaimsToJWTContext(c, claims)
 
 	c.SetPath("/tasks")
@@ -388,12 +464,12 @@ func TestGetTaskListShould400BadRequestWhenPageSizeNumberIsLessThan1(t *testing.
 	mr := mockRepo{}
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
 	mr.On("ListTasks", mock.Anything).Return(taskList, nil)
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	// Assertions
 	if assert.NoError(t, h.GetTaskList(c)) {
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.Equal(t, "\"page_size must be bigger than 0\"\n", rec.Body.String())
+		assertProblem(t, rec, problem.CODE_INVALID_QUERY, "page_size must be bigger than 0")
 	}
 }
 
@@ -406,6 +482,7 @@ func TestGetTaskListShould400BadRequestWhenPageSizeNumberIsMoreThan40(t *testing
 	claims := make(map[string]string, 0)
 	claims["http://supervisorapi/role"] = "manager"
 	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
 	addClaimsToJWTContext(c, claims)
 
 	c.SetPath("/tasks")
@@ -417,12 +494,12 @@ func TestGetTaskListShould400BadRequestWhenPageSizeNumberIsMoreThan40(t *testing
 	mr := mockRepo{}
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
 	mr.On("ListTasks", mock.Anything).Return(taskList, nil)
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	// Assertions
 	if assert.NoError(t, h.GetTaskList(c)) {
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
-		assert.Equal(t, "\"page_size must be less or equal than 40\"\n", rec.Body.String())
+		assertProblem(t, rec, problem.CODE_INVALID_QUERY, "page_size must be less or equal than 40")
 	}
 }
 
@@ -438,6 +515,7 @@ func TestUpdateTaskShould200OK(t *testing.T) {
 	claims := make(map[string]string, 0)
 	claims["http://supervisorapi/role"] = "manager"
 	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
 	addClaimsToJWTContext(c, claims)
 
 	c.SetPath("/tasks/:id")
@@ -452,7 +530,7 @@ func TestUpdateTaskShould200OK(t *testing.T) {
 
 	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
 	mr.On("UpdateTask", updatedMockedTask.Id, mock.Anything).Return(updatedMockedTask, nil)
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	u, err = json.Marshal(updatedMockedTask.ToResponse())
 	assert.Nil(t, err)
@@ -476,6 +554,7 @@ func TestUpdateTaskShould404NotFoundWhenTaskDoesNotExist(t *testing.T) {
 	claims := make(map[string]string, 0)
 	claims["http://supervisorapi/role"] = "manager"
 	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
 	addClaimsToJWTContext(c, claims)
 
 	c.SetPath("/tasks/:id")
@@ -485,12 +564,12 @@ func TestUpdateTaskShould404NotFoundWhenTaskDoesNotExist(t *testing.T) {
 	mr := mockRepo{}
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
 	mr.On("GetTaskById", mock.Anything).Return(models.Task{}, gorm.ErrRecordNotFound)
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	// Assertions
 	if assert.NoError(t, h.UpdateTask(c)) {
 		assert.Equal(t, http.StatusNotFound, rec.Code)
-		assert.Equal(t, "\"Not found\"\n", rec.Body.String())
+		assertProblem(t, rec, problem.CODE_NOT_FOUND, "task not found")
 	}
 }
 
@@ -503,6 +582,7 @@ func TestDeleteTaskShould204NoContent(t *testing.T) {
 	claims := make(map[string]string, 0)
 	claims["http://supervisorapi/role"] = "manager"
 	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
 	addClaimsToJWTContext(c, claims)
 
 	c.SetPath("/tasks/:id")
@@ -511,9 +591,9 @@ func TestDeleteTaskShould204NoContent(t *testing.T) {
 
 	mr := mockRepo{}
 	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
-	mr.On("DeleteTask", mock.Anything).Return(nil)
+	mr.On("DeleteTask", mock.Anything, 0).Return(nil)
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	// Assertions
 	if assert.NoError(t, h.DeleteTask(c)) {
@@ -530,6 +610,7 @@ func TestDeleteTenantShouldReturn404NotFoundWhenItDoesntExist(t *testing.T) {
 	claims := make(map[string]string, 0)
 	claims["http://supervisorapi/role"] = "manager"
 	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
 	addClaimsToJWTContext(c, claims)
 
 	c.SetPath("/tasks/:id")
@@ -538,13 +619,473 @@ func TestDeleteTenantShouldReturn404NotFoundWhenItDoesntExist(t *testing.T) {
 
 	mr := mockRepo{}
 	mr.On("GetTaskById", mock.Anything).Return(models.Task{}, gorm.ErrRecordNotFound)
-	mr.On("DeleteTask", mock.Anything).Return(nil)
+	mr.On("DeleteTask", mock.Anything, 0).Return(nil)
 	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
-	h := NewTasksHandler(&mr, ce, createRedisClient())
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
 
 	// Assertions
 	if assert.NoError(t, h.DeleteTask(c)) {
 		assert.Equal(t, http.StatusNotFound, rec.Code)
-		assert.Equal(t, "\"Task not found\"\n", rec.Body.String())
+		assertProblem(t, rec, problem.CODE_NOT_FOUND, "task not found")
+	}
+}
+
+func TestGetTaskByIdShould304NotModifiedWhenETagMatches(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13", nil)
+	req.Header.Set(HEADER_IF_NONE_MATCH, mockedTask.ETag())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskById(c)) {
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Equal(t, mockedTask.ETag(), rec.Header().Get(HEADER_ETAG))
+		assert.Equal(t, "", rec.Body.String())
+	}
+}
+
+func TestGetTaskByIdShould200OKWhenETagIsOfAnotherRepresentation(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13?fields=date", nil)
+	req.Header.Set(HEADER_IF_NONE_MATCH, mockedTask.ETag())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskById(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		etag := rec.Header().Get(HEADER_ETAG)
+		assert.NotEqual(t, mockedTask.ETag(), etag)
+		assert.Equal(t, mockedTask.ETag(), versionETag(etag))
+		assert.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary))
+	}
+}
+
+func TestUpdateTaskShould412PreconditionFailedWhenIfMatchDoesNotMatch(t *testing.T) {
+	e := echo.New()
+	u, err := json.Marshal(mockedTaskRequest)
+	assert.Nil(t, err)
+	req := httptest.NewRequest(http.MethodPut, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13", strings.NewReader(string(u)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HEADER_IF_MATCH, "\"7\"")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.UpdateTask(c)) {
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		mr.AssertNotCalled(t, "UpdateTask", mock.Anything)
+	}
+}
+
+func TestUpdateTaskShould412PreconditionFailedWhenVersionConflicts(t *testing.T) {
+	e := echo.New()
+	u, err := json.Marshal(mockedTaskRequest)
+	assert.Nil(t, err)
+	req := httptest.NewRequest(http.MethodPut, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13", strings.NewReader(string(u)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HEADER_IF_MATCH, mockedTask.ETag())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	mr.On("UpdateTask", mockedTask.Id, mock.Anything).Return(models.Task{}, repositories.ErrVersionConflict)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.UpdateTask(c)) {
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	}
+}
+
+func TestDeleteTaskShould412PreconditionFailedWhenIfMatchDoesNotMatch(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13", nil)
+	req.Header.Set(HEADER_IF_MATCH, "\"7\"")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.DeleteTask(c)) {
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		mr.AssertNotCalled(t, "DeleteTask", mock.Anything, mock.Anything)
+	}
+}
+
+func TestDeleteTaskShould204NoContentWhenIfMatchMatches(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13", nil)
+	req.Header.Set(HEADER_IF_MATCH, mockedTask.ETag())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	mr.On("DeleteTask", mockedTask.Id, mockedTask.Version).Return(nil)
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.DeleteTask(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	}
+}
+
+func TestPatchTaskShould200OKWithMergePatch(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13", strings.NewReader(`{"date":"2022-06-01 09:00:00AM"}`))
+	req.Header.Set("Content-Type", patch.MERGE_PATCH_CONTENT_TYPE)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+
+	patchedTask := mockedTask
+	patchedTask.Date.Time = time.Date(2022, time.June, 1, 9, 0, 0, 0, time.UTC)
+
+	// the summary was not patched, so it must be stored with the same ciphertext
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	mr.On("UpdateTask", mockedTask.Id, patchedTask).Return(patchedTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	patchedTask.Summary = ce.Decrypt(patchedTask.Summary)
+	u, err := json.Marshal(patchedTask.ToResponse())
+	assert.Nil(t, err)
+
+	// Assertions
+	if assert.NoError(t, h.PatchTask(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(u)+"\n", rec.Body.String())
+	}
+}
+
+func TestPatchTaskShould200OKWithJSONPatch(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13", strings.NewReader(`[{"op":"replace","path":"/summary","value":"fixed typo"}]`))
+	req.Header.Set("Content-Type", patch.JSON_PATCH_CONTENT_TYPE)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+
+	patchedTask := mockedTask
+	patchedTask.Summary = ce.Encrypt("fixed typo")
+
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	mr.On("UpdateTask", mockedTask.Id, mock.MatchedBy(func(task models.Task) bool {
+		return ce.Decrypt(task.Summary) == "fixed typo" && task.Date == mockedTask.Date
+	})).Return(patchedTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	patchedTask.Summary = "fixed typo"
+	u, err := json.Marshal(patchedTask.ToResponse())
+	assert.Nil(t, err)
+
+	// Assertions
+	if assert.NoError(t, h.PatchTask(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(u)+"\n", rec.Body.String())
+	}
+}
+
+func TestPatchTaskShould400BadRequestWhenPatchedDateIsInvalid(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13", strings.NewReader(`{"date":"2022 06 01"}`))
+	req.Header.Set("Content-Type", patch.MERGE_PATCH_CONTENT_TYPE)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.PatchTask(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_VALIDATION_FAILED, models.ErrInvalidDate.Error())
+	}
+}
+
+func TestPatchTaskShould400BadRequestWhenOnlyTimeZoneIsPatched(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13", strings.NewReader(`{"time_zone":"Europe/Lisbon"}`))
+	req.Header.Set("Content-Type", patch.MERGE_PATCH_CONTENT_TYPE)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.PatchTask(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"date_required"`)
+		mr.AssertNotCalled(t, "UpdateTask", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	}
+}
+
+func TestPatchTaskShould415UnsupportedMediaType(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/tasks/a2d45497-09b4-4da1-a0d0-173d0bd12f13", strings.NewReader(`summary=x`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_id"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("GetTaskById", mock.Anything).Return(mockedTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.PatchTask(c)) {
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	}
+}
+
+func TestGetTaskListShould200OKWithNextCursor(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks?page_size=2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	taskList := []models.Task{mockedTask, mockedTask, mockedTask}
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("ListTasks", mock.Anything).Return(taskList, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.TaskListResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Len(t, response.Data, 2)
+		assert.Equal(t, "", response.Metadata.Prev)
+
+		cursor, err := repositories.DecodeCursor(response.Metadata.Next)
+		assert.Nil(t, err)
+		assert.Equal(t, mockedTask.Id, cursor.Id)
+	}
+}
+
+func TestGetTaskListShould400BadRequestWhenCursorIsInvalid(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks?cursor=abc", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_INVALID_QUERY, repositories.ErrInvalidCursor.Error())
+	}
+}
+
+func TestGetTaskListShould200OKSortingByManyFields(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks?sort=-date,worker_name,custom.priority&page_size=2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("ListTasks", mock.MatchedBy(func(q repositories.ListQuery) bool {
+		return q.Sort.String() == "-date,worker_name,custom.priority"
+	})).Return([]models.Task{mockedTask, mockedTask, mockedTask}, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.TaskListResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+
+		cursor, err := repositories.DecodeCursor(response.Metadata.Next)
+		assert.Nil(t, err)
+		assert.Equal(t, "-date,worker_name,custom.priority", cursor.Sort)
+		assert.Len(t, cursor.Values, 3)
+	}
+}
+
+func TestGetTaskListShould400BadRequestWhenSortFieldIsUnknown(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks?sort=-summary", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_INVALID_QUERY, `can't sort by "summary", sort by date, worker_name, worker_id, version or custom.<name>`)
 	}
 }
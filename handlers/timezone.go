@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/labstack/echo/v4"
+)
+
+// QUERY_TIME_ZONE is the query parameter clients use to choose the time zone
+// of the dates in responses.
+const QUERY_TIME_ZONE = "tz"
+
+// CONTEXT_USER is the context key of the user synced from the token claims.
+const CONTEXT_USER = "profile"
+
+// location resolves the time zone of a request. It is the tz query parameter,
+// or the default time zone preference of the user, or the zoneinfo claim,
+// or UTC. Dates without zone are read in it and response dates are written in it.
+func location(c echo.Context) (*time.Location, error) {
+
+	if tz := c.QueryParam(QUERY_TIME_ZONE); tz != "" {
+		return models.LoadLocation(tz)
+	}
+
+	if user, ok := c.Get(CONTEXT_USER).(models.User); ok && user.DefaultTimeZone != "" {
+		if loc, err := models.LoadLocation(user.DefaultTimeZone); err == nil {
+			return loc, nil
+		}
+	}
+
+	if loc, err := models.LoadLocation(auth.GetUserTimeZone(c)); err == nil {
+		return loc, nil
+	}
+
+	return time.UTC, nil
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLocationPrefersQueryThenUserThenClaim(t *testing.T) {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks?tz=Asia/Tokyo", nil)
+	c := e.NewContext(req, httptest.NewRecorder())
+	addClaimsToJWTContext(c, map[string]string{"zoneinfo": "America/New_York"})
+	c.Set(CONTEXT_USER, models.User{DefaultTimeZone: "Europe/Lisbon"})
+
+	loc, err := location(c)
+	assert.Nil(t, err)
+	assert.Equal(t, "Asia/Tokyo", loc.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	c = e.NewContext(req, httptest.NewRecorder())
+	addClaimsToJWTContext(c, map[string]string{"zoneinfo": "America/New_York"})
+	c.Set(CONTEXT_USER, models.User{DefaultTimeZone: "Europe/Lisbon"})
+
+	loc, err = location(c)
+	assert.Nil(t, err)
+	assert.Equal(t, "Europe/Lisbon", loc.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	c = e.NewContext(req, httptest.NewRecorder())
+	addClaimsToJWTContext(c, map[string]string{"zoneinfo": "America/New_York"})
+
+	loc, err = location(c)
+	assert.Nil(t, err)
+	assert.Equal(t, "America/New_York", loc.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	c = e.NewContext(req, httptest.NewRecorder())
+	addClaimsToJWTContext(c, map[string]string{})
+
+	loc, err = location(c)
+	assert.Nil(t, err)
+	assert.Equal(t, time.UTC, loc)
+}
+
+func TestGetTaskByIdShould200OKWithDateInRequestedTimeZone(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/?tz=Europe/Lisbon", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("GetTaskById", mockedTask.Id).Return(mockedTask, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskById(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"date":"2020-04-15T11:50:00+01:00"`)
+	}
+}
+
+func TestGetTaskByIdShould400BadRequestWhenTimeZoneIsInvalid(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/?tz=Mars/Olympus", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks/:id")
+	c.SetParamNames("id")
+	c.SetParamValues("a2d45497-09b4-4da1-a0d0-173d0bd12f13")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskById(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestGetTaskListShould200OKWithResolvedDates(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks?on=2024-03-05&tz=Europe/Berlin", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	mr.On("ListTasks", mock.MatchedBy(func(query repositories.ListQuery) bool {
+		return query.IntervalFilters[repositories.INTERVAL_FROM] == time.Date(2024, time.March, 4, 23, 0, 0, 0, time.UTC) &&
+			query.IntervalFilters["before"] == time.Date(2024, time.March, 5, 23, 0, 0, 0, time.UTC)
+	})).Return([]models.Task{mockedTask}, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.TaskListResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, &models.DateBounds{
+			After:  "2024-03-05T00:00:00+01:00",
+			Before: "2024-03-06T00:00:00+01:00",
+		}, response.Metadata.Dates)
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestGetTaskListShould400BadRequestWhenDatesAreInvalid(t *testing.T) {
+
+	cases := map[string]string{
+		"/tasks?range=next_week":              `unknown range "next_week", use today, yesterday, this_week, last_week, this_month, last_month, this_year, last_year`,
+		"/tasks?after=-7d&range=this_week":    "range can't be used together with after",
+		"/tasks?after=sometime":               "invalid after: " + models.ErrInvalidBound.Error(),
+		"/tasks?after=2024-03-05&before=-10y": "after must be earlier than before",
+	}
+
+	for target, detail := range cases {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		claims := make(map[string]string, 0)
+		claims["http://supervisorapi/role"] = "manager"
+		claims["sub"] = "mocked_manager_id"
+		addClaimsToJWTContext(c, claims)
+
+		c.SetPath("/tasks")
+
+		mr := mockRepo{}
+		ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+		h := NewTasksHandler(&mr, noUsersRepo(), noViewsRepo(), noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+		// Assertions
+		if assert.NoError(t, h.GetTaskList(c)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assertProblem(t, rec, problem.CODE_INVALID_QUERY, detail)
+			mr.AssertNotCalled(t, "ListTasks", mock.Anything)
+		}
+	}
+}
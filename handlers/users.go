@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type UsersHandler struct {
+	repo repositories.UsersRepository
+}
+
+func NewUsersHandler(repo repositories.UsersRepository) *UsersHandler {
+	return &UsersHandler{
+		repo: repo,
+	}
+}
+
+// SyncUser is a middleware that keeps the users table up to date with the
+// claims of the authenticated user, creating the user on its first request.
+func (uh *UsersHandler) SyncUser(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+
+		user, err := uh.repo.SyncUser(models.User{
+			Id:       auth.GetUserId(c),
+			Nickname: auth.GetUserNickname(c),
+			Role:     auth.GetUserRole(c),
+			Team:     auth.GetUserTeam(c),
+			TimeZone: auth.GetUserTimeZone(c),
+		})
+		if err != nil {
+			return err
+		}
+
+		// the user preferences are needed by the other handlers
+		c.Set(CONTEXT_USER, user)
+
+		return next(c)
+	}
+}
+
+func (uh *UsersHandler) ListUsers(c echo.Context) error {
+
+	// Only Manager can browse the directory
+	if !auth.IsManager(c) {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	query := repositories.NewListQuery()
+
+	// pagination
+	err := query.AddPageAndPageSize(c.QueryParam("page"), c.QueryParam("page_size"))
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	// create filters
+	err = query.AddListUserFilters(c.QueryParams())
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	withTotalCount, err := wantsTotalCount(c)
+	if err != nil {
+		return problem.Write(c, queryProblem(err))
+	}
+
+	users, err := uh.repo.ListUsers(query)
+	if err != nil {
+		return err
+	}
+
+	response := models.ToUserListResponse(users, query.Pagination.Page, query.Pagination.PageSize)
+
+	if withTotalCount {
+		count, err := uh.repo.CountUsers(query)
+		if err != nil {
+			return err
+		}
+		response.Metadata.TotalCount = &count
+	}
+
+	addListLinks(c, &response.Metadata)
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (uh *UsersHandler) GetUserById(c echo.Context) error {
+
+	id := c.Param("id")
+
+	// If User does not have manager Role or is not the user is unAuthorized
+	if !auth.IsManager(c) && auth.GetUserId(c) != id {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	user, err := uh.repo.GetUserById(id)
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("user not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, user.ToResponse())
+}
+
+func (uh *UsersHandler) GetMe(c echo.Context) error {
+
+	user, err := uh.repo.GetUserById(auth.GetUserId(c))
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("user not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, user.ToProfileResponse())
+}
+
+func (uh *UsersHandler) UpdateMyPreferences(c echo.Context) error {
+
+	req := new(models.UserPreferencesRequest)
+	err := c.Bind(req)
+	if err != nil {
+		return problem.Write(c, problem.Malformed("malformed request body"))
+	}
+
+	err = req.Validate()
+	if err != nil {
+		return problem.Write(c, validationProblem(err))
+	}
+
+	user, err := uh.repo.GetUserById(auth.GetUserId(c))
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("user not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err = uh.repo.UpdateUserPreferences(req.ApplyTo(user))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, user.ToProfileResponse())
+}
+
+// ListTaskClaims lists the tasks stored before users existed, by nickname,
+// with the users they could be linked to.
+func (uh *UsersHandler) ListTaskClaims(c echo.Context) error {
+
+	// Only Admin can review claims
+	if !auth.IsAdmin(c) {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	claims, err := uh.repo.ListTaskClaims()
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, claims)
+}
+
+// ClaimTasks links the legacy tasks of a nickname to the user chosen by an
+// admin. It is a one-off migration, users never claim tasks by logging in.
+func (uh *UsersHandler) ClaimTasks(c echo.Context) error {
+
+	// Only Admin can claim
+	if !auth.IsAdmin(c) {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	req := new(models.TaskClaimRequest)
+	err := c.Bind(req)
+	if err != nil {
+		return problem.Write(c, problem.Malformed("malformed request body"))
+	}
+
+	err = req.Validate()
+	if err != nil {
+		return problem.Write(c, validationProblem(err))
+	}
+
+	claimed, err := uh.repo.ClaimTasks(req.Nickname, req.UserId)
+	if err == gorm.ErrRecordNotFound {
+		return problem.Write(c, problem.NotFound("user not found"))
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, models.TaskClaimResponse{Claimed: claimed})
+}
@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockUsersRepo struct {
+	mock.Mock
+}
+
+func (mr *mockUsersRepo) GetUserById(id string) (models.User, error) {
+	args := mr.Called(id)
+
+	mockedUser := args.Get(0)
+	if mockedUser == nil {
+		return models.User{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (mr *mockUsersRepo) GetUsersByIds(ids []string) ([]models.User, error) {
+	args := mr.Called(ids)
+
+	mockedUsers := args.Get(0)
+	if mockedUsers == nil {
+		return []models.User{}, args.Error(1)
+	}
+
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+// noUsersRepo returns a repository for handlers that don't include users.
+func noUsersRepo() *mockUsersRepo {
+	return &mockUsersRepo{}
+}
+
+func (mr *mockUsersRepo) SyncUser(u models.User) (models.User, error) {
+	args := mr.Called(u)
+
+	mockedUser := args.Get(0)
+	if mockedUser == nil {
+		return models.User{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (mr *mockUsersRepo) ListUsers(query repositories.ListQuery) ([]models.User, error) {
+	args := mr.Called(query)
+
+	mockedUsers := args.Get(0)
+	if mockedUsers == nil {
+		return []models.User{}, args.Error(1)
+	}
+
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (mr *mockUsersRepo) CountUsers(query repositories.ListQuery) (int64, error) {
+	args := mr.Called(query)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (mr *mockUsersRepo) UpdateUserPreferences(u models.User) (models.User, error) {
+	args := mr.Called(u)
+
+	mockedUser := args.Get(0)
+	if mockedUser == nil {
+		return models.User{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (mr *mockUsersRepo) GetUserByCalendarToken(hash string) (models.User, error) {
+	args := mr.Called(hash)
+
+	mockedUser := args.Get(0)
+	if mockedUser == nil {
+		return models.User{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (mr *mockUsersRepo) SetCalendarToken(id string, hash string) error {
+	args := mr.Called(id, hash)
+	return args.Error(0)
+}
+
+func (mr *mockUsersRepo) BackfillTaskWorkers() (int64, error) {
+	args := mr.Called()
+	return int64(args.Int(0)), args.Error(1)
+}
+
+func (mr *mockUsersRepo) ListTaskClaims() ([]repositories.TaskClaim, error) {
+	args := mr.Called()
+
+	mockedClaims := args.Get(0)
+	if mockedClaims == nil {
+		return []repositories.TaskClaim{}, args.Error(1)
+	}
+
+	return args.Get(0).([]repositories.TaskClaim), args.Error(1)
+}
+
+func (mr *mockUsersRepo) ClaimTasks(nickname string, userId string) (int64, error) {
+	args := mr.Called(nickname, userId)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+var mockedUser = models.User{
+	Id:                   "mocked_worker_id",
+	Nickname:             "mocked_worker_name",
+	Role:                 "technician",
+	Team:                 "hvac",
+	TimeZone:             "Europe/Lisbon",
+	NotificationsEnabled: true,
+}
+
+func TestSyncUserShouldSyncClaims(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_name"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	user := models.User{Id: "mocked_worker_id", Nickname: "mocked_worker_name", Role: "technician"}
+
+	mr := mockUsersRepo{}
+	mr.On("SyncUser", user).Return(user, nil)
+	h := NewUsersHandler(&mr)
+
+	called := false
+	next := func(c echo.Context) error {
+		called = true
+		return c.NoContent(http.StatusOK)
+	}
+
+	// Assertions
+	if assert.NoError(t, h.SyncUser(next)(c)) {
+		assert.True(t, called)
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestSyncUserShouldFailWhenRepositoryFails(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/nickname"] = "mocked_worker_name"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	mr := mockUsersRepo{}
+	mr.On("SyncUser", mock.Anything).Return(models.User{}, errors.New("db down"))
+	h := NewUsersHandler(&mr)
+
+	called := false
+	next := func(c echo.Context) error {
+		called = true
+		return nil
+	}
+
+	// Assertions
+	assert.Error(t, h.SyncUser(next)(c))
+	assert.False(t, called)
+}
+
+func TestListUsersShould200OK(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/users?q=mock&team=hvac", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/users")
+
+	mr := mockUsersRepo{}
+	mr.On("ListUsers", mock.MatchedBy(func(query repositories.ListQuery) bool {
+		return query.Search == "mock" && query.Filters["team"] == "hvac"
+	})).Return([]models.User{mockedUser}, nil)
+	h := NewUsersHandler(&mr)
+
+	response := models.ToUserListResponse([]models.User{mockedUser}, 1, 20)
+	response.Metadata.Links = &models.Links{
+		Self:  "/users?q=mock&team=hvac",
+		First: "/users?page=1&q=mock&team=hvac",
+	}
+	u, err := json.Marshal(response)
+	assert.Nil(t, err)
+
+	// Assertions
+	if assert.NoError(t, h.ListUsers(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(u)+"\n", rec.Body.String())
+	}
+}
+
+func TestListUsersShould401UnauthorizedWhenNotManager(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/users")
+
+	mr := mockUsersRepo{}
+	h := NewUsersHandler(&mr)
+
+	// Assertions
+	if assert.NoError(t, h.ListUsers(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestGetMeShould200OKWithPreferences(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/me")
+
+	mr := mockUsersRepo{}
+	mr.On("GetUserById", "mocked_worker_id").Return(mockedUser, nil)
+	h := NewUsersHandler(&mr)
+
+	u, err := json.Marshal(mockedUser.ToProfileResponse())
+	assert.Nil(t, err)
+
+	// Assertions
+	if assert.NoError(t, h.GetMe(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(u)+"\n", rec.Body.String())
+	}
+}
+
+func TestUpdateMyPreferencesShould200OK(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/me/preferences", strings.NewReader(`{"notifications_enabled":false}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/me/preferences")
+
+	updatedUser := mockedUser
+	updatedUser.NotificationsEnabled = false
+
+	mr := mockUsersRepo{}
+	mr.On("GetUserById", "mocked_worker_id").Return(mockedUser, nil)
+	mr.On("UpdateUserPreferences", updatedUser).Return(updatedUser, nil)
+	h := NewUsersHandler(&mr)
+
+	u, err := json.Marshal(updatedUser.ToProfileResponse())
+	assert.Nil(t, err)
+
+	// Assertions
+	if assert.NoError(t, h.UpdateMyPreferences(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(u)+"\n", rec.Body.String())
+	}
+}
+
+func TestUpdateMyPreferencesShould400BadRequestWhenTimeZoneIsInvalid(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/me/preferences", strings.NewReader(`{"default_time_zone":"Mars/Olympus"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/me/preferences")
+
+	mr := mockUsersRepo{}
+	h := NewUsersHandler(&mr)
+
+	// Assertions
+	if assert.NoError(t, h.UpdateMyPreferences(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_VALIDATION_FAILED, models.ErrInvalidTimeZone.Error())
+	}
+}
+
+func TestListTaskClaimsShould200OK(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/task-claims", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "admin"
+	claims["sub"] = "mocked_admin_id"
+	addClaimsToJWTContext(c, claims)
+
+	taskClaims := []repositories.TaskClaim{{Nickname: "mocked_worker_name", UserId: "mocked_worker_id", Tasks: 3, Reason: repositories.CLAIM_AMBIGUOUS}}
+
+	mr := mockUsersRepo{}
+	mr.On("ListTaskClaims").Return(taskClaims, nil)
+	h := NewUsersHandler(&mr)
+
+	u, err := json.Marshal(taskClaims)
+	assert.Nil(t, err)
+
+	// Assertions
+	if assert.NoError(t, h.ListTaskClaims(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(u)+"\n", rec.Body.String())
+	}
+}
+
+func TestClaimTasksShould200OK(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/task-claims", strings.NewReader(`{"nickname":"mocked_worker_name","user_id":"mocked_worker_id"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "admin"
+	claims["sub"] = "mocked_admin_id"
+	addClaimsToJWTContext(c, claims)
+
+	mr := mockUsersRepo{}
+	mr.On("ClaimTasks", "mocked_worker_name", "mocked_worker_id").Return(int64(3), nil)
+	h := NewUsersHandler(&mr)
+
+	// Assertions
+	if assert.NoError(t, h.ClaimTasks(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `{"claimed":3}`+"\n", rec.Body.String())
+	}
+}
+
+func TestClaimTasksShould401UnauthorizedWhenNotAdmin(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/task-claims", strings.NewReader(`{"nickname":"mocked_worker_name","user_id":"mocked_worker_id"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	mr := mockUsersRepo{}
+	h := NewUsersHandler(&mr)
+
+	// Assertions
+	if assert.NoError(t, h.ClaimTasks(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		mr.AssertNotCalled(t, "ClaimTasks", mock.Anything, mock.Anything)
+	}
+}
+
+func TestClaimTasksShould400BadRequestWhenUserIsMissing(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/task-claims", strings.NewReader(`{"nickname":"mocked_worker_name"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "admin"
+	claims["sub"] = "mocked_admin_id"
+	addClaimsToJWTContext(c, claims)
+
+	mr := mockUsersRepo{}
+	h := NewUsersHandler(&mr)
+
+	// Assertions
+	if assert.NoError(t, h.ClaimTasks(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
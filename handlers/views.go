@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/auth"
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// QUERY_VIEW is the query parameter applying a saved view to the task list.
+const QUERY_VIEW = "view"
+
+type ViewsHandler struct {
+	repo  repositories.ViewsRepository
+	index encryption.BlindIndex
+}
+
+func NewViewsHandler(repo repositories.ViewsRepository, index encryption.BlindIndex) *ViewsHandler {
+	return &ViewsHandler{
+		repo:  repo,
+		index: index,
+	}
+}
+
+func (vh *ViewsHandler) ListViews(c echo.Context) error {
+
+	views, err := vh.repo.ListViews(auth.GetUserId(c), auth.GetUserTeam(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, models.ToViewListResponse(views))
+}
+
+func (vh *ViewsHandler) GetViewById(c echo.Context) error {
+
+	view, err := vh.getVisibleView(c)
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, view.ToResponse())
+}
+
+func (vh *ViewsHandler) CreateView(c echo.Context) error {
+
+	var req models.ViewRequest
+	err := c.Bind(&req)
+	if err != nil {
+		return problem.Write(c, problem.Malformed("malformed request body"))
+	}
+
+	err = vh.validateRequest(c, req)
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	view, err := vh.repo.CreateView(req.ToView(auth.GetUserId(c), auth.GetUserTeam(c)))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, view.ToResponse())
+}
+
+func (vh *ViewsHandler) UpdateView(c echo.Context) error {
+
+	view, err := vh.getVisibleView(c)
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	// Only the owner can change a view
+	if view.OwnerId != auth.GetUserId(c) {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	var req models.ViewRequest
+	err = c.Bind(&req)
+	if err != nil {
+		return problem.Write(c, problem.Malformed("malformed request body"))
+	}
+
+	err = vh.validateRequest(c, req)
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	view, err = vh.repo.UpdateView(req.ApplyTo(view))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, view.ToResponse())
+}
+
+func (vh *ViewsHandler) DeleteView(c echo.Context) error {
+
+	view, err := vh.getVisibleView(c)
+	if err != nil {
+		return writeError(c, err)
+	}
+
+	// The owner or an Admin can delete a view
+	if view.OwnerId != auth.GetUserId(c) && !auth.IsAdmin(c) {
+		return problem.Write(c, problem.Unauthorized())
+	}
+
+	err = vh.repo.DeleteView(view.Id)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// getVisibleView gets the view of the id parameter, views the user can't see
+// are not found.
+func (vh *ViewsHandler) getVisibleView(c echo.Context) (models.View, error) {
+
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return models.View{}, problem.InvalidId("invalid view id")
+	}
+
+	return visibleView(vh.repo, c, id)
+}
+
+// validateRequest checks the request and runs its query through the task
+// list, so views are only saved when they can be applied.
+func (vh *ViewsHandler) validateRequest(c echo.Context, req models.ViewRequest) error {
+
+	err := req.Validate()
+	if err != nil {
+		return validationProblem(err)
+	}
+
+	loc, err := location(c)
+	if err != nil {
+		return queryProblem(err)
+	}
+
+	err = checkViewParams(req.Params(), auth.IsManager(c), loc, vh.index)
+	if err != nil {
+		return problem.Validation(err.Error(), problem.FieldError{
+			Field:   models.FIELD_VIEW_QUERY,
+			Code:    "invalid",
+			Message: err.Error(),
+		})
+	}
+
+	return nil
+}
+
+// visibleView gets a view the user can see, Admins see every view.
+func visibleView(repo repositories.ViewsRepository, c echo.Context, id uuid.UUID) (models.View, error) {
+
+	view, err := repo.GetViewById(id)
+	if err == gorm.ErrRecordNotFound {
+		return models.View{}, problem.NotFound("view not found")
+	}
+	if err != nil {
+		return models.View{}, err
+	}
+
+	if !auth.IsAdmin(c) && !view.VisibleTo(auth.GetUserId(c), auth.GetUserTeam(c)) {
+		return models.View{}, problem.NotFound("view not found")
+	}
+
+	return view, nil
+}
+
+// checkViewParams checks the parameters of a view the way the task list reads
+// them.
+func checkViewParams(params url.Values, isManager bool, loc *time.Location, index encryption.BlindIndex) error {
+
+	_, err := buildTaskListQuery(params, isManager, loc, index)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseTaskView(params)
+	return err
+}
+
+// applyView merges the parameters of the saved view of the view parameter
+// into the query of the request, parameters of the request win. The view is
+// checked again as the task list may have changed since it was saved.
+func (th *TasksHandler) applyView(c echo.Context, loc *time.Location) error {
+
+	params := c.QueryParams()
+	if params.Get(QUERY_VIEW) == "" {
+		return nil
+	}
+
+	id, err := uuid.FromString(params.Get(QUERY_VIEW))
+	if err != nil {
+		return problem.NotFound("view not found")
+	}
+
+	view, err := visibleView(th.views, c, id)
+	if err != nil {
+		return err
+	}
+
+	saved := view.Params()
+	err = checkViewParams(saved, auth.IsManager(c), loc, th.index)
+	if err != nil {
+		return queryProblem(fmt.Errorf("saved view %s is no longer valid: %s", view.Name, err.Error()))
+	}
+
+	for key, values := range saved {
+		if _, ok := params[key]; !ok {
+			params[key] = values
+		}
+	}
+	params.Del(QUERY_VIEW)
+
+	// the links of the list repeat the parameters of the view
+	c.Request().URL.RawQuery = params.Encode()
+
+	return nil
+}
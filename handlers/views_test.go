@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/gofrs/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type mockViewsRepo struct {
+	mock.Mock
+}
+
+var mockedView = models.View{
+	Id:        uuid.FromStringOrNil("7f1c2d3e-4b5a-4c6d-8e9f-0a1b2c3d4e5f"),
+	Name:      "hvac week",
+	OwnerId:   "mocked_manager_id",
+	Team:      "mocked_team",
+	Shared:    true,
+	Query:     "after=2024-03-01T00%3A00%3A00Z&page_size=5&sort=worker_name%2C-date&worker_name=ana",
+	CreatedAt: time.Date(2024, time.March, 1, 8, 0, 0, 0, time.UTC),
+	UpdatedAt: time.Date(2024, time.March, 1, 8, 0, 0, 0, time.UTC),
+}
+
+func (mr *mockViewsRepo) GetViewById(id uuid.UUID) (models.View, error) {
+	args := mr.Called(id)
+
+	mockedView := args.Get(0)
+	if mockedView == nil {
+		return models.View{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.View), args.Error(1)
+}
+
+func (mr *mockViewsRepo) ListViews(ownerId string, team string) ([]models.View, error) {
+	args := mr.Called(ownerId, team)
+
+	mockedViews := args.Get(0)
+	if mockedViews == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).([]models.View), args.Error(1)
+}
+
+func (mr *mockViewsRepo) CreateView(v models.View) (models.View, error) {
+	args := mr.Called(v)
+
+	mockedView := args.Get(0)
+	if mockedView == nil {
+		return models.View{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.View), args.Error(1)
+}
+
+func (mr *mockViewsRepo) UpdateView(v models.View) (models.View, error) {
+	args := mr.Called(v)
+
+	mockedView := args.Get(0)
+	if mockedView == nil {
+		return models.View{}, args.Error(1)
+	}
+
+	return args.Get(0).(models.View), args.Error(1)
+}
+
+func (mr *mockViewsRepo) DeleteView(id uuid.UUID) error {
+	args := mr.Called(id)
+	return args.Error(0)
+}
+
+// noViewsRepo returns a repository for requests without saved views.
+func noViewsRepo() *mockViewsRepo {
+	return &mockViewsRepo{}
+}
+
+func TestCreateViewShould201Created(t *testing.T) {
+	e := echo.New()
+	body := `{"name":" hvac week ","query":{"worker_name":"ana","sort":"worker_name,-date"},"shared":true}`
+	req := httptest.NewRequest(http.MethodPost, "/views", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["http://supervisorapi/team"] = "mocked_team"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	mr := mockViewsRepo{}
+	mr.On("CreateView", mock.MatchedBy(func(v models.View) bool {
+		return v.Name == "hvac week" && v.OwnerId == "mocked_manager_id" && v.Team == "mocked_team" &&
+			v.Shared && v.Query == "sort=worker_name%2C-date&worker_name=ana"
+	})).Return(mockedView, nil)
+	h := NewViewsHandler(&mr, testBlindIndex)
+
+	// Assertions
+	if assert.NoError(t, h.CreateView(c)) {
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var response models.ViewResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, mockedView.Id, response.Id)
+		assert.Equal(t, "worker_name,-date", response.Query["sort"])
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestCreateViewShould400BadRequestWhenQueryIsInvalid(t *testing.T) {
+
+	cases := map[string]string{
+		`{"name":"v","query":{"cursor":"abc"}}`:     "cursor can't be saved in a view",
+		`{"name":"v","query":{"q":"pump"}}`:         "q can't be saved in a view",
+		`{"name":"v","query":{"sort":"secret"}}`:    `can't sort by "secret", sort by date, worker_name, worker_id, version or custom.<name>`,
+		`{"name":"v","query":{"fields":"secret"}}`:  `unknown field "secret" in fields, use id, worker_id, worker_name, summary, date, custom, version`,
+		`{"name":"","query":{"worker_name":"ana"}}`: "name is required",
+	}
+
+	for body, message := range cases {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/views", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		claims := make(map[string]string, 0)
+		claims["http://supervisorapi/role"] = "manager"
+		claims["sub"] = "mocked_manager_id"
+		addClaimsToJWTContext(c, claims)
+
+		mr := mockViewsRepo{}
+		h := NewViewsHandler(&mr, testBlindIndex)
+
+		// Assertions
+		if assert.NoError(t, h.CreateView(c)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+
+			var p problem.Problem
+			assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &p))
+			assert.Equal(t, problem.CODE_VALIDATION_FAILED, p.Code)
+			if assert.Len(t, p.Errors, 1, body) {
+				assert.Equal(t, message, p.Errors[0].Message)
+			}
+			mr.AssertNotCalled(t, "CreateView", mock.Anything)
+		}
+	}
+}
+
+func TestGetViewByIdShould404NotFoundWhenNotVisible(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/team"] = "other_team"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/views/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(mockedView.Id.String())
+
+	mr := mockViewsRepo{}
+	mr.On("GetViewById", mockedView.Id).Return(mockedView, nil)
+	h := NewViewsHandler(&mr, testBlindIndex)
+
+	// Assertions
+	if assert.NoError(t, h.GetViewById(c)) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CODE_NOT_FOUND, "view not found")
+	}
+}
+
+func TestUpdateViewShould401UnauthorizedWhenNotOwner(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"name":"mine","query":{"worker_name":"pump"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "technician"
+	claims["http://supervisorapi/team"] = "mocked_team"
+	claims["sub"] = "mocked_worker_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/views/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(mockedView.Id.String())
+
+	mr := mockViewsRepo{}
+	mr.On("GetViewById", mockedView.Id).Return(mockedView, nil)
+	h := NewViewsHandler(&mr, testBlindIndex)
+
+	// Assertions
+	if assert.NoError(t, h.UpdateView(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		mr.AssertNotCalled(t, "UpdateView", mock.Anything)
+	}
+}
+
+func TestDeleteViewShould204NoContentForAdmin(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "admin"
+	claims["sub"] = "mocked_admin_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/views/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(mockedView.Id.String())
+
+	mr := mockViewsRepo{}
+	mr.On("GetViewById", mockedView.Id).Return(mockedView, nil)
+	mr.On("DeleteView", mockedView.Id).Return(nil)
+	h := NewViewsHandler(&mr, testBlindIndex)
+
+	// Assertions
+	if assert.NoError(t, h.DeleteView(c)) {
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestGetTaskListShould200OKWithSavedView(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks?view="+mockedView.Id.String()+"&page_size=2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["http://supervisorapi/team"] = "mocked_team"
+	claims["sub"] = "other_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	vr := mockViewsRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	vr.On("GetViewById", mockedView.Id).Return(mockedView, nil)
+	mr.On("ListTasks", mock.MatchedBy(func(query repositories.ListQuery) bool {
+		// the page size of the request wins over the view
+		return query.Pagination.PageSize == 2 && query.Filters["worker_name"] == "ana" &&
+			query.Sort.String() == "worker_name,-date" && !query.DateRange().After.IsZero()
+	})).Return([]models.Task{mockedTask}, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), &vr, noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.TaskListResponse
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "/tasks?after=2024-03-01T00%3A00%3A00Z&page_size=2&sort=worker_name%2C-date&worker_name=ana", response.Metadata.Links.Self)
+		mr.AssertExpectations(t)
+	}
+}
+
+func TestGetTaskListShould400BadRequestWhenSavedViewIsStale(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks?view="+mockedView.Id.String(), nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	stale := mockedView
+	stale.Query = "sort=priority"
+
+	mr := mockRepo{}
+	vr := mockViewsRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	vr.On("GetViewById", mockedView.Id).Return(stale, nil)
+	h := NewTasksHandler(&mr, noUsersRepo(), &vr, noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assertProblem(t, rec, problem.CODE_INVALID_QUERY,
+			`saved view hvac week is no longer valid: can't sort by "priority", sort by date, worker_name, worker_id, version or custom.<name>`)
+		mr.AssertNotCalled(t, "ListTasks", mock.Anything)
+	}
+}
+
+func TestGetTaskListShould404NotFoundWhenViewIsMissing(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/tasks?view="+mockedView.Id.String(), nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	claims := make(map[string]string, 0)
+	claims["http://supervisorapi/role"] = "manager"
+	claims["sub"] = "mocked_manager_id"
+	addClaimsToJWTContext(c, claims)
+
+	c.SetPath("/tasks")
+
+	mr := mockRepo{}
+	vr := mockViewsRepo{}
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	vr.On("GetViewById", mockedView.Id).Return(nil, gorm.ErrRecordNotFound)
+	h := NewTasksHandler(&mr, noUsersRepo(), &vr, noCustomFieldsRepo(), noTeamLimitsRepo(), ce, testBlindIndex, createRedisClient())
+
+	// Assertions
+	if assert.NoError(t, h.GetTaskList(c)) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assertProblem(t, rec, problem.CODE_NOT_FOUND, "view not found")
+	}
+}
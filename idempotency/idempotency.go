@@ -0,0 +1,219 @@
+package idempotency
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+)
+
+const HEADER_IDEMPOTENCY_KEY = "Idempotency-Key"
+const HEADER_IDEMPOTENT_REPLAYED = "Idempotent-Replayed"
+
+const DEFAULT_TTL = 24 * time.Hour
+
+// PENDING_TTL is how long a key stays reserved by a request in progress. A
+// process that dies during the request frees the key after it, instead of
+// answering 409 until the key expires.
+const PENDING_TTL = 2 * time.Minute
+const MAX_KEY_LENGTH = 255
+const KEY_PREFIX = "idempotency:"
+
+// replayedHeaders are the response headers stored and replayed with a response.
+var replayedHeaders = []string{echo.HeaderContentType, echo.HeaderLocation, "ETag"}
+
+// Record is what is stored in Redis for an idempotency key. A record that is
+// not completed belongs to a request that is still being processed.
+type Record struct {
+	RequestHash string            `json:"request_hash"`
+	Completed   bool              `json:"completed"`
+	Status      int               `json:"status,omitempty"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
+
+type Store struct {
+	rclient *redis.Client
+	ttl     time.Duration
+}
+
+func NewStore(rclient *redis.Client, ttl time.Duration) *Store {
+	return &Store{
+		rclient: rclient,
+		ttl:     ttl,
+	}
+}
+
+// Middleware makes the requests carrying an Idempotency-Key header
+// idempotent. The first response for a key is stored and replayed for every
+// repeated request with the same key, scope and body, while a different body
+// under the same key is rejected with 422. scope isolates the keys of
+// different users.
+func (s *Store) Middleware(scope func(c echo.Context) string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			key := c.Request().Header.Get(HEADER_IDEMPOTENCY_KEY)
+			if key == "" {
+				return next(c)
+			}
+
+			if len(key) > MAX_KEY_LENGTH {
+				return problem.Write(c, problem.BadRequest(problem.CODE_MALFORMED_REQUEST, "Idempotency-Key is too long"))
+			}
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return problem.Write(c, problem.Malformed("malformed request body"))
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := c.Request().Context()
+			redisKey := KEY_PREFIX + scope(c) + ":" + key
+			hash := RequestHash(c.Request().Method, c.Request().URL.Path, c.Request().URL.RawQuery, body)
+
+			reserved, err := s.reserve(ctx, redisKey, hash)
+			if err != nil {
+				// without Redis the request is processed as if it had no key
+				log.Printf("idempotency store unavailable: %v", err)
+				return next(c)
+			}
+
+			if !reserved {
+				return s.replay(c, redisKey, hash)
+			}
+
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			err = next(c)
+
+			// failed requests can be retried with the same key
+			if err != nil || c.Response().Status >= http.StatusInternalServerError {
+				s.rclient.Del(ctx, redisKey)
+				return err
+			}
+
+			record := Record{
+				RequestHash: hash,
+				Completed:   true,
+				Status:      c.Response().Status,
+				Header:      make(map[string]string),
+				Body:        recorder.body.Bytes(),
+			}
+			for _, header := range replayedHeaders {
+				if value := c.Response().Header().Get(header); value != "" {
+					record.Header[header] = value
+				}
+			}
+
+			if err := s.save(ctx, redisKey, record); err != nil {
+				log.Printf("unable to store idempotent response: %v", err)
+			}
+
+			return nil
+		}
+	}
+}
+
+// RequestHash fingerprints a request so a key reused for a different request
+// can be detected. The query is the raw query of the URL, parameters such as
+// atomic change the request as much as the body.
+func RequestHash(method string, path string, query string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write([]byte(query))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *Store) reserve(ctx context.Context, redisKey string, hash string) (bool, error) {
+
+	pending, err := json.Marshal(Record{RequestHash: hash})
+	if err != nil {
+		return false, err
+	}
+
+	return s.rclient.SetNX(ctx, redisKey, pending, PENDING_TTL).Result()
+}
+
+// save stores the response of a key, for the whole TTL.
+func (s *Store) save(ctx context.Context, redisKey string, record Record) error {
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return s.rclient.Set(ctx, redisKey, value, s.ttl).Err()
+}
+
+func (s *Store) replay(c echo.Context, redisKey string, hash string) error {
+
+	value, err := s.rclient.Get(c.Request().Context(), redisKey).Bytes()
+	if err == redis.Nil {
+		// the key expired or its request failed in the meantime
+		return problem.Write(c, problem.New(http.StatusConflict, problem.CODE_IDEMPOTENCY_IN_PROGRESS, "request with this Idempotency-Key is being retried, try again"))
+	}
+	if err != nil {
+		return err
+	}
+
+	var record Record
+	if err := json.Unmarshal(value, &record); err != nil {
+		return err
+	}
+
+	if record.RequestHash != hash {
+		return problem.Write(c, problem.New(http.StatusUnprocessableEntity, problem.CODE_IDEMPOTENCY_KEY_REUSED, "Idempotency-Key was already used for a different request"))
+	}
+
+	if !record.Completed {
+		return problem.Write(c, problem.New(http.StatusConflict, problem.CODE_IDEMPOTENCY_IN_PROGRESS, "request with this Idempotency-Key is still being processed"))
+	}
+
+	for header, headerValue := range record.Header {
+		c.Response().Header().Set(header, headerValue)
+	}
+	c.Response().Header().Set(HEADER_IDEMPOTENT_REPLAYED, "true")
+
+	c.Response().WriteHeader(record.Status)
+	_, err = c.Response().Write(record.Body)
+	return err
+}
+
+// responseRecorder copies the response body while it is written.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return r.ResponseWriter.(http.Hijacker).Hijack()
+}
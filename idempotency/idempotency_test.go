@@ -0,0 +1,150 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rclient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	return NewStore(rclient, time.Hour), mr
+}
+
+func newTestServer(store *Store, calls *int) *echo.Echo {
+	e := echo.New()
+	scope := func(c echo.Context) string { return c.Request().Header.Get("X-User") }
+
+	e.POST("/tasks", func(c echo.Context) error {
+		*calls++
+		c.Response().Header().Set("ETag", "\"1\"")
+		return c.JSON(http.StatusCreated, map[string]int{"call": *calls})
+	}, store.Middleware(scope))
+
+	return e
+}
+
+func doRequest(e *echo.Echo, key string, user string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", user)
+	if key != "" {
+		req.Header.Set(HEADER_IDEMPOTENCY_KEY, key)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareReplaysStoredResponse(t *testing.T) {
+	store, mr := newTestStore(t)
+	calls := 0
+	e := newTestServer(store, &calls)
+
+	first := doRequest(e, "key-1", "user-1", `{"summary":"a"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	second := doRequest(e, "key-1", "user-1", `{"summary":"a"}`)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "\"1\"", second.Header().Get("ETag"))
+	assert.Equal(t, "true", second.Header().Get(HEADER_IDEMPOTENT_REPLAYED))
+	assert.Equal(t, 1, calls)
+
+	// keys are scoped by user
+	other := doRequest(e, "key-1", "user-2", `{"summary":"a"}`)
+	assert.Equal(t, http.StatusCreated, other.Code)
+	assert.Equal(t, 2, calls)
+
+	// keys expire
+	mr.FastForward(2 * time.Hour)
+	expired := doRequest(e, "key-1", "user-1", `{"summary":"a"}`)
+	assert.Equal(t, http.StatusCreated, expired.Code)
+	assert.Equal(t, 3, calls)
+}
+
+func TestMiddlewareRejectsDifferentBodyForSameKey(t *testing.T) {
+	store, _ := newTestStore(t)
+	calls := 0
+	e := newTestServer(store, &calls)
+
+	first := doRequest(e, "key-1", "user-1", `{"summary":"a"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	conflicting := doRequest(e, "key-1", "user-1", `{"summary":"b"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, conflicting.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestMiddlewareRejectsDifferentQueryForSameKey(t *testing.T) {
+	store, _ := newTestStore(t)
+	calls := 0
+	e := newTestServer(store, &calls)
+
+	post := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"summary":"a"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User", "user-1")
+		req.Header.Set(HEADER_IDEMPOTENCY_KEY, "key-1")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusCreated, post("/tasks?atomic=true").Code)
+	assert.Equal(t, http.StatusCreated, post("/tasks?atomic=true").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, post("/tasks?atomic=false").Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestMiddlewareRejectsKeyInProgress(t *testing.T) {
+	store, mr := newTestStore(t)
+	calls := 0
+	e := newTestServer(store, &calls)
+
+	hash := RequestHash(http.MethodPost, "/tasks", "", []byte(`{"summary":"a"}`))
+	mr.Set(KEY_PREFIX+"user-1:key-1", `{"request_hash":"`+hash+`","completed":false}`)
+
+	inProgress := doRequest(e, "key-1", "user-1", `{"summary":"a"}`)
+	assert.Equal(t, http.StatusConflict, inProgress.Code)
+	assert.Equal(t, 0, calls)
+}
+
+func TestMiddlewarePendingKeysExpireSoon(t *testing.T) {
+	store, mr := newTestStore(t)
+
+	reserved, err := store.reserve(context.Background(), KEY_PREFIX+"user-1:key-1", "hash")
+	assert.Nil(t, err)
+	assert.True(t, reserved)
+	assert.Equal(t, PENDING_TTL, mr.TTL(KEY_PREFIX+"user-1:key-1"))
+
+	// the key of a request that never ended is free again
+	mr.FastForward(PENDING_TTL)
+	calls := 0
+	e := newTestServer(store, &calls)
+	retried := doRequest(e, "key-1", "user-1", `{"summary":"a"}`)
+	assert.Equal(t, http.StatusCreated, retried.Code)
+	assert.Equal(t, 1, calls)
+
+	// stored responses are kept for the whole TTL
+	assert.Equal(t, time.Hour, mr.TTL(KEY_PREFIX+"user-1:key-1"))
+}
+
+func TestMiddlewareWithoutKey(t *testing.T) {
+	store, _ := newTestStore(t)
+	calls := 0
+	e := newTestServer(store, &calls)
+
+	doRequest(e, "", "user-1", `{"summary":"a"}`)
+	doRequest(e, "", "user-1", `{"summary":"a"}`)
+	assert.Equal(t, 2, calls)
+}
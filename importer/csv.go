@@ -0,0 +1,226 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+)
+
+const COLUMN_SUMMARY = "summary"
+const COLUMN_DATE = "date"
+const COLUMN_TIME_ZONE = "time_zone"
+const COLUMN_WORKER_ID = "worker_id"
+const COLUMN_WORKER_NAME = "worker_name"
+
+// CUSTOM_PREFIX maps columns to custom fields, as custom.<name>.
+const CUSTOM_PREFIX = "custom."
+
+// COLUMNS are the fields of tasks columns can be mapped to, along with
+// custom.<name>. summary and date are required.
+var COLUMNS = []string{COLUMN_SUMMARY, COLUMN_DATE, COLUMN_TIME_ZONE, COLUMN_WORKER_ID, COLUMN_WORKER_NAME}
+
+// ErrInvalidHeader is the error of files whose header doesn't fit the
+// mapping, nothing can be imported from them.
+var ErrInvalidHeader = errors.New("invalid header")
+
+// Mapping maps the fields of tasks to the headers of the columns of a file.
+type Mapping map[string]string
+
+// Row is a line of a file as a task request. Line is the line of the file
+// the row starts on, the header being line 1.
+type Row struct {
+	Line       int
+	Request    models.TaskRequest
+	WorkerId   string
+	WorkerName string
+}
+
+// ParseMapping parses mappings such as summary:Description, a field and the
+// header of its column. Columns named after a field that isn't mapped are
+// mapped to it.
+func ParseMapping(pairs []string) (Mapping, error) {
+
+	mapping := make(Mapping)
+
+	for _, pair := range pairs {
+		field, header, ok := cut(pair, ":")
+		if !ok || strings.TrimSpace(header) == "" {
+			return nil, fmt.Errorf("invalid mapping %q, use field:header", pair)
+		}
+
+		field = strings.TrimSpace(field)
+		if !isColumn(field) {
+			return nil, fmt.Errorf("can't map to %q, map to %s or custom.<name>", field, strings.Join(COLUMNS, ", "))
+		}
+		if _, ok := mapping[field]; ok {
+			return nil, fmt.Errorf("%s is mapped more than once", field)
+		}
+
+		mapping[field] = strings.TrimSpace(header)
+	}
+
+	return mapping, nil
+}
+
+func isColumn(field string) bool {
+
+	if strings.HasPrefix(field, CUSTOM_PREFIX) {
+		return models.CUSTOM_FIELD_NAME.MatchString(strings.TrimPrefix(field, CUSTOM_PREFIX))
+	}
+
+	for _, column := range COLUMNS {
+		if column == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reader reads the rows of a CSV file one at a time.
+type Reader struct {
+	csv     *csv.Reader
+	columns map[string]int
+}
+
+// NewReader reads the header of the file and resolves the mapping against it.
+func NewReader(r io.Reader, mapping Mapping) (*Reader, error) {
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w, the file is empty", ErrInvalidHeader)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidHeader, err.Error())
+	}
+
+	// a byte order mark is left by spreadsheets
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[strings.TrimSpace(name)] = i
+	}
+
+	columns := make(map[string]int)
+	for field, name := range mapping {
+		i, ok := positions[name]
+		if !ok {
+			return nil, fmt.Errorf("%w, column %q of %s is not in the file", ErrInvalidHeader, name, field)
+		}
+		columns[field] = i
+	}
+
+	for name, i := range positions {
+		if _, mapped := mapping[name]; !mapped && isColumn(name) {
+			columns[name] = i
+		}
+	}
+
+	for _, field := range []string{COLUMN_SUMMARY, COLUMN_DATE} {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("%w, no column is mapped to %s", ErrInvalidHeader, field)
+		}
+	}
+
+	return &Reader{csv: cr, columns: columns}, nil
+}
+
+// Next reads the next row, it returns io.EOF after the last one. Lines that
+// aren't valid CSV return a *LineError, the rows after them can still be read.
+func (r *Reader) Next() (Row, error) {
+
+	record, err := r.csv.Read()
+	if err == io.EOF {
+		return Row{}, err
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Row{}, &LineError{Line: parseErr.StartLine, Errors: []problem.FieldError{{
+			Field:   "line",
+			Code:    problem.CODE_MALFORMED_REQUEST,
+			Message: parseErr.Err.Error(),
+		}}}
+	}
+	if err != nil {
+		return Row{}, err
+	}
+
+	line, _ := r.csv.FieldPos(0)
+	row := Row{Line: line}
+
+	value := func(field string) string {
+		i, ok := r.columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row.Request = models.TaskRequest{
+		Summary:  value(COLUMN_SUMMARY),
+		Date:     value(COLUMN_DATE),
+		TimeZone: value(COLUMN_TIME_ZONE),
+	}
+	row.WorkerId = value(COLUMN_WORKER_ID)
+	row.WorkerName = value(COLUMN_WORKER_NAME)
+
+	for _, field := range r.customColumns() {
+		if v := value(field); v != "" {
+			if row.Request.Custom == nil {
+				row.Request.Custom = models.CustomFields{}
+			}
+			row.Request.Custom[strings.TrimPrefix(field, CUSTOM_PREFIX)] = customValue(v)
+		}
+	}
+
+	return row, nil
+}
+
+func (r *Reader) customColumns() []string {
+
+	fields := make([]string, 0)
+	for field := range r.columns {
+		if strings.HasPrefix(field, CUSTOM_PREFIX) {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	return fields
+}
+
+// customValue reads numbers and booleans as such, so they validate against
+// the schema of the team, and anything else as text.
+func customValue(value string) interface{} {
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err == nil {
+		switch v.(type) {
+		case float64, bool:
+			return v
+		}
+	}
+
+	return value
+}
+
+// cut is strings.Cut, which Go 1.17 doesn't have.
+func cut(s string, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,88 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMapping(t *testing.T) {
+
+	mapping, err := ParseMapping([]string{"summary:Description", " date : Day ", "custom.site:Site: Name"})
+	assert.Nil(t, err)
+	assert.Equal(t, Mapping{"summary": "Description", "date": "Day", "custom.site": "Site: Name"}, mapping)
+
+	_, err = ParseMapping([]string{"summary"})
+	assert.EqualError(t, err, `invalid mapping "summary", use field:header`)
+
+	_, err = ParseMapping([]string{"version:Version"})
+	assert.EqualError(t, err, `can't map to "version", map to summary, date, time_zone, worker_id, worker_name or custom.<name>`)
+
+	_, err = ParseMapping([]string{"date:Day", "date:When"})
+	assert.EqualError(t, err, "date is mapped more than once")
+}
+
+func TestReaderMapsColumns(t *testing.T) {
+
+	file := "\ufeffDescription,Day,worker_id,Site,custom.hours,Notes\n" +
+		"Fixed the pump,2022-05-23 03:33:01PM,auth0|1,Lisbon,2.5,ignored\n" +
+		"\"Replaced the\nfilter\",2022-05-24T10:00:00Z,,,true,\n"
+
+	reader, err := NewReader(strings.NewReader(file), Mapping{"summary": "Description", "date": "Day", "custom.site": "Site"})
+	assert.Nil(t, err)
+
+	row, err := reader.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, Row{
+		Line: 2,
+		Request: models.TaskRequest{
+			Summary: "Fixed the pump",
+			Date:    "2022-05-23 03:33:01PM",
+			Custom:  models.CustomFields{"site": "Lisbon", "hours": 2.5},
+		},
+		WorkerId: "auth0|1",
+	}, row)
+
+	// quoted values span lines
+	row, err = reader.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, row.Line)
+	assert.Equal(t, "Replaced the\nfilter", row.Request.Summary)
+	assert.Equal(t, models.CustomFields{"hours": true}, row.Request.Custom)
+
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReaderRejectsInvalidHeaders(t *testing.T) {
+
+	_, err := NewReader(strings.NewReader(""), Mapping{})
+	assert.True(t, errors.Is(err, ErrInvalidHeader))
+
+	_, err = NewReader(strings.NewReader("summary,date\n"), Mapping{"date": "Day"})
+	assert.EqualError(t, err, `invalid header, column "Day" of date is not in the file`)
+
+	_, err = NewReader(strings.NewReader("summary,when\n"), Mapping{})
+	assert.EqualError(t, err, "invalid header, no column is mapped to date")
+}
+
+func TestReaderReportsMalformedLines(t *testing.T) {
+
+	reader, err := NewReader(strings.NewReader("summary,date\na \"b\" c,2022-05-23T10:00:00Z\nok,2022-05-23T10:00:00Z\n"), Mapping{})
+	assert.Nil(t, err)
+
+	_, err = reader.Next()
+	var le *LineError
+	if assert.True(t, errors.As(err, &le)) {
+		assert.Equal(t, 2, le.Line)
+		assert.Equal(t, "line", le.Errors[0].Field)
+	}
+
+	row, err := reader.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", row.Request.Summary)
+}
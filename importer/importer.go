@@ -0,0 +1,267 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/MrBolas/SupervisorAPI/search"
+	"gorm.io/gorm"
+)
+
+// DEFAULT_BATCH_SIZE is the number of tasks inserted at a time.
+const DEFAULT_BATCH_SIZE = 200
+
+// MAX_REPORTED_ERRORS is the most invalid lines a report lists, the others
+// are only counted.
+const MAX_REPORTED_ERRORS = 1000
+
+// LineError is a line of a file that can't be imported, with every reason.
+type LineError struct {
+	Line   int                  `json:"line"`
+	Errors []problem.FieldError `json:"errors"`
+}
+
+func (le *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", le.Line, le.Errors[0].Message)
+}
+
+// Report is the outcome of an import so far. Valid rows are imported, unless
+// it is a dry run.
+type Report struct {
+	DryRun    bool        `json:"dry_run"`
+	Rows      int         `json:"rows"`
+	Valid     int         `json:"valid"`
+	Imported  int         `json:"imported"`
+	Failed    int         `json:"failed"`
+	Errors    []LineError `json:"errors"`
+	Truncated bool        `json:"errors_truncated,omitempty"`
+}
+
+type Options struct {
+	DryRun    bool
+	BatchSize int
+	Limits    models.Limits
+	// Schema is the custom fields schema of the team, empty when the team has
+	// none and custom fields aren't allowed.
+	Schema models.CustomFieldSchema
+	// Location reads the dates without zone.
+	Location *time.Location
+	// WorkerId and WorkerName are the worker of the rows without one.
+	WorkerId   string
+	WorkerName string
+	// Resume is the report of an import of the same file that stopped. Its
+	// rows are skipped and the report goes on from it.
+	Resume *Report
+	// BeforeBatch, when not nil, is called before each batch is inserted, an
+	// error stops the import.
+	BeforeBatch func() error
+}
+
+// Importer creates tasks from files, with the rules of task requests.
+type Importer struct {
+	repo  repositories.Repository
+	users repositories.UsersRepository
+	ce    encryption.CryptoEngine
+	index encryption.BlindIndex
+}
+
+func NewImporter(repo repositories.Repository, users repositories.UsersRepository, ce encryption.CryptoEngine, index encryption.BlindIndex) *Importer {
+	return &Importer{
+		repo:  repo,
+		users: users,
+		ce:    ce,
+		index: index,
+	}
+}
+
+// Import reads the rows of a CSV file, validates them and inserts the valid
+// ones in batches. Invalid lines are reported and skipped. onBatch, when not
+// nil, is called after each batch with the report so far and the tasks
+// inserted. Errors of the database stop the import, the batches before stay
+// and the last report given to onBatch is what was imported.
+func (im *Importer) Import(r io.Reader, mapping Mapping, opts Options, onBatch func(Report, []models.Task)) (Report, error) {
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DEFAULT_BATCH_SIZE
+	}
+
+	report := Report{DryRun: opts.DryRun, Errors: make([]LineError, 0)}
+	if opts.Resume != nil {
+		report = *opts.Resume
+		report.DryRun = opts.DryRun
+		report.Errors = append(make([]LineError, 0, len(report.Errors)), report.Errors...)
+	}
+	skip := report.Rows
+
+	reader, err := NewReader(r, mapping)
+	if err != nil {
+		return report, err
+	}
+
+	names := make(map[string]string)
+	batch := make([]models.Task, 0, opts.BatchSize)
+
+	flush := func() error {
+		if !opts.DryRun && len(batch) > 0 {
+			if opts.BeforeBatch != nil {
+				if err := opts.BeforeBatch(); err != nil {
+					return err
+				}
+			}
+			if err := im.repo.CreateTasks(batch); err != nil {
+				return err
+			}
+			report.Imported += len(batch)
+		}
+		if onBatch != nil {
+			onBatch(report, batch)
+		}
+		batch = make([]models.Task, 0, opts.BatchSize)
+		return nil
+	}
+
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		le, invalid := err.(*LineError)
+		if err != nil && !invalid {
+			return report, err
+		}
+
+		// rows of the resumed import were imported or reported already
+		if skip > 0 {
+			skip--
+			continue
+		}
+
+		report.Rows++
+		if invalid {
+			report.fail(*le)
+			continue
+		}
+
+		task, errs, err := im.toTask(row, opts, names)
+		if err != nil {
+			return report, err
+		}
+		if len(errs) > 0 {
+			report.fail(LineError{Line: row.Line, Errors: errs})
+			continue
+		}
+
+		report.Valid++
+		batch = append(batch, task)
+
+		if len(batch) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	return report, flush()
+}
+
+// toTask validates a row and builds its task, indexed and encrypted. The
+// violations of the row are returned with a nil error.
+func (im *Importer) toTask(row Row, opts Options, names map[string]string) (models.Task, []problem.FieldError, error) {
+
+	errs := make([]problem.FieldError, 0)
+
+	if err := row.Request.ValidateFields(models.TASK_FIELDS, opts.Limits); err != nil {
+		errs = append(errs, fieldErrors(err)...)
+	}
+
+	switch {
+	case len(row.Request.Custom) == 0:
+	case opts.Schema.Schema == "":
+		errs = append(errs, problem.FieldError{Field: models.FIELD_CUSTOM, Code: "not_allowed", Message: "custom fields are not defined for your team"})
+	default:
+		if err := opts.Schema.Validate(row.Request.Custom); err != nil {
+			errs = append(errs, fieldErrors(err)...)
+		}
+	}
+
+	workerId, workerName := row.WorkerId, row.WorkerName
+	switch {
+	case workerId == "" && workerName != "":
+		errs = append(errs, problem.FieldError{Field: COLUMN_WORKER_ID, Code: "required", Message: "worker_id is required with worker_name"})
+	case workerId == "":
+		workerId, workerName = opts.WorkerId, opts.WorkerName
+	case workerName == "":
+		name, err := im.workerName(workerId, names)
+		if err != nil {
+			return models.Task{}, nil, err
+		}
+		if name == "" {
+			errs = append(errs, problem.FieldError{Field: COLUMN_WORKER_NAME, Code: "required", Message: "worker_name is required for workers that aren't users"})
+		}
+		workerName = name
+	}
+
+	if len(errs) > 0 {
+		return models.Task{}, errs, nil
+	}
+
+	task, err := row.Request.ToTask(workerId, workerName, opts.Location)
+	if err != nil {
+		return models.Task{}, fieldErrors(err), nil
+	}
+
+	task.SearchTokens = search.Tokens(im.index, task.Summary)
+	task.Summary = im.ce.Encrypt(task.Summary)
+
+	return task, nil, nil
+}
+
+// workerName is the nickname of a user, empty for unknown users. Names are
+// kept in names, files have many rows of the same workers.
+func (im *Importer) workerName(id string, names map[string]string) (string, error) {
+
+	if name, ok := names[id]; ok {
+		return name, nil
+	}
+
+	user, err := im.users.GetUserById(id)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return "", err
+	}
+
+	names[id] = user.Nickname
+	return user.Nickname, nil
+}
+
+func (r *Report) fail(le LineError) {
+
+	r.Failed++
+
+	if len(r.Errors) < MAX_REPORTED_ERRORS {
+		r.Errors = append(r.Errors, le)
+		return
+	}
+	r.Truncated = true
+}
+
+// fieldErrors are the violations of a validation error.
+func fieldErrors(err error) []problem.FieldError {
+
+	fes, ok := models.AsFieldErrors(err)
+	if !ok {
+		return []problem.FieldError{{Field: "line", Code: problem.CODE_VALIDATION_FAILED, Message: err.Error()}}
+	}
+
+	errs := make([]problem.FieldError, 0, len(fes))
+	for _, fe := range fes {
+		errs = append(errs, problem.FieldError{Field: fe.Field, Code: fe.Code, Message: fe.Error()})
+	}
+
+	return errs
+}
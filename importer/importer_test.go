@@ -0,0 +1,204 @@
+package importer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var testCryptoEngine = encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+var testBlindIndex = encryption.NewBlindIndex("J8s0pQ2mXv7LcN4rT1wZ6yB3hF9kD5gA")
+
+const testSchema = `{"type": "object", "properties": {"hours": {"type": "number"}}, "additionalProperties": false}`
+
+// fakeRepo keeps the batches of tasks created, the other methods aren't used.
+type fakeRepo struct {
+	repositories.Repository
+	batches [][]models.Task
+	err     error
+}
+
+func (r *fakeRepo) CreateTasks(tasks []models.Task) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.batches = append(r.batches, tasks)
+	return nil
+}
+
+type fakeUsers struct {
+	repositories.UsersRepository
+}
+
+func (u fakeUsers) GetUserById(id string) (models.User, error) {
+	if id == "auth0|1" {
+		return models.User{Id: id, Nickname: "joseph"}, nil
+	}
+	return models.User{}, gorm.ErrRecordNotFound
+}
+
+func testOptions() Options {
+	return Options{
+		BatchSize:  2,
+		Limits:     models.DEFAULT_LIMITS,
+		Schema:     models.CustomFieldSchema{Team: "mocked_team", Schema: testSchema},
+		Location:   time.UTC,
+		WorkerId:   "auth0|manager",
+		WorkerName: "maria",
+	}
+}
+
+const testFile = "summary,date,worker_id,worker_name,custom.hours\n" +
+	"Fixed the pump,2022-05-23T10:00:00Z,auth0|1,,2\n" +
+	"Checked the valves,2022-05-23 03:33:01PM,,,\n" +
+	"No date,,auth0|1,,\n" +
+	"Unknown worker,2022-05-24T10:00:00Z,auth0|2,,\n" +
+	"Named worker,2022-05-24T10:00:00Z,,ana,\n" +
+	"Too many hours,2022-05-24T10:00:00Z,,,a lot\n" +
+	"Legacy worker,2022-05-25T10:00:00Z,legacy|7,Tom,\n"
+
+func TestImportInsertsValidRowsInBatches(t *testing.T) {
+
+	repo := &fakeRepo{}
+	im := NewImporter(repo, fakeUsers{}, testCryptoEngine, testBlindIndex)
+
+	progress := make([]int, 0)
+	report, err := im.Import(strings.NewReader(testFile), Mapping{}, testOptions(), func(report Report, tasks []models.Task) {
+		progress = append(progress, report.Imported)
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, 7, report.Rows)
+	assert.Equal(t, 3, report.Valid)
+	assert.Equal(t, 3, report.Imported)
+	assert.Equal(t, 4, report.Failed)
+	assert.Equal(t, []int{2, 3}, progress)
+
+	lines := make([]int, 0)
+	for _, le := range report.Errors {
+		lines = append(lines, le.Line)
+	}
+	assert.Equal(t, []int{4, 5, 6, 7}, lines)
+	assert.Equal(t, "date", report.Errors[0].Errors[0].Field)
+	assert.Equal(t, "worker_name", report.Errors[1].Errors[0].Field)
+	assert.Equal(t, "worker_id", report.Errors[2].Errors[0].Field)
+	assert.Equal(t, "custom.hours", report.Errors[3].Errors[0].Field)
+
+	if assert.Len(t, repo.batches, 2) {
+		first := repo.batches[0][0]
+		assert.Equal(t, "auth0|1", first.WorkerId)
+		assert.Equal(t, "joseph", first.WorkerName)
+		assert.Equal(t, "Fixed the pump", testCryptoEngine.Decrypt(first.Summary))
+		assert.Equal(t, testBlindIndex.Tokens("Fixed the pump"), first.SearchTokens)
+		assert.Equal(t, models.CustomFields{"hours": float64(2)}, first.Custom)
+
+		// rows without worker belong to the user importing
+		assert.Equal(t, "auth0|manager", repo.batches[0][1].WorkerId)
+		assert.Equal(t, "maria", repo.batches[0][1].WorkerName)
+
+		assert.Equal(t, "Tom", repo.batches[1][0].WorkerName)
+	}
+}
+
+func TestImportDryRunDoesNotWrite(t *testing.T) {
+
+	repo := &fakeRepo{}
+	im := NewImporter(repo, fakeUsers{}, testCryptoEngine, testBlindIndex)
+
+	opts := testOptions()
+	opts.DryRun = true
+
+	report, err := im.Import(strings.NewReader(testFile), Mapping{}, opts, nil)
+	assert.Nil(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 3, report.Valid)
+	assert.Equal(t, 0, report.Imported)
+	assert.Equal(t, 4, report.Failed)
+	assert.Empty(t, repo.batches)
+}
+
+func TestImportRejectsCustomFieldsWithoutSchema(t *testing.T) {
+
+	im := NewImporter(&fakeRepo{}, fakeUsers{}, testCryptoEngine, testBlindIndex)
+
+	opts := testOptions()
+	opts.Schema = models.CustomFieldSchema{}
+
+	report, err := im.Import(strings.NewReader("summary,date,custom.hours\nx,2022-05-23T10:00:00Z,2\n"), Mapping{}, opts, nil)
+	assert.Nil(t, err)
+	if assert.Len(t, report.Errors, 1) {
+		assert.Equal(t, "not_allowed", report.Errors[0].Errors[0].Code)
+	}
+}
+
+func TestImportStopsOnDatabaseErrors(t *testing.T) {
+
+	repo := &fakeRepo{err: errors.New("connection lost")}
+	im := NewImporter(repo, fakeUsers{}, testCryptoEngine, testBlindIndex)
+
+	report, err := im.Import(strings.NewReader(testFile), Mapping{}, testOptions(), nil)
+	assert.EqualError(t, err, "connection lost")
+	assert.Equal(t, 0, report.Imported)
+}
+
+func TestImportResumesAfterTheRowsOfTheReport(t *testing.T) {
+
+	repo := &fakeRepo{}
+	im := NewImporter(repo, fakeUsers{}, testCryptoEngine, testBlindIndex)
+
+	// the first batch of the file was imported before the import stopped
+	opts := testOptions()
+	opts.Resume = &Report{Rows: 2, Valid: 2, Imported: 2, Errors: make([]LineError, 0)}
+
+	report, err := im.Import(strings.NewReader(testFile), Mapping{}, opts, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 7, report.Rows)
+	assert.Equal(t, 3, report.Valid)
+	assert.Equal(t, 3, report.Imported)
+	assert.Equal(t, 4, report.Failed)
+	assert.Equal(t, 4, report.Errors[0].Line)
+	assert.Empty(t, opts.Resume.Errors)
+	if assert.Len(t, repo.batches, 1) {
+		assert.Len(t, repo.batches[0], 1)
+		assert.Equal(t, "Tom", repo.batches[0][0].WorkerName)
+	}
+}
+
+func TestImportStopsWhenBeforeBatchFails(t *testing.T) {
+
+	repo := &fakeRepo{}
+	im := NewImporter(repo, fakeUsers{}, testCryptoEngine, testBlindIndex)
+
+	batches := 0
+	opts := testOptions()
+	opts.BeforeBatch = func() error {
+		if batches == 1 {
+			return errors.New("job lost")
+		}
+		batches++
+		return nil
+	}
+
+	_, err := im.Import(strings.NewReader(testFile), Mapping{}, opts, nil)
+	assert.EqualError(t, err, "job lost")
+	assert.Len(t, repo.batches, 1)
+}
+
+func TestReportListsTheFirstErrors(t *testing.T) {
+
+	var report Report
+	for i := 0; i <= MAX_REPORTED_ERRORS; i++ {
+		report.fail(LineError{Line: i + 2})
+	}
+
+	assert.Equal(t, MAX_REPORTED_ERRORS+1, report.Failed)
+	assert.Len(t, report.Errors, MAX_REPORTED_ERRORS)
+	assert.True(t, report.Truncated)
+}
@@ -0,0 +1,242 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofrs/uuid"
+)
+
+const JOB_PENDING = "pending"
+const JOB_RUNNING = "running"
+const JOB_DONE = "done"
+const JOB_FAILED = "failed"
+
+// JOB_RESUMED is a failed job another job resumed, it can't be resumed again.
+const JOB_RESUMED = "resumed"
+
+// JOB_WATCH_RETRIES is how many times a change of a job is tried again when
+// the job changed meanwhile.
+const JOB_WATCH_RETRIES = 3
+
+// JOB_TTL is how long jobs are kept after their last update.
+const JOB_TTL = 24 * time.Hour
+const JOB_KEY_PREFIX = "import:job:"
+
+// JOB_STALE_AFTER is how long a job can go without an update before it is
+// taken as stopped, its instance crashed or restarted. Jobs are updated after
+// every batch.
+const JOB_STALE_AFTER = 10 * time.Minute
+
+// JOB_STALE_ERROR is the error of the jobs that stopped.
+const JOB_STALE_ERROR = "the import stopped before the end of the file"
+
+var ErrJobNotFound = errors.New("import job not found")
+var ErrJobNotResumable = errors.New("only failed import jobs can be resumed, once")
+var ErrJobLost = errors.New("import job stopped running, it failed or was resumed")
+
+// Job is an import running in the background. Report is its progress, and
+// Error why it failed. Digest is the SHA-256 of the file, an import resuming
+// the job must send the same one, and ResumedBy is the job that resumed it.
+type Job struct {
+	Id        uuid.UUID  `json:"id"`
+	Status    string     `json:"status"`
+	CreatedBy string     `json:"created_by"`
+	Digest    string     `json:"digest"`
+	Report    Report     `json:"report"`
+	Error     string     `json:"error,omitempty"`
+	ResumedBy *uuid.UUID `json:"resumed_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Jobs keeps the import jobs in Redis, so any instance can report them.
+type Jobs struct {
+	rclient *redis.Client
+	ttl     time.Duration
+}
+
+func NewJobs(rclient *redis.Client, ttl time.Duration) *Jobs {
+	return &Jobs{
+		rclient: rclient,
+		ttl:     ttl,
+	}
+}
+
+// Create stores a new pending job of the user, importing the file of digest.
+// report is where the import starts, empty unless it resumes another job.
+func (j *Jobs) Create(ctx context.Context, createdBy string, digest string, report Report) (Job, error) {
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return Job{}, err
+	}
+
+	now := time.Now().UTC()
+	job := Job{
+		Id:        id,
+		Status:    JOB_PENDING,
+		CreatedBy: createdBy,
+		Digest:    digest,
+		Report:    report,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	return job, j.Save(ctx, job)
+}
+
+// Save stores the job as it is now.
+func (j *Jobs) Save(ctx context.Context, job Job) error {
+
+	job.UpdatedAt = time.Now().UTC()
+
+	value, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	return j.rclient.Set(ctx, JOB_KEY_PREFIX+job.Id.String(), value, j.ttl).Err()
+}
+
+// Get reads a job, ErrJobNotFound when it doesn't exist or expired. Pending
+// or running jobs without updates for JOB_STALE_AFTER are failed, their
+// report is what was imported.
+func (j *Jobs) Get(ctx context.Context, id uuid.UUID) (Job, error) {
+
+	job, err := j.read(ctx, j.rclient, id)
+	if err != nil {
+		return Job{}, err
+	}
+
+	if job.stale(time.Now()) {
+		return j.watch(ctx, id, func(current Job) (Job, error) {
+			return current, nil
+		})
+	}
+
+	return job, nil
+}
+
+// Update saves the progress of a running job, ErrJobLost when it stopped
+// running meanwhile: it went stale and failed, and may have been resumed.
+func (j *Jobs) Update(ctx context.Context, job Job) error {
+
+	_, err := j.watch(ctx, job.Id, func(current Job) (Job, error) {
+		if current.Status != JOB_PENDING && current.Status != JOB_RUNNING {
+			return Job{}, ErrJobLost
+		}
+		return job, nil
+	})
+
+	return err
+}
+
+// Running is ErrJobLost when the job no longer runs, its import must stop.
+func (j *Jobs) Running(ctx context.Context, id uuid.UUID) error {
+
+	job, err := j.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job.Status != JOB_RUNNING {
+		return ErrJobLost
+	}
+
+	return nil
+}
+
+// Claim marks a failed job resumed by the job resumedBy. A job is only
+// claimed once, ErrJobNotResumable otherwise.
+func (j *Jobs) Claim(ctx context.Context, id uuid.UUID, resumedBy uuid.UUID) (Job, error) {
+
+	job, err := j.watch(ctx, id, func(current Job) (Job, error) {
+		if current.Status != JOB_FAILED {
+			return Job{}, ErrJobNotResumable
+		}
+		current.Status = JOB_RESUMED
+		current.ResumedBy = &resumedBy
+		return current, nil
+	})
+	if err == redis.TxFailedErr {
+		return Job{}, ErrJobNotResumable
+	}
+
+	return job, err
+}
+
+// Delete removes a job that never started.
+func (j *Jobs) Delete(ctx context.Context, id uuid.UUID) error {
+	return j.rclient.Del(ctx, JOB_KEY_PREFIX+id.String()).Err()
+}
+
+// watch changes a job atomically. change gets the stored job, failed when
+// stale, and returns the job to store or an error that leaves it as it is.
+func (j *Jobs) watch(ctx context.Context, id uuid.UUID, change func(Job) (Job, error)) (Job, error) {
+
+	key := JOB_KEY_PREFIX + id.String()
+
+	var saved Job
+	var err error
+	for i := 0; i < JOB_WATCH_RETRIES; i++ {
+		err = j.rclient.Watch(ctx, func(tx *redis.Tx) error {
+
+			current, err := j.read(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+
+			now := time.Now().UTC()
+			if current.stale(now) {
+				current.Status = JOB_FAILED
+				current.Error = JOB_STALE_ERROR
+			}
+
+			saved, err = change(current)
+			if err != nil {
+				return err
+			}
+			saved.UpdatedAt = now
+
+			value, err := json.Marshal(saved)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				return pipe.Set(ctx, key, value, j.ttl).Err()
+			})
+			return err
+		}, key)
+
+		if err != redis.TxFailedErr {
+			break
+		}
+	}
+	if err != nil {
+		return Job{}, err
+	}
+
+	return saved, nil
+}
+
+func (j *Jobs) read(ctx context.Context, client redis.Cmdable, id uuid.UUID) (Job, error) {
+
+	value, err := client.Get(ctx, JOB_KEY_PREFIX+id.String()).Bytes()
+	if err == redis.Nil {
+		return Job{}, ErrJobNotFound
+	}
+	if err != nil {
+		return Job{}, err
+	}
+
+	var job Job
+	return job, json.Unmarshal(value, &job)
+}
+
+func (job Job) stale(now time.Time) bool {
+	return (job.Status == JOB_PENDING || job.Status == JOB_RUNNING) && now.Sub(job.UpdatedAt) > JOB_STALE_AFTER
+}
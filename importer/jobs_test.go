@@ -0,0 +1,130 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobsCreateSaveAndGet(t *testing.T) {
+	mr := miniredis.RunT(t)
+	jobs := NewJobs(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Hour)
+	ctx := context.Background()
+
+	job, err := jobs.Create(ctx, "auth0|manager", "digest", Report{DryRun: true})
+	assert.Nil(t, err)
+	assert.Equal(t, JOB_PENDING, job.Status)
+	assert.Equal(t, "digest", job.Digest)
+	assert.True(t, job.Report.DryRun)
+
+	job.Status = JOB_RUNNING
+	job.Report.Rows = 200
+	assert.Nil(t, jobs.Save(ctx, job))
+
+	saved, err := jobs.Get(ctx, job.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, JOB_RUNNING, saved.Status)
+	assert.Equal(t, 200, saved.Report.Rows)
+	assert.Equal(t, "auth0|manager", saved.CreatedBy)
+
+	// jobs expire after their last update
+	mr.FastForward(time.Hour)
+	_, err = jobs.Get(ctx, job.Id)
+	assert.Equal(t, ErrJobNotFound, err)
+
+	_, err = jobs.Get(ctx, uuid.Must(uuid.NewV4()))
+	assert.Equal(t, ErrJobNotFound, err)
+}
+
+func TestJobsGetFailsStaleJobs(t *testing.T) {
+	mr := miniredis.RunT(t)
+	jobs := NewJobs(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Hour)
+	ctx := context.Background()
+
+	job, err := jobs.Create(ctx, "auth0|manager", "digest", Report{Rows: 200, Imported: 200})
+	assert.Nil(t, err)
+
+	saved, err := jobs.Get(ctx, job.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, JOB_PENDING, saved.Status)
+
+	// the instance running the job stopped updating it
+	job.Status = JOB_RUNNING
+	job.UpdatedAt = time.Now().UTC().Add(-JOB_STALE_AFTER - time.Minute)
+	value, _ := json.Marshal(job)
+	mr.Set(JOB_KEY_PREFIX+job.Id.String(), string(value))
+
+	saved, err = jobs.Get(ctx, job.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, JOB_FAILED, saved.Status)
+	assert.Equal(t, JOB_STALE_ERROR, saved.Error)
+	assert.Equal(t, 200, saved.Report.Imported)
+
+	// the failure is kept
+	saved, err = jobs.Get(ctx, job.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, JOB_FAILED, saved.Status)
+}
+
+func TestJobsClaimFailedJobsOnce(t *testing.T) {
+	mr := miniredis.RunT(t)
+	jobs := NewJobs(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Hour)
+	ctx := context.Background()
+
+	job, err := jobs.Create(ctx, "auth0|manager", "digest", Report{})
+	assert.Nil(t, err)
+	resumedBy := uuid.Must(uuid.NewV4())
+
+	// running jobs can't be resumed
+	_, err = jobs.Claim(ctx, job.Id, resumedBy)
+	assert.Equal(t, ErrJobNotResumable, err)
+
+	job.Status = JOB_FAILED
+	assert.Nil(t, jobs.Save(ctx, job))
+
+	claimed, err := jobs.Claim(ctx, job.Id, resumedBy)
+	assert.Nil(t, err)
+	assert.Equal(t, JOB_RESUMED, claimed.Status)
+	assert.Equal(t, &resumedBy, claimed.ResumedBy)
+
+	_, err = jobs.Claim(ctx, job.Id, uuid.Must(uuid.NewV4()))
+	assert.Equal(t, ErrJobNotResumable, err)
+
+	_, err = jobs.Claim(ctx, uuid.Must(uuid.NewV4()), resumedBy)
+	assert.Equal(t, ErrJobNotFound, err)
+}
+
+func TestJobsUpdateOnlyRunningJobs(t *testing.T) {
+	mr := miniredis.RunT(t)
+	jobs := NewJobs(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Hour)
+	ctx := context.Background()
+
+	job, err := jobs.Create(ctx, "auth0|manager", "digest", Report{})
+	assert.Nil(t, err)
+
+	job.Status = JOB_RUNNING
+	assert.Nil(t, jobs.Update(ctx, job))
+	assert.Nil(t, jobs.Running(ctx, job.Id))
+
+	// the job went stale and was resumed, its import must stop
+	failed := job
+	failed.Status = JOB_FAILED
+	assert.Nil(t, jobs.Save(ctx, failed))
+	_, err = jobs.Claim(ctx, job.Id, uuid.Must(uuid.NewV4()))
+	assert.Nil(t, err)
+
+	job.Report.Rows = 200
+	assert.Equal(t, ErrJobLost, jobs.Update(ctx, job))
+	assert.Equal(t, ErrJobLost, jobs.Running(ctx, job.Id))
+
+	saved, err := jobs.Get(ctx, job.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, JOB_RESUMED, saved.Status)
+	assert.Equal(t, 0, saved.Report.Rows)
+}
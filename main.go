@@ -45,7 +45,7 @@ func buildDSN() string {
 		panic("missing env var: " + ENV_MYSQL_DB)
 	}
 
-	return mysqlUsername + ":" + mysqlPassword + "@(" + mysqlHost + ":" + mysqlPort + ")/" + mysqlDB + "?charset=utf8mb4&parseTime=True&loc=Local"
+	return mysqlUsername + ":" + mysqlPassword + "@(" + mysqlHost + ":" + mysqlPort + ")/" + mysqlDB + "?charset=utf8mb4&parseTime=True&loc=UTC"
 }
 
 func main() {
@@ -0,0 +1,189 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+)
+
+const escapable = "\\`*_[]()#+-.!>"
+
+type nodeKind int
+
+const (
+	textNode nodeKind = iota
+	strongNode
+	emphasisNode
+	codeNode
+	linkNode
+)
+
+// node is an inline element. Text and code nodes carry text, the others
+// carry children.
+type node struct {
+	kind     nodeKind
+	text     string
+	href     string
+	children []node
+}
+
+// parseInline parses the inline elements of a line.
+func parseInline(s string) []node {
+
+	runes := []rune(s)
+	nodes := make([]node, 0)
+	var text strings.Builder
+
+	emit := func(n node) {
+		if text.Len() > 0 {
+			nodes = append(nodes, node{kind: textNode, text: text.String()})
+			text.Reset()
+		}
+		nodes = append(nodes, n)
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune(escapable, runes[i+1]):
+			text.WriteRune(runes[i+1])
+			i++
+			continue
+
+		case r == '`':
+			if end := indexRune(runes, i+1, '`'); end > i+1 {
+				emit(node{kind: codeNode, text: string(runes[i+1 : end])})
+				i = end
+				continue
+			}
+
+		case (r == '*' || r == '_') && hasPrefix(runes, i, r, r) && opens(runes, i+2):
+			if end := closingDelimiter(runes, i+2, []rune{r, r}); end > i+2 {
+				emit(node{kind: strongNode, children: parseInline(string(runes[i+2 : end]))})
+				i = end + 1
+				continue
+			}
+
+		case r == '*' || r == '_':
+			if !opens(runes, i+1) || (r == '_' && i > 0 && isWord(runes[i-1])) {
+				break
+			}
+			if end := closingDelimiter(runes, i+1, []rune{r}); end > i+1 {
+				emit(node{kind: emphasisNode, children: parseInline(string(runes[i+1 : end]))})
+				i = end
+				continue
+			}
+
+		case r == '[':
+			if link, end, ok := parseLink(runes, i); ok {
+				emit(link)
+				i = end
+				continue
+			}
+		}
+
+		text.WriteRune(r)
+	}
+
+	if text.Len() > 0 {
+		nodes = append(nodes, node{kind: textNode, text: text.String()})
+	}
+
+	return nodes
+}
+
+// parseLink parses [text](url) at start, it returns the index of the closing
+// parenthesis.
+func parseLink(runes []rune, start int) (node, int, bool) {
+
+	closeText := indexRune(runes, start+1, ']')
+	if closeText < 0 || closeText+1 >= len(runes) || runes[closeText+1] != '(' {
+		return node{}, 0, false
+	}
+
+	// urls may contain balanced parentheses
+	closeHref := -1
+	depth := 0
+	for i := closeText + 2; i < len(runes) && closeHref < 0; i++ {
+		switch runes[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				closeHref = i
+			}
+			depth--
+		}
+	}
+	if closeHref < 0 {
+		return node{}, 0, false
+	}
+
+	return node{
+		kind:     linkNode,
+		href:     strings.TrimSpace(string(runes[closeText+2 : closeHref])),
+		children: parseInline(string(runes[start+1 : closeText])),
+	}, closeHref, true
+}
+
+// closingDelimiter finds the delimiter that closes an element opened before
+// from. Underscores don't close inside words, so snake_case is left alone.
+func closingDelimiter(runes []rune, from int, delimiter []rune) int {
+
+	for i := from; i+len(delimiter) <= len(runes); i++ {
+		if runes[i] == '\\' {
+			i++
+			continue
+		}
+		if !hasPrefix(runes, i, delimiter...) {
+			continue
+		}
+		// a single delimiter must not be half of a double one
+		if len(delimiter) == 1 && hasPrefix(runes, i+1, delimiter[0]) {
+			i++
+			continue
+		}
+		// closing delimiters follow text, not spaces
+		if unicode.IsSpace(runes[i-1]) {
+			continue
+		}
+		next := i + len(delimiter)
+		if delimiter[0] == '_' && next < len(runes) && isWord(runes[next]) {
+			continue
+		}
+		return i
+	}
+
+	return -1
+}
+
+// opens tells whether a delimiter ending before at opens an element, it must
+// be followed by text.
+func opens(runes []rune, at int) bool {
+	return at < len(runes) && !unicode.IsSpace(runes[at])
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasPrefix(runes []rune, at int, prefix ...rune) bool {
+	if at+len(prefix) > len(runes) {
+		return false
+	}
+	for i, r := range prefix {
+		if runes[at+i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
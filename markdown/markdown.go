@@ -0,0 +1,101 @@
+// Package markdown renders the Markdown dialect of task summaries.
+//
+// The dialect supports:
+//   - headings: lines starting with one to six "#" and a space
+//   - lists: lines starting with "- ", "* " or "+ ", or with "1. " for ordered lists
+//   - paragraphs: lines separated by blank lines, single line breaks are kept
+//   - inline: **bold**, __bold__, *italic*, _italic_, `code` and [text](url)
+//   - backslash escapes of the markup characters
+//
+// Raw HTML is not part of the dialect, it is escaped like any other text. The
+// rendered HTML only contains the tags above and links with http, https or
+// mailto urls, so it is safe to embed in a page.
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+var headingLine = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)[ \t#]*$`)
+var bulletLine = regexp.MustCompile(`^[ \t]*[-*+][ \t]+(.*)$`)
+var orderedLine = regexp.MustCompile(`^[ \t]*[0-9]{1,9}[.)][ \t]+(.*)$`)
+
+type blockKind int
+
+const (
+	paragraphBlock blockKind = iota
+	headingBlock
+	bulletListBlock
+	orderedListBlock
+)
+
+// block is a heading, a paragraph or a list. Lines are the lines of a
+// paragraph, the items of a list or the single line of a heading.
+type block struct {
+	kind  blockKind
+	level int
+	lines []string
+}
+
+// parseBlocks splits the source into blocks.
+func parseBlocks(src string) []block {
+
+	blocks := make([]block, 0)
+	var current *block
+
+	flush := func() {
+		if current != nil {
+			blocks = append(blocks, *current)
+			current = nil
+		}
+	}
+
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	for _, line := range strings.Split(src, "\n") {
+
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		if m := headingLine.FindStringSubmatch(line); m != nil {
+			flush()
+			blocks = append(blocks, block{kind: headingBlock, level: len(m[1]), lines: []string{m[2]}})
+			continue
+		}
+
+		if m := bulletLine.FindStringSubmatch(line); m != nil {
+			if current == nil || current.kind != bulletListBlock {
+				flush()
+				current = &block{kind: bulletListBlock}
+			}
+			current.lines = append(current.lines, m[1])
+			continue
+		}
+
+		if m := orderedLine.FindStringSubmatch(line); m != nil {
+			if current == nil || current.kind != orderedListBlock {
+				flush()
+				current = &block{kind: orderedListBlock}
+			}
+			current.lines = append(current.lines, m[1])
+			continue
+		}
+
+		// lines that follow a list item continue it
+		if current != nil && current.kind != paragraphBlock {
+			last := len(current.lines) - 1
+			current.lines[last] += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		if current == nil {
+			current = &block{kind: paragraphBlock}
+		}
+		current.lines = append(current.lines, strings.TrimSpace(line))
+	}
+	flush()
+
+	return blocks
+}
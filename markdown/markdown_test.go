@@ -0,0 +1,65 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToHTMLBlocks(t *testing.T) {
+
+	src := "# Boiler service\n\nReplaced the **pump** and *cleaned* the filter\nTested for 2h\n\n- valve\n- gasket\n\n1. drain\n2. refill"
+
+	expected := "<h1>Boiler service</h1>\n" +
+		"<p>Replaced the <strong>pump</strong> and <em>cleaned</em> the filter<br>\nTested for 2h</p>\n" +
+		"<ul>\n<li>valve</li>\n<li>gasket</li>\n</ul>\n" +
+		"<ol>\n<li>drain</li>\n<li>refill</li>\n</ol>\n"
+
+	assert.Equal(t, expected, ToHTML(src))
+}
+
+func TestToHTMLInline(t *testing.T) {
+
+	assert.Equal(t, "<p><strong>bold</strong> __ <em>it</em></p>\n", ToHTML("__bold__ __ _it_"))
+	assert.Equal(t, "<p><code>a *b*</code></p>\n", ToHTML("`a *b*`"))
+	assert.Equal(t, "<p>work_order_id stays</p>\n", ToHTML("work_order_id stays"))
+	assert.Equal(t, "<p>*not italic*</p>\n", ToHTML(`\*not italic\*`))
+	assert.Equal(t, "<p>**unclosed</p>\n", ToHTML("**unclosed"))
+	assert.Equal(t, "<p><em>a <strong>b</strong> c</em></p>\n", ToHTML("*a **b** c*"))
+}
+
+func TestToHTMLEscapesRawHTML(t *testing.T) {
+
+	assert.Equal(t,
+		"<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; <strong>&lt;b&gt;</strong></p>\n",
+		ToHTML(`<script>alert("x")</script> & **<b>**`))
+
+	assert.Equal(t, "<p><code>&lt;img src=x onerror=alert(1)&gt;</code></p>\n", ToHTML("`<img src=x onerror=alert(1)>`"))
+}
+
+func TestToHTMLLinks(t *testing.T) {
+
+	assert.Equal(t,
+		`<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer">manual</a></p>`+"\n",
+		ToHTML("[manual](https://example.com/a?b=1&c=2)"))
+
+	// unsafe schemes and relative urls keep only the text
+	assert.Equal(t, "<p>click</p>\n", ToHTML("[click](javascript:alert(1))"))
+	assert.Equal(t, "<p>click</p>\n", ToHTML("[click](JavaScript:alert(1))"))
+	assert.Equal(t, "<p>click</p>\n", ToHTML("[click](/tasks)"))
+	assert.Equal(t, `<p><a href="https://x.y/&#34;onclick=&#34;" rel="nofollow noopener noreferrer">q</a></p>`+"\n", ToHTML(`[q](https://x.y/"onclick=")`))
+}
+
+func TestToText(t *testing.T) {
+
+	src := "## Boiler *service*\n\nReplaced the **pump**, see [manual](https://example.com)\n\n* valve\n* gasket\n\n1. drain\n1. refill"
+
+	expected := "Boiler service\n\n" +
+		"Replaced the pump, see manual (https://example.com)\n\n" +
+		"- valve\n- gasket\n\n" +
+		"1. drain\n2. refill"
+
+	assert.Equal(t, expected, ToText(src))
+	assert.Equal(t, "plain summary", ToText("plain summary"))
+	assert.Equal(t, "", ToText(""))
+}
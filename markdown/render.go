@@ -0,0 +1,136 @@
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SAFE_URL_SCHEMES are the schemes links may use, other links are rendered
+// as their text.
+var SAFE_URL_SCHEMES = []string{"http", "https", "mailto"}
+
+// ToHTML renders the source as sanitized HTML.
+func ToHTML(src string) string {
+
+	var b strings.Builder
+
+	for _, bl := range parseBlocks(src) {
+		switch bl.kind {
+		case headingBlock:
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", bl.level, inlineHTML(parseInline(bl.lines[0])), bl.level)
+
+		case bulletListBlock, orderedListBlock:
+			tag := "ul"
+			if bl.kind == orderedListBlock {
+				tag = "ol"
+			}
+			b.WriteString("<" + tag + ">\n")
+			for _, item := range bl.lines {
+				b.WriteString("<li>" + inlineHTML(parseInline(item)) + "</li>\n")
+			}
+			b.WriteString("</" + tag + ">\n")
+
+		default:
+			lines := make([]string, 0, len(bl.lines))
+			for _, line := range bl.lines {
+				lines = append(lines, inlineHTML(parseInline(line)))
+			}
+			b.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+		}
+	}
+
+	return b.String()
+}
+
+// ToText renders the source as plain text without markup, for exports and
+// notifications. Links keep their url after the text.
+func ToText(src string) string {
+
+	blocks := make([]string, 0)
+
+	for _, bl := range parseBlocks(src) {
+		lines := make([]string, 0, len(bl.lines))
+		for i, line := range bl.lines {
+			text := inlineText(parseInline(line))
+			switch bl.kind {
+			case bulletListBlock:
+				text = "- " + text
+			case orderedListBlock:
+				text = strconv.Itoa(i+1) + ". " + text
+			}
+			lines = append(lines, text)
+		}
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+
+	return strings.Join(blocks, "\n\n")
+}
+
+func inlineHTML(nodes []node) string {
+
+	var b strings.Builder
+
+	for _, n := range nodes {
+		switch n.kind {
+		case strongNode:
+			b.WriteString("<strong>" + inlineHTML(n.children) + "</strong>")
+		case emphasisNode:
+			b.WriteString("<em>" + inlineHTML(n.children) + "</em>")
+		case codeNode:
+			b.WriteString("<code>" + html.EscapeString(n.text) + "</code>")
+		case linkNode:
+			if !isSafeURL(n.href) {
+				b.WriteString(inlineHTML(n.children))
+				continue
+			}
+			fmt.Fprintf(&b, `<a href="%s" rel="nofollow noopener noreferrer">%s</a>`, html.EscapeString(n.href), inlineHTML(n.children))
+		default:
+			b.WriteString(html.EscapeString(n.text))
+		}
+	}
+
+	return b.String()
+}
+
+func inlineText(nodes []node) string {
+
+	var b strings.Builder
+
+	for _, n := range nodes {
+		switch n.kind {
+		case strongNode, emphasisNode:
+			b.WriteString(inlineText(n.children))
+		case linkNode:
+			text := inlineText(n.children)
+			b.WriteString(text)
+			if n.href != "" && n.href != text {
+				b.WriteString(" (" + n.href + ")")
+			}
+		default:
+			b.WriteString(n.text)
+		}
+	}
+
+	return b.String()
+}
+
+// isSafeURL only accepts absolute urls with one of SAFE_URL_SCHEMES.
+func isSafeURL(href string) bool {
+
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	for _, safe := range SAFE_URL_SCHEMES {
+		if scheme == safe {
+			return true
+		}
+	}
+
+	return false
+}
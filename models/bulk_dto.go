@@ -0,0 +1,92 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/MrBolas/SupervisorAPI/problem"
+	"github.com/gofrs/uuid"
+)
+
+const MAX_BULK_OPERATIONS = 100
+
+const FIELD_OPERATIONS = "operations"
+
+const BULK_CREATE = "create"
+const BULK_UPDATE = "update"
+const BULK_DELETE = "delete"
+
+type BulkOperation struct {
+	Op      string       `json:"op"`
+	Id      uuid.UUID    `json:"id,omitempty"`
+	Version int          `json:"version,omitempty"`
+	Task    *TaskRequest `json:"task,omitempty"`
+}
+
+type BulkRequest struct {
+	Atomic     bool            `json:"atomic"`
+	Operations []BulkOperation `json:"operations"`
+}
+
+type BulkResult struct {
+	Index  int              `json:"index"`
+	Op     string           `json:"op"`
+	Id     *uuid.UUID       `json:"id,omitempty"`
+	Status int              `json:"status"`
+	Error  *problem.Problem `json:"error,omitempty"`
+	Task   *TaskResponse    `json:"task,omitempty"`
+}
+
+type BulkResponse struct {
+	Atomic    bool         `json:"atomic"`
+	Committed bool         `json:"committed"`
+	Results   []BulkResult `json:"results"`
+}
+
+// Validate checks the shape of the bulk request and reports all the invalid
+// operations, the tasks of the operations are validated one by one when they
+// are executed.
+func (br *BulkRequest) Validate() error {
+
+	err := Validate(
+		map[string]interface{}{FIELD_OPERATIONS: br.Operations},
+		Rules{FIELD_OPERATIONS: {NotEmpty(), MaxItems(MAX_BULK_OPERATIONS)}},
+		[]string{FIELD_OPERATIONS})
+	if err != nil {
+		return err
+	}
+
+	errs := make(FieldErrors, 0)
+	for i, op := range br.Operations {
+		if fieldErrors, ok := AsFieldErrors(op.Validate()); ok {
+			errs = append(errs, fieldErrors.WithPrefix(fmt.Sprintf("%s[%d]", FIELD_OPERATIONS, i))...)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+func (bo *BulkOperation) Validate() error {
+
+	fields := []string{"op"}
+	switch bo.Op {
+	case BULK_CREATE:
+		fields = append(fields, "task")
+	case BULK_UPDATE:
+		fields = append(fields, "id", "task")
+	case BULK_DELETE:
+		fields = append(fields, "id")
+	}
+
+	return Validate(
+		map[string]interface{}{"op": bo.Op, "id": bo.Id, "task": bo.Task},
+		Rules{
+			"op":   {OneOf(BULK_CREATE, BULK_UPDATE, BULK_DELETE)},
+			"id":   {Required()},
+			"task": {Required()},
+		},
+		fields)
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkRequestValidation(t *testing.T) {
+
+	tr := TaskRequest{
+		Summary: "mock_request",
+		Date:    "2006-01-02 03:04:05PM",
+	}
+	id := uuid.Must(uuid.NewV4())
+
+	br := BulkRequest{
+		Operations: []BulkOperation{
+			{Op: BULK_CREATE, Task: &tr},
+			{Op: BULK_UPDATE, Id: id, Task: &tr},
+			{Op: BULK_DELETE, Id: id},
+		},
+	}
+	assert.Nil(t, br.Validate())
+
+	br = BulkRequest{}
+	if assert.Error(t, br.Validate()) {
+		assert.EqualError(t, br.Validate(), "operations must not be empty")
+	}
+
+	br = BulkRequest{Operations: []BulkOperation{{Op: BULK_UPDATE, Task: &tr}}}
+	if assert.Error(t, br.Validate()) {
+		assert.EqualError(t, br.Validate(), "operations[0]: id is required")
+	}
+
+	br = BulkRequest{Operations: []BulkOperation{{Op: "upsert", Task: &tr}}}
+	if assert.Error(t, br.Validate()) {
+		assert.EqualError(t, br.Validate(), "operations[0]: op must be one of create, update, delete")
+	}
+
+	// all the invalid operations are reported at once
+	br = BulkRequest{Operations: []BulkOperation{{Op: BULK_DELETE}, {Op: BULK_CREATE, Task: &tr}, {Op: BULK_UPDATE}}}
+	if fieldErrors, ok := AsFieldErrors(br.Validate()); assert.True(t, ok) {
+		assert.Len(t, fieldErrors, 3)
+		assert.Equal(t, "operations[0].id", fieldErrors[0].Field)
+		assert.Equal(t, "operations[2].id", fieldErrors[1].Field)
+		assert.Equal(t, "operations[2].task", fieldErrors[2].Field)
+		assert.Equal(t, "required", fieldErrors[2].Code)
+	}
+
+	br = BulkRequest{Operations: make([]BulkOperation, MAX_BULK_OPERATIONS+1)}
+	if assert.Error(t, br.Validate()) {
+		assert.EqualError(t, br.Validate(), fmt.Sprintf("operations max size is %d", MAX_BULK_OPERATIONS))
+	}
+}
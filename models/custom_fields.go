@@ -0,0 +1,231 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// CUSTOM_FIELD_NAME is the format of custom field names, they are used in
+// query parameters and database JSON paths.
+var CUSTOM_FIELD_NAME = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,63}$`)
+
+// CustomFieldSchema is the JSON Schema the custom fields of the tasks of a
+// team are validated against.
+type CustomFieldSchema struct {
+	Team      string    `gorm:"primary_key;column:team;type:varchar(191)"`
+	Schema    string    `gorm:"column:schema;type:text"`
+	UpdatedBy string    `gorm:"column:updated_by"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+type CustomFieldSchemaResponse struct {
+	Team      string          `json:"team"`
+	Schema    json.RawMessage `json:"schema"`
+	UpdatedBy string          `json:"updated_by"`
+	UpdatedAt string          `json:"updated_at"`
+}
+
+func (cfs *CustomFieldSchema) ToResponse() CustomFieldSchemaResponse {
+	return CustomFieldSchemaResponse{
+		Team:      cfs.Team,
+		Schema:    json.RawMessage(cfs.Schema),
+		UpdatedBy: cfs.UpdatedBy,
+		UpdatedAt: FormatDate(cfs.UpdatedAt, time.UTC),
+	}
+}
+
+// CustomFields are the tenant defined fields of a task, stored as JSON.
+type CustomFields map[string]interface{}
+
+func (cf CustomFields) Value() (driver.Value, error) {
+	if len(cf) == 0 {
+		return nil, nil
+	}
+
+	value, err := json.Marshal(cf)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(value), nil
+}
+
+func (cf *CustomFields) Scan(value interface{}) error {
+
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*cf = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported custom fields value %T", value)
+	}
+
+	if len(data) == 0 {
+		*cf = nil
+		return nil
+	}
+
+	return json.Unmarshal(data, cf)
+}
+
+// Equal compares custom fields by their JSON representation.
+func (cf CustomFields) Equal(other CustomFields) bool {
+	if len(cf) == 0 && len(other) == 0 {
+		return true
+	}
+
+	a, _ := json.Marshal(cf)
+	b, _ := json.Marshal(other)
+	return string(a) == string(b)
+}
+
+// ValidateCustomFieldSchema checks that schema is a valid JSON Schema for an
+// object whose properties have valid custom field names.
+func ValidateCustomFieldSchema(schema string) error {
+
+	var document map[string]interface{}
+	if err := json.Unmarshal([]byte(schema), &document); err != nil {
+		return errors.New("schema must be a JSON object")
+	}
+
+	if document["type"] != "object" {
+		return errors.New("schema type must be object")
+	}
+
+	if properties, ok := document["properties"].(map[string]interface{}); ok {
+		for name := range properties {
+			if !CUSTOM_FIELD_NAME.MatchString(name) {
+				return fmt.Errorf("invalid custom field name %q, use letters, digits and underscores", name)
+			}
+		}
+	}
+
+	if _, err := gojsonschema.NewSchema(newSchemaLoader(schema)); err != nil {
+		return fmt.Errorf("invalid schema: %s", err.Error())
+	}
+
+	return nil
+}
+
+// schemaLoader loads a schema without the documents its references name,
+// which are never fetched: admins write the schemas, and could make the server
+// read any URL or file. References to the definitions of the schema resolve.
+type schemaLoader struct {
+	gojsonschema.JSONLoader
+}
+
+func newSchemaLoader(schema string) gojsonschema.JSONLoader {
+	return schemaLoader{gojsonschema.NewStringLoader(schema)}
+}
+
+func (sl schemaLoader) LoaderFactory() gojsonschema.JSONLoaderFactory {
+	return referenceLoaderFactory{}
+}
+
+// referenceLoaderFactory makes the loaders of the documents of references,
+// they fail without loading them.
+type referenceLoaderFactory struct{}
+
+func (referenceLoaderFactory) New(source string) gojsonschema.JSONLoader {
+	return referenceLoader{gojsonschema.NewReferenceLoader(source)}
+}
+
+type referenceLoader struct {
+	gojsonschema.JSONLoader
+}
+
+func (rl referenceLoader) LoadJSON() (interface{}, error) {
+	return nil, fmt.Errorf("schemas can only reference their own definitions, not %v", rl.JsonSource())
+}
+
+func (rl referenceLoader) LoaderFactory() gojsonschema.JSONLoaderFactory {
+	return referenceLoaderFactory{}
+}
+
+// compiledSchemas are the compiled schemas of the teams, a schema is only
+// compiled again when its team saves another version.
+var compiledSchemas = struct {
+	sync.RWMutex
+	byTeam map[string]compiledSchema
+}{byTeam: make(map[string]compiledSchema)}
+
+type compiledSchema struct {
+	source string
+	schema *gojsonschema.Schema
+}
+
+// compiled is the compiled schema, from the cache while the team keeps the
+// same version.
+func (cfs *CustomFieldSchema) compiled() (*gojsonschema.Schema, error) {
+
+	compiledSchemas.RLock()
+	cached, ok := compiledSchemas.byTeam[cfs.Team]
+	compiledSchemas.RUnlock()
+	if ok && cached.source == cfs.Schema {
+		return cached.schema, nil
+	}
+
+	schema, err := gojsonschema.NewSchema(newSchemaLoader(cfs.Schema))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %s", err.Error())
+	}
+
+	compiledSchemas.Lock()
+	compiledSchemas.byTeam[cfs.Team] = compiledSchema{source: cfs.Schema, schema: schema}
+	compiledSchemas.Unlock()
+
+	return schema, nil
+}
+
+// Validate validates custom fields against the schema and reports all the
+// violations as FieldErrors.
+func (cfs *CustomFieldSchema) Validate(custom CustomFields) error {
+
+	if custom == nil {
+		custom = CustomFields{}
+	}
+
+	schema, err := cfs.compiled()
+	if err != nil {
+		return err
+	}
+
+	result, err := schema.Validate(gojsonschema.NewGoLoader(map[string]interface{}(custom)))
+	if err != nil {
+		return fmt.Errorf("invalid custom fields: %s", err.Error())
+	}
+
+	if result.Valid() {
+		return nil
+	}
+
+	violations := make(FieldErrors, 0)
+	for _, e := range result.Errors() {
+		field := FIELD_CUSTOM
+		if e.Field() != "(root)" {
+			field += "." + e.Field()
+		}
+		violations = append(violations, NewFieldError(field, e.Type(), field+": "+e.Description()))
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Field != violations[j].Field {
+			return violations[i].Field < violations[j].Field
+		}
+		return violations[i].Error() < violations[j].Error()
+	})
+
+	return violations
+}
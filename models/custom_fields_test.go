@@ -0,0 +1,95 @@
+package models
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const mockedSchema = `{"type":"object","properties":{"work_order":{"type":"string"},"meter_reading":{"type":"number","minimum":0}},"required":["work_order"],"additionalProperties":false}`
+
+func TestValidateCustomFieldSchema(t *testing.T) {
+	assert.Nil(t, ValidateCustomFieldSchema(mockedSchema))
+	assert.Error(t, ValidateCustomFieldSchema(`not json`))
+	assert.Error(t, ValidateCustomFieldSchema(`{"type":"string"}`))
+	assert.Error(t, ValidateCustomFieldSchema(`{"type":"object","properties":{"work order":{"type":"string"}}}`))
+}
+
+func TestValidateCustomFieldSchemaNeverFetchesReferences(t *testing.T) {
+
+	fetched := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		w.Write([]byte(`{"type":"string"}`))
+	}))
+	defer server.Close()
+
+	err := ValidateCustomFieldSchema(`{"type":"object","properties":{"work_order":{"$ref":"` + server.URL + `/work_order.json"}}}`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "own definitions")
+	}
+	assert.Error(t, ValidateCustomFieldSchema(`{"type":"object","properties":{"work_order":{"$ref":"file:///etc/passwd"}}}`))
+	assert.Equal(t, 0, fetched)
+
+	local := `{"type":"object","definitions":{"code":{"type":"string"}},"properties":{"work_order":{"$ref":"#/definitions/code"}}}`
+	assert.Nil(t, ValidateCustomFieldSchema(local))
+
+	schema := CustomFieldSchema{Team: "mocked_local_refs", Schema: local}
+	assert.Nil(t, schema.Validate(CustomFields{"work_order": "WO-1"}))
+	assert.Error(t, schema.Validate(CustomFields{"work_order": 1}))
+}
+
+func TestCustomFieldSchemaValidate(t *testing.T) {
+	schema := CustomFieldSchema{Team: "mocked_team", Schema: mockedSchema}
+
+	assert.Nil(t, schema.Validate(CustomFields{"work_order": "WO-1", "meter_reading": 12.5}))
+
+	err := schema.Validate(CustomFields{"meter_reading": -1, "vehicle_plate": "AA-00-BB"})
+	if assert.Error(t, err) {
+		// all violations are reported at once
+		assert.Contains(t, err.Error(), "work_order")
+		assert.Contains(t, err.Error(), "meter_reading")
+		assert.Contains(t, err.Error(), "vehicle_plate")
+	}
+}
+
+func TestCustomFieldSchemaCompilesEachVersionOnce(t *testing.T) {
+	schema := CustomFieldSchema{Team: "compiled_team", Schema: mockedSchema}
+
+	first, err := schema.compiled()
+	assert.Nil(t, err)
+	again, err := schema.compiled()
+	assert.Nil(t, err)
+	assert.Same(t, first, again)
+
+	// another team has a schema of its own
+	other, err := (&CustomFieldSchema{Team: "other_team", Schema: mockedSchema}).compiled()
+	assert.Nil(t, err)
+	assert.NotSame(t, first, other)
+
+	// a new version of the schema is compiled again
+	schema.Schema = `{"type":"object","properties":{"work_order":{"type":"string"}}}`
+	updated, err := schema.compiled()
+	assert.Nil(t, err)
+	assert.NotSame(t, first, updated)
+	assert.Nil(t, schema.Validate(CustomFields{"meter_reading": -1}))
+}
+
+func TestCustomFieldsValueAndScan(t *testing.T) {
+	value, err := CustomFields{"work_order": "WO-1"}.Value()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"work_order":"WO-1"}`, value)
+
+	value, err = CustomFields{}.Value()
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+
+	var cf CustomFields
+	assert.Nil(t, cf.Scan([]byte(`{"work_order":"WO-1"}`)))
+	assert.Equal(t, CustomFields{"work_order": "WO-1"}, cf)
+
+	assert.Nil(t, cf.Scan(nil))
+	assert.Nil(t, cf)
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// STATUS_DONE and STATUS_PENDING_REVIEW are the values of the status custom
+// field the dashboard counts.
+const STATUS_DONE = "done"
+const STATUS_PENDING_REVIEW = "pending_review"
+
+// DUE_DATE_FIELD is the custom field of the day a task is due, as yyyy-mm-dd.
+const DUE_DATE_FIELD = "due_date"
+
+// Dashboard is the summary of the tasks of a team, or of every team.
+type Dashboard struct {
+	TasksToday      int64
+	TasksThisWeek   int64
+	Overdue         int64
+	PendingReview   int64
+	IdleTechnicians []User
+	RecentActivity  []Task
+}
+
+type ActivityResponse struct {
+	TaskId     uuid.UUID `json:"task_id"`
+	WorkerId   string    `json:"worker_id"`
+	WorkerName string    `json:"worker_name"`
+	Date       string    `json:"date"`
+	Version    int       `json:"version"`
+}
+
+type DashboardResponse struct {
+	Team            string             `json:"team,omitempty"`
+	TimeZone        string             `json:"time_zone"`
+	Today           DateBounds         `json:"today"`
+	ThisWeek        DateBounds         `json:"this_week"`
+	TasksToday      int64              `json:"tasks_today"`
+	TasksThisWeek   int64              `json:"tasks_this_week"`
+	Overdue         int64              `json:"overdue"`
+	PendingReview   int64              `json:"pending_review"`
+	IdleTechnicians []UserResponse     `json:"idle_technicians"`
+	RecentActivity  []ActivityResponse `json:"recent_activity"`
+	GeneratedAt     string             `json:"generated_at"`
+}
+
+// ToResponse builds the response of the dashboard, with dates in loc. The
+// feed has no summaries, so they are never cached decrypted.
+func (d *Dashboard) ToResponse(team string, today DateRange, week DateRange, loc *time.Location) DashboardResponse {
+
+	idle := make([]UserResponse, 0, len(d.IdleTechnicians))
+	for _, u := range d.IdleTechnicians {
+		idle = append(idle, u.ToResponse())
+	}
+
+	activity := make([]ActivityResponse, 0, len(d.RecentActivity))
+	for _, t := range d.RecentActivity {
+		activity = append(activity, ActivityResponse{
+			TaskId:     t.Id,
+			WorkerId:   t.WorkerId,
+			WorkerName: t.WorkerName,
+			Date:       FormatDate(t.Date.Time, loc),
+			Version:    t.Version,
+		})
+	}
+
+	return DashboardResponse{
+		Team:            team,
+		TimeZone:        loc.String(),
+		Today:           *today.ToBounds(loc),
+		ThisWeek:        *week.ToBounds(loc),
+		TasksToday:      d.TasksToday,
+		TasksThisWeek:   d.TasksThisWeek,
+		Overdue:         d.Overdue,
+		PendingReview:   d.PendingReview,
+		IdleTechnicians: idle,
+		RecentActivity:  activity,
+		GeneratedAt:     FormatDate(time.Now(), loc),
+	}
+}
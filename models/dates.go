@@ -0,0 +1,52 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidDate = errors.New("invalid date format, use RFC 3339 (2006-01-02T15:04:05Z07:00) or yyyy-mm-dd hh:mm:ssPM")
+var ErrInvalidTimeZone = errors.New("invalid time zone, use an IANA time zone such as Europe/Lisbon")
+
+// ParseDate parses an RFC 3339 date, or a date in the legacy DATE_FORMAT,
+// and returns it in UTC. Legacy dates carry no zone, so they are read in the
+// given location.
+func ParseDate(value string, loc *time.Location) (time.Time, error) {
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	t, err := time.ParseInLocation(DATE_FORMAT, value, loc)
+	if err != nil {
+		return time.Time{}, ErrInvalidDate
+	}
+
+	return t.UTC(), nil
+}
+
+// FormatDate formats a date as RFC 3339 in the given location.
+func FormatDate(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(time.RFC3339)
+}
+
+// LoadLocation loads an IANA time zone, an empty name is UTC.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimeZone
+	}
+
+	return loc, nil
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FieldError is a validation error of a field of a request.
+type FieldError struct {
+	Field string
+	Code  string
+	Err   error
+}
+
+func NewFieldError(field string, code string, message string) FieldError {
+	return FieldError{
+		Field: field,
+		Code:  code,
+		Err:   errors.New(message),
+	}
+}
+
+func (fe FieldError) Error() string {
+	return fe.Err.Error()
+}
+
+func (fe FieldError) Unwrap() error {
+	return fe.Err
+}
+
+// FieldErrors are all the validation errors of a request.
+type FieldErrors []FieldError
+
+func (fe FieldErrors) Error() string {
+	messages := make([]string, 0, len(fe))
+	for _, e := range fe {
+		messages = append(messages, e.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is reports whether any of the errors is target. errors.Is only unwraps
+// lists of errors from Go 1.20.
+func (fe FieldErrors) Is(target error) bool {
+	for _, e := range fe {
+		if errors.Is(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As sets target to the first of the errors that matches it, as errors.As.
+func (fe FieldErrors) As(target interface{}) bool {
+	for _, e := range fe {
+		if errors.As(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithPrefix nests the fields of the errors under prefix, it is used to
+// report the errors of the items of a list such as operations[2].task.
+func (fe FieldErrors) WithPrefix(prefix string) FieldErrors {
+	prefixed := make(FieldErrors, 0, len(fe))
+	for _, e := range fe {
+		e.Field = prefix + "." + e.Field
+		e.Err = fmt.Errorf("%s: %w", prefix, e.Err)
+		prefixed = append(prefixed, e)
+	}
+	return prefixed
+}
+
+// AsFieldErrors returns the field errors of err, a single FieldError is
+// returned as a list.
+func AsFieldErrors(err error) (FieldErrors, bool) {
+
+	var fieldErrors FieldErrors
+	if errors.As(err, &fieldErrors) {
+		return fieldErrors, true
+	}
+
+	var fieldError FieldError
+	if errors.As(err, &fieldError) {
+		return FieldErrors{fieldError}, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+const EVENT_TASK_CREATED = "task.created"
+const EVENT_TASK_UPDATED = "task.updated"
+const EVENT_TASK_DELETED = "task.deleted"
+
+// TaskEvent tells the subscribers of the task events that a task changed.
+type TaskEvent struct {
+	Event     string    `json:"event"`
+	TaskId    uuid.UUID `json:"task_id"`
+	ChangedBy string    `json:"changed_by"`
+	At        string    `json:"at"`
+}
+
+func NewTaskEvent(event string, taskId uuid.UUID, changedBy string) TaskEvent {
+	return TaskEvent{
+		Event:     event,
+		TaskId:    taskId,
+		ChangedBy: changedBy,
+		At:        FormatDate(time.Now(), time.UTC),
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EXPORT_COLUMNS is the header of task exports in CSV.
+var EXPORT_COLUMNS = []string{"id", "date", "worker_id", "worker_name", "summary", "version", "custom"}
+
+// ExportRecord is the CSV record of a task with a decrypted summary, in the
+// order of EXPORT_COLUMNS. Summaries are plain text and custom fields are a
+// json object.
+func (t *Task) ExportRecord(loc *time.Location) []string {
+
+	date := ""
+	if t.Date.Valid {
+		date = FormatDate(t.Date.Time, loc)
+	}
+
+	custom := ""
+	if len(t.Custom) > 0 {
+		b, _ := json.Marshal(t.Custom)
+		custom = string(b)
+	}
+
+	return []string{
+		t.Id.String(),
+		date,
+		csvCell(t.WorkerId),
+		csvCell(t.WorkerName),
+		csvCell(t.SummaryText()),
+		strconv.Itoa(t.Version),
+		custom,
+	}
+}
+
+// csvCell keeps text from being read as a formula by spreadsheets, which run
+// cells starting with =, +, - or @.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
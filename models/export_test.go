@@ -0,0 +1,45 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportRecord(t *testing.T) {
+
+	id := uuid.Must(uuid.NewV4())
+	lisbon, _ := time.LoadLocation("Europe/Lisbon")
+
+	task := Task{
+		Id:         id,
+		WorkerId:   "auth0|1",
+		WorkerName: "=HYPERLINK(\"x\")",
+		Summary:    "Fixed the **pump**",
+		Date:       sql.NullTime{Valid: true, Time: time.Date(2022, time.June, 1, 10, 0, 0, 0, time.UTC)},
+		Custom:     CustomFields{"duration": 2},
+		Version:    3,
+	}
+
+	record := task.ExportRecord(lisbon)
+	assert.Len(t, record, len(EXPORT_COLUMNS))
+	assert.Equal(t, []string{
+		id.String(),
+		"2022-06-01T11:00:00+01:00",
+		"auth0|1",
+		"'=HYPERLINK(\"x\")",
+		"Fixed the pump",
+		"3",
+		`{"duration":2}`,
+	}, record)
+
+	// tasks without date or custom fields have empty cells
+	task.Date = sql.NullTime{}
+	task.Custom = nil
+	record = task.ExportRecord(time.UTC)
+	assert.Equal(t, "", record[1])
+	assert.Equal(t, "", record[6])
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+)
+
+// MAX_SUMMARY_CHARS_LIMIT is the highest summary limit a team can choose.
+const MAX_SUMMARY_CHARS_LIMIT = 10000
+
+const FIELD_MAX_SUMMARY_CHARS = "max_summary_chars"
+
+// Limits are the validation limits of the requests of a team.
+type Limits struct {
+	MaxSummaryChars int
+}
+
+// DEFAULT_LIMITS apply to teams without limits of their own.
+var DEFAULT_LIMITS = Limits{
+	MaxSummaryChars: MAX_SUMMARY_CHARS,
+}
+
+// TeamLimits are the limits a team chose, zero values use the defaults.
+type TeamLimits struct {
+	Team            string    `gorm:"primary_key;column:team;type:varchar(191)"`
+	MaxSummaryChars int       `gorm:"column:max_summary_chars"`
+	UpdatedBy       string    `gorm:"column:updated_by"`
+	UpdatedAt       time.Time `gorm:"column:updated_at"`
+}
+
+type TeamLimitsRequest struct {
+	MaxSummaryChars int `json:"max_summary_chars"`
+}
+
+type TeamLimitsResponse struct {
+	Team            string `json:"team"`
+	MaxSummaryChars int    `json:"max_summary_chars"`
+	UpdatedBy       string `json:"updated_by,omitempty"`
+	UpdatedAt       string `json:"updated_at,omitempty"`
+}
+
+func (tl *TeamLimits) ToLimits() Limits {
+
+	limits := DEFAULT_LIMITS
+	if tl.MaxSummaryChars > 0 {
+		limits.MaxSummaryChars = tl.MaxSummaryChars
+	}
+
+	return limits
+}
+
+func (tl *TeamLimits) ToResponse() TeamLimitsResponse {
+
+	response := TeamLimitsResponse{
+		Team:            tl.Team,
+		MaxSummaryChars: tl.ToLimits().MaxSummaryChars,
+		UpdatedBy:       tl.UpdatedBy,
+	}
+	if !tl.UpdatedAt.IsZero() {
+		response.UpdatedAt = FormatDate(tl.UpdatedAt, time.UTC)
+	}
+
+	return response
+}
+
+func (tlr *TeamLimitsRequest) Validate() error {
+	return Validate(
+		map[string]interface{}{FIELD_MAX_SUMMARY_CHARS: tlr.MaxSummaryChars},
+		Rules{FIELD_MAX_SUMMARY_CHARS: {Between(1, MAX_SUMMARY_CHARS_LIMIT)}},
+		[]string{FIELD_MAX_SUMMARY_CHARS})
+}
+
+func (tlr *TeamLimitsRequest) ToTeamLimits(team string, updatedBy string) TeamLimits {
+	return TeamLimits{
+		Team:            team,
+		MaxSummaryChars: tlr.MaxSummaryChars,
+		UpdatedBy:       updatedBy,
+		UpdatedAt:       time.Now().UTC(),
+	}
+}
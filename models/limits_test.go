@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeamLimitsDefaults(t *testing.T) {
+
+	tl := TeamLimits{Team: "mocked_team"}
+	assert.Equal(t, DEFAULT_LIMITS, tl.ToLimits())
+	assert.Equal(t, MAX_SUMMARY_CHARS, tl.ToResponse().MaxSummaryChars)
+
+	tl.MaxSummaryChars = 5000
+	assert.Equal(t, 5000, tl.ToLimits().MaxSummaryChars)
+}
+
+func TestTeamLimitsRequestValidation(t *testing.T) {
+
+	tlr := TeamLimitsRequest{MaxSummaryChars: 5000}
+	assert.Nil(t, tlr.Validate())
+
+	tlr.MaxSummaryChars = 0
+	assert.EqualError(t, tlr.Validate(), "max_summary_chars must be between 1 and 10000")
+
+	tlr.MaxSummaryChars = MAX_SUMMARY_CHARS_LIMIT + 1
+	assert.Error(t, tlr.Validate())
+}
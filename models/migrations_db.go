@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// SchemaMigration records a one-off migration of the data, so it never runs
+// twice.
+type SchemaMigration struct {
+	Name      string    `gorm:"primary_key;column:name;type:varchar(191)"`
+	Detail    string    `gorm:"column:detail"`
+	AppliedAt time.Time `gorm:"column:applied_at"`
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DAY_FORMAT is the format of dates without time, they are the start of the
+// day in the time zone of the request.
+const DAY_FORMAT = "2006-01-02"
+
+const RANGE_TODAY = "today"
+const RANGE_YESTERDAY = "yesterday"
+const RANGE_THIS_WEEK = "this_week"
+const RANGE_LAST_WEEK = "last_week"
+const RANGE_THIS_MONTH = "this_month"
+const RANGE_LAST_MONTH = "last_month"
+const RANGE_THIS_YEAR = "this_year"
+const RANGE_LAST_YEAR = "last_year"
+
+// RANGES are the named date ranges, weeks start on Monday.
+var RANGES = []string{
+	RANGE_TODAY, RANGE_YESTERDAY, RANGE_THIS_WEEK, RANGE_LAST_WEEK,
+	RANGE_THIS_MONTH, RANGE_LAST_MONTH, RANGE_THIS_YEAR, RANGE_LAST_YEAR,
+}
+
+// RELATIVE_DATE is an offset from now such as -7d, in hours, days, weeks,
+// months or years.
+var RELATIVE_DATE = regexp.MustCompile(`^([+-])(\d{1,4})([hdwmy])$`)
+
+var ErrInvalidDay = errors.New("invalid day format, use yyyy-mm-dd")
+var ErrInvalidBound = errors.New("invalid date format, use RFC 3339, yyyy-mm-dd, yyyy-mm-dd hh:mm:ssPM or an offset such as -7d (h, d, w, m or y)")
+
+// DateRange is a range of dates in UTC, it includes After and excludes
+// Before. Zero bounds are open.
+type DateRange struct {
+	After  time.Time
+	Before time.Time
+}
+
+// DateBounds are the bounds of a DateRange, as sent to the client.
+type DateBounds struct {
+	After  string `json:"after,omitempty"`
+	Before string `json:"before,omitempty"`
+}
+
+// ParseDateBound parses a bound of a date range: a date as in ParseDate, a
+// day, or an offset from now. Hours are counted from now, longer offsets are
+// whole days, so -7d is the start of the day a week ago in loc.
+func ParseDateBound(value string, now time.Time, loc *time.Location) (time.Time, error) {
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	if match := RELATIVE_DATE.FindStringSubmatch(value); match != nil {
+		n, _ := strconv.Atoi(match[2])
+		if match[1] == "-" {
+			n = -n
+		}
+
+		if match[3] == "h" {
+			return now.Add(time.Duration(n) * time.Hour).UTC(), nil
+		}
+
+		day := startOfDay(now, loc)
+		switch match[3] {
+		case "d":
+			day = day.AddDate(0, 0, n)
+		case "w":
+			day = day.AddDate(0, 0, 7*n)
+		case "m":
+			day = day.AddDate(0, n, 0)
+		case "y":
+			day = day.AddDate(n, 0, 0)
+		}
+		return day.UTC(), nil
+	}
+
+	if day, err := time.ParseInLocation(DAY_FORMAT, value, loc); err == nil {
+		return day.UTC(), nil
+	}
+
+	date, err := ParseDate(value, loc)
+	if err != nil {
+		return time.Time{}, ErrInvalidBound
+	}
+
+	return date, nil
+}
+
+// ParseDay is the range of a whole day in loc.
+func ParseDay(value string, loc *time.Location) (DateRange, error) {
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	day, err := time.ParseInLocation(DAY_FORMAT, value, loc)
+	if err != nil {
+		return DateRange{}, ErrInvalidDay
+	}
+
+	return DateRange{After: day.UTC(), Before: day.AddDate(0, 0, 1).UTC()}, nil
+}
+
+// NamedRange is the range of one of the RANGES, at now in loc.
+func NamedRange(name string, now time.Time, loc *time.Location) (DateRange, error) {
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	today := startOfDay(now, loc)
+	// weeks start on Monday
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, loc)
+	year := time.Date(today.Year(), time.January, 1, 0, 0, 0, 0, loc)
+
+	var start, end time.Time
+	switch name {
+	case RANGE_TODAY:
+		start, end = today, today.AddDate(0, 0, 1)
+	case RANGE_YESTERDAY:
+		start, end = today.AddDate(0, 0, -1), today
+	case RANGE_THIS_WEEK:
+		start, end = monday, monday.AddDate(0, 0, 7)
+	case RANGE_LAST_WEEK:
+		start, end = monday.AddDate(0, 0, -7), monday
+	case RANGE_THIS_MONTH:
+		start, end = month, month.AddDate(0, 1, 0)
+	case RANGE_LAST_MONTH:
+		start, end = month.AddDate(0, -1, 0), month
+	case RANGE_THIS_YEAR:
+		start, end = year, year.AddDate(1, 0, 0)
+	case RANGE_LAST_YEAR:
+		start, end = year.AddDate(-1, 0, 0), year
+	default:
+		return DateRange{}, fmt.Errorf("unknown range %q, use %s", name, strings.Join(RANGES, ", "))
+	}
+
+	return DateRange{After: start.UTC(), Before: end.UTC()}, nil
+}
+
+// IsZero tells whether both bounds are open.
+func (dr DateRange) IsZero() bool {
+	return dr.After.IsZero() && dr.Before.IsZero()
+}
+
+// ToBounds formats the bounds in loc, nil when both are open.
+func (dr DateRange) ToBounds(loc *time.Location) *DateBounds {
+
+	if dr.IsZero() {
+		return nil
+	}
+
+	var bounds DateBounds
+	if !dr.After.IsZero() {
+		bounds.After = FormatDate(dr.After, loc)
+	}
+	if !dr.Before.IsZero() {
+		bounds.Before = FormatDate(dr.Before, loc)
+	}
+
+	return &bounds
+}
+
+// startOfDay is the midnight of the day of t in loc.
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a Wednesday evening in UTC, already Thursday in Berlin
+var rangesNow = time.Date(2024, time.March, 6, 23, 30, 0, 0, time.UTC)
+
+func TestNamedRangesAreResolvedInLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	cases := map[string]DateRange{
+		RANGE_TODAY:      {time.Date(2024, time.March, 6, 23, 0, 0, 0, time.UTC), time.Date(2024, time.March, 7, 23, 0, 0, 0, time.UTC)},
+		RANGE_YESTERDAY:  {time.Date(2024, time.March, 5, 23, 0, 0, 0, time.UTC), time.Date(2024, time.March, 6, 23, 0, 0, 0, time.UTC)},
+		RANGE_THIS_WEEK:  {time.Date(2024, time.March, 3, 23, 0, 0, 0, time.UTC), time.Date(2024, time.March, 10, 23, 0, 0, 0, time.UTC)},
+		RANGE_LAST_WEEK:  {time.Date(2024, time.February, 25, 23, 0, 0, 0, time.UTC), time.Date(2024, time.March, 3, 23, 0, 0, 0, time.UTC)},
+		RANGE_THIS_MONTH: {time.Date(2024, time.February, 29, 23, 0, 0, 0, time.UTC), time.Date(2024, time.March, 31, 22, 0, 0, 0, time.UTC)},
+		RANGE_LAST_MONTH: {time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC), time.Date(2024, time.February, 29, 23, 0, 0, 0, time.UTC)},
+		RANGE_THIS_YEAR:  {time.Date(2023, time.December, 31, 23, 0, 0, 0, time.UTC), time.Date(2024, time.December, 31, 23, 0, 0, 0, time.UTC)},
+		RANGE_LAST_YEAR:  {time.Date(2022, time.December, 31, 23, 0, 0, 0, time.UTC), time.Date(2023, time.December, 31, 23, 0, 0, 0, time.UTC)},
+	}
+
+	for name, expected := range cases {
+		dates, err := NamedRange(name, rangesNow, berlin)
+		assert.Nil(t, err, name)
+		assert.Equal(t, expected, dates, name)
+	}
+
+	_, err = NamedRange("next_week", rangesNow, berlin)
+	assert.EqualError(t, err, `unknown range "next_week", use today, yesterday, this_week, last_week, this_month, last_month, this_year, last_year`)
+}
+
+func TestParseDateBound(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	cases := map[string]time.Time{
+		"-7d":                  time.Date(2024, time.February, 28, 23, 0, 0, 0, time.UTC),
+		"-3h":                  time.Date(2024, time.March, 6, 20, 30, 0, 0, time.UTC),
+		"-1w":                  time.Date(2024, time.February, 28, 23, 0, 0, 0, time.UTC),
+		"+1m":                  time.Date(2024, time.April, 6, 22, 0, 0, 0, time.UTC),
+		"2024-03-05":           time.Date(2024, time.March, 4, 23, 0, 0, 0, time.UTC),
+		"2024-03-05T10:00:00Z": time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC),
+	}
+
+	for value, expected := range cases {
+		date, err := ParseDateBound(value, rangesNow, berlin)
+		assert.Nil(t, err, value)
+		assert.Equal(t, expected, date, value)
+	}
+
+	for _, value := range []string{"yesterday", "-7", "7d", "-7x", "2024-13-01"} {
+		_, err := ParseDateBound(value, rangesNow, berlin)
+		assert.Equal(t, ErrInvalidBound, err, value)
+	}
+}
+
+func TestParseDay(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	dates, err := ParseDay("2024-03-31", berlin)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, time.March, 30, 23, 0, 0, 0, time.UTC), dates.After)
+	assert.Equal(t, time.Date(2024, time.March, 31, 22, 0, 0, 0, time.UTC), dates.Before)
+	assert.Equal(t, &DateBounds{After: "2024-03-31T00:00:00+01:00", Before: "2024-04-01T00:00:00+02:00"}, dates.ToBounds(berlin))
+
+	_, err = ParseDay("2024-03-05T10:00:00Z", berlin)
+	assert.Equal(t, ErrInvalidDay, err)
+
+	assert.Nil(t, DateRange{}.ToBounds(berlin))
+}
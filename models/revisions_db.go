@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type TaskRevision struct {
+	Id         uuid.UUID    `gorm:"primary_key;"`
+	TaskId     uuid.UUID    `gorm:"column:task_id;uniqueIndex:idx_task_revision"`
+	Revision   int          `gorm:"column:revision;uniqueIndex:idx_task_revision"`
+	ChangedBy  string       `gorm:"column:changed_by"`
+	ChangedAt  time.Time    `gorm:"column:changed_at"`
+	WorkerId   string       `gorm:"column:worker_id;type:varchar(191)"`
+	WorkerName string       `gorm:"column:worker_name"`
+	Summary    string       `gorm:"column:summary"`
+	Date       sql.NullTime `gorm:"column:date"`
+	Custom     CustomFields `gorm:"column:custom;type:json"`
+	Version    int          `gorm:"column:version"`
+}
+
+// NewTaskRevision snapshots the values a task had before being changed.
+// The summary is kept as stored, so it stays encrypted.
+func NewTaskRevision(t Task, revision int, changedBy string) (TaskRevision, error) {
+
+	genUuid, err := uuid.NewV4()
+	if err != nil {
+		return TaskRevision{}, err
+	}
+
+	return TaskRevision{
+		Id:         genUuid,
+		TaskId:     t.Id,
+		Revision:   revision,
+		ChangedBy:  changedBy,
+		ChangedAt:  time.Now().UTC(),
+		WorkerId:   t.WorkerId,
+		WorkerName: t.WorkerName,
+		Summary:    t.Summary,
+		Date:       t.Date,
+		Custom:     t.Custom,
+		Version:    t.Version,
+	}, nil
+}
+
+func (tr *TaskRevision) ToTask() Task {
+	return Task{
+		Id:         tr.TaskId,
+		WorkerId:   tr.WorkerId,
+		WorkerName: tr.WorkerName,
+		Summary:    tr.Summary,
+		Date:       tr.Date,
+		Custom:     tr.Custom,
+		Version:    tr.Version,
+	}
+}
+
+func (tr *TaskRevision) ToResponse(loc *time.Location) TaskRevisionResponse {
+	return TaskRevisionResponse{
+		Revision:   tr.Revision,
+		ChangedBy:  tr.ChangedBy,
+		ChangedAt:  FormatDate(tr.ChangedAt, loc),
+		WorkerId:   tr.WorkerId,
+		WorkerName: tr.WorkerName,
+		Summary:    tr.Summary,
+		Date:       FormatDate(tr.Date.Time, loc),
+		Custom:     tr.Custom,
+	}
+}
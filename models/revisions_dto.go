@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const CURRENT_REVISION = "current"
+
+type TaskRevisionResponse struct {
+	Revision   int          `json:"revision"`
+	ChangedBy  string       `json:"changed_by"`
+	ChangedAt  string       `json:"changed_at"`
+	WorkerId   string       `json:"worker_id"`
+	WorkerName string       `json:"worker_name"`
+	Summary    string       `json:"summary"`
+	Date       string       `json:"date"`
+	Custom     CustomFields `json:"custom,omitempty"`
+}
+
+type TaskRevisionListResponse struct {
+	Data []TaskRevisionResponse `json:"data"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+type TaskDiffResponse struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
+
+func ToRevisionListResponse(revisions []TaskRevision, loc *time.Location) TaskRevisionListResponse {
+
+	revisionsResponse := make([]TaskRevisionResponse, 0)
+
+	for _, r := range revisions {
+		revisionsResponse = append(revisionsResponse, r.ToResponse(loc))
+	}
+
+	return TaskRevisionListResponse{
+		Data: revisionsResponse,
+	}
+}
+
+// DiffTasks lists the fields that differ between two versions of a task.
+// Summaries are compared as given, so callers should decrypt them first.
+// Dates are reported in the given location.
+func DiffTasks(from Task, to Task, loc *time.Location) []FieldChange {
+
+	changes := make([]FieldChange, 0)
+
+	if from.WorkerId != to.WorkerId {
+		changes = append(changes, FieldChange{Field: "worker_id", From: from.WorkerId, To: to.WorkerId})
+	}
+
+	if from.Summary != to.Summary {
+		changes = append(changes, FieldChange{Field: "summary", From: from.Summary, To: to.Summary})
+	}
+
+	if !from.Date.Time.Equal(to.Date.Time) || from.Date.Valid != to.Date.Valid {
+		changes = append(changes, FieldChange{Field: "date", From: FormatDate(from.Date.Time, loc), To: FormatDate(to.Date.Time, loc)})
+	}
+
+	if !from.Custom.Equal(to.Custom) {
+		changes = append(changes, FieldChange{Field: "custom", From: customFieldsString(from.Custom), To: customFieldsString(to.Custom)})
+	}
+
+	return changes
+}
+
+func customFieldsString(cf CustomFields) string {
+	if len(cf) == 0 {
+		return ""
+	}
+
+	value, _ := json.Marshal(cf)
+	return string(value)
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffTasks(t *testing.T) {
+
+	from := Task{
+		WorkerId: "mocked_worker_id",
+		Summary:  "mocked_summary",
+		Date: sql.NullTime{
+			Valid: true,
+			Time:  time.Date(2020, time.April, 15, 10, 50, 0, 0, time.UTC),
+		},
+	}
+
+	changes := DiffTasks(from, from, time.UTC)
+	assert.Equal(t, len(changes), 0)
+
+	to := from
+	to.Summary = "updated_summary"
+	to.Date.Time = time.Date(2020, time.April, 16, 10, 50, 0, 0, time.UTC)
+
+	changes = DiffTasks(from, to, time.UTC)
+	assert.Equal(t, len(changes), 2)
+	assert.Equal(t, changes[0], FieldChange{Field: "summary", From: "mocked_summary", To: "updated_summary"})
+	assert.Equal(t, changes[1].Field, "date")
+	assert.Equal(t, changes[1].From, "2020-04-15T10:50:00Z")
+	assert.Equal(t, changes[1].To, "2020-04-16T10:50:00Z")
+}
+
+func TestNewTaskRevision(t *testing.T) {
+
+	tr := TaskRequest{
+		Summary: "mock_request",
+		Date:    "2006-01-02 03:04:05PM",
+	}
+
+	task, err := tr.ToTask("mocked_worker_id", "mocked_worker_id", time.UTC)
+	assert.Nil(t, err)
+
+	revision, err := NewTaskRevision(task, 3, "mocked_manager")
+	assert.Nil(t, err)
+
+	assert.NotNil(t, revision.Id)
+	assert.Equal(t, revision.TaskId, task.Id)
+	assert.Equal(t, revision.Revision, 3)
+	assert.Equal(t, revision.ChangedBy, "mocked_manager")
+	assert.Equal(t, revision.ToTask(), task)
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/gofrs/uuid"
+)
+
+// TaskSearchToken is a blind index token of a word of the summary of a task.
+type TaskSearchToken struct {
+	TaskId uuid.UUID `gorm:"primary_key;column:task_id"`
+	Token  string    `gorm:"primary_key;column:token;type:char(32);index"`
+}
+
+// ToSearchTokens builds the rows of the tokens of a task.
+func ToSearchTokens(taskId uuid.UUID, tokens []string) []TaskSearchToken {
+
+	rows := make([]TaskSearchToken, 0, len(tokens))
+	for _, token := range tokens {
+		rows = append(rows, TaskSearchToken{TaskId: taskId, Token: token})
+	}
+
+	return rows
+}
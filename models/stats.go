@@ -0,0 +1,39 @@
+package models
+
+// StatsGroup are the metrics of the tasks of a group, the group has the values
+// of its dimensions. Metrics that weren't asked for are left out.
+type StatsGroup struct {
+	Group    map[string]string `json:"group"`
+	Count    *int64            `json:"count,omitempty"`
+	Duration *float64          `json:"duration,omitempty"`
+}
+
+type StatsMetadata struct {
+	GroupBy  []string    `json:"group_by"`
+	Metrics  []string    `json:"metrics"`
+	TimeZone string      `json:"time_zone"`
+	Dates    *DateBounds `json:"dates,omitempty"`
+}
+
+type StatsResponse struct {
+	Data     []StatsGroup  `json:"data"`
+	Metadata StatsMetadata `json:"metadata"`
+}
+
+// Add adds the metrics of a part of the group.
+func (sg *StatsGroup) Add(metrics []string, count int64, duration float64) {
+
+	if contains(metrics, "count") {
+		if sg.Count == nil {
+			sg.Count = new(int64)
+		}
+		*sg.Count += count
+	}
+
+	if contains(metrics, "duration") {
+		if sg.Duration == nil {
+			sg.Duration = new(float64)
+		}
+		*sg.Duration += duration
+	}
+}
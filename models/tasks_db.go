@@ -2,22 +2,61 @@ package models
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
 
+	"github.com/MrBolas/SupervisorAPI/markdown"
 	"github.com/gofrs/uuid"
 )
 
 type Task struct {
-	Id       uuid.UUID    `gorm:"primary_key;"`
-	WorkerId string       `gorm:"column:worker_name"`
-	Summary  string       `gorm:"column:summary"`
-	Date     sql.NullTime `gorm:"column:date"`
+	Id         uuid.UUID    `gorm:"primary_key;"`
+	WorkerId   string       `gorm:"column:worker_id;type:varchar(191);index"`
+	WorkerName string       `gorm:"column:worker_name"`
+	Summary    string       `gorm:"column:summary"`
+	Date       sql.NullTime `gorm:"column:date"`
+	Custom     CustomFields `gorm:"column:custom;type:json"`
+	Version    int          `gorm:"column:version;not null;default:1"`
+
+	// SearchTokens are the blind index tokens of the summary. They are stored
+	// with the task when not nil.
+	SearchTokens []string `gorm:"-"`
 }
 
 func (t *Task) ToResponse() TaskResponse {
+	return t.ToResponseIn(time.UTC)
+}
+
+// ToResponseIn builds the response with dates in the given location.
+func (t *Task) ToResponseIn(loc *time.Location) TaskResponse {
 	return TaskResponse{
-		Id:       t.Id,
-		WorkerId: t.WorkerId,
-		Summary:  t.Summary,
-		Date:     t.Date.Time.String(),
+		Id:         t.Id,
+		WorkerId:   t.WorkerId,
+		WorkerName: t.WorkerName,
+		Summary:    t.Summary,
+		Date:       FormatDate(t.Date.Time, loc),
+		Custom:     t.Custom,
+		Version:    t.Version,
+	}
+}
+
+// SummaryText is the decrypted summary without markdown, for exports and
+// notifications.
+func (t *Task) SummaryText() string {
+	return markdown.ToText(t.Summary)
+}
+
+// ETag is the entity tag of the current version of the task.
+func (t *Task) ETag() string {
+	return fmt.Sprintf("\"%d\"", t.Version)
+}
+
+// ToRequest builds the request that would produce the task, it is the
+// document that patches are applied to.
+func (t *Task) ToRequest() TaskRequest {
+	return TaskRequest{
+		Summary: t.Summary,
+		Date:    FormatDate(t.Date.Time, time.UTC),
+		Custom:  t.Custom,
 	}
 }
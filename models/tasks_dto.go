@@ -5,23 +5,32 @@ import (
 	"errors"
 	"time"
 
+	"github.com/MrBolas/SupervisorAPI/markdown"
 	"github.com/gofrs/uuid"
 )
 
 const MAX_SUMMARY_CHARS = 2500
+const DATE_FORMAT = "2006-01-02 03:04:05PM"
+
+const FIELD_SUMMARY = "summary"
+const FIELD_DATE = "date"
+const FIELD_CUSTOM = "custom"
+const FIELD_TIME_ZONE = "time_zone"
 
 type TaskRequest struct {
-	Summary string `json:"summary"`
-	Date    string `json:"date"`
+	Summary  string       `json:"summary"`
+	Date     string       `json:"date"`
+	TimeZone string       `json:"time_zone,omitempty"`
+	Custom   CustomFields `json:"custom,omitempty"`
 }
 
-func (tr *TaskRequest) ToTask(workerId string) (Task, error) {
+// ToTask builds a task from the request. Dates without zone are read in the
+// time zone of the request, or in loc when the request has none.
+func (tr *TaskRequest) ToTask(workerId string, workerName string, loc *time.Location) (Task, error) {
 
-	t, err := time.Parse(
-		"2006-01-02 03:04:05PM",
-		tr.Date)
+	t, err := tr.parseDate(loc)
 	if err != nil {
-		return Task{}, errors.New("invalid date format, use yyyy-mm-dd hh:mm:ssPM")
+		return Task{}, err
 	}
 
 	genUuid, err := uuid.NewV4()
@@ -30,39 +39,141 @@ func (tr *TaskRequest) ToTask(workerId string) (Task, error) {
 	}
 
 	return Task{
-		Id:       genUuid,
-		Summary:  tr.Summary,
-		WorkerId: workerId,
+		Id:         genUuid,
+		Summary:    tr.Summary,
+		WorkerId:   workerId,
+		WorkerName: workerName,
+		Custom:     tr.Custom,
 		Date: sql.NullTime{
 			Valid: true,
 			Time:  t,
 		},
+		Version: 1,
 	}, nil
 }
 
+// TASK_FIELDS are the fields validated in full task requests.
+var TASK_FIELDS = []string{FIELD_SUMMARY, FIELD_DATE, FIELD_TIME_ZONE}
+
+// Validate validates the whole request with the default limits.
 func (tr *TaskRequest) Validate() error {
+	return tr.ValidateFields(TASK_FIELDS, DEFAULT_LIMITS)
+}
 
-	// validate date
-	_, err := time.Parse(
-		"2006-01-02 03:04:05PM",
-		tr.Date)
-	if err != nil {
-		return errors.New("invalid date format, use yyyy-mm-dd hh:mm:ssPM")
+// ValidateFields validates only the given fields of the request, it is used
+// for partial updates where the other fields are left untouched. All the
+// violations are reported as FieldErrors.
+func (tr *TaskRequest) ValidateFields(fields []string, limits Limits) error {
+
+	// the time zone is part of the date
+	if contains(fields, FIELD_DATE) && !contains(fields, FIELD_TIME_ZONE) {
+		fields = append(fields, FIELD_TIME_ZONE)
+	}
+
+	errs, _ := AsFieldErrors(Validate(tr.values(), tr.rules(limits), fields))
+
+	// dates are stored in UTC, a time zone only reads the date next to it
+	if contains(fields, FIELD_TIME_ZONE) && !contains(fields, FIELD_DATE) {
+		errs = append(errs, NewFieldError(FIELD_TIME_ZONE, "date_required", "time_zone only applies to the date of the same request, send the date too"))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+func (tr *TaskRequest) values() map[string]interface{} {
+	return map[string]interface{}{
+		FIELD_SUMMARY:   tr.Summary,
+		FIELD_DATE:      tr.Date,
+		FIELD_TIME_ZONE: tr.TimeZone,
+	}
+}
+
+func (tr *TaskRequest) rules(limits Limits) Rules {
+	return Rules{
+		FIELD_SUMMARY:   {MaxChars(limits.MaxSummaryChars)},
+		FIELD_DATE:      {Date()},
+		FIELD_TIME_ZONE: {TimeZone()},
+	}
+}
+
+// ChangedFields lists the fields of patched that differ from tr.
+func (tr *TaskRequest) ChangedFields(patched TaskRequest) []string {
+
+	fields := make([]string, 0)
+
+	if tr.Summary != patched.Summary {
+		fields = append(fields, FIELD_SUMMARY)
+	}
+
+	if tr.Date != patched.Date {
+		fields = append(fields, FIELD_DATE)
+	}
+
+	if tr.TimeZone != patched.TimeZone {
+		fields = append(fields, FIELD_TIME_ZONE)
+	}
+
+	if !tr.Custom.Equal(patched.Custom) {
+		fields = append(fields, FIELD_CUSTOM)
+	}
+
+	return fields
+}
+
+// ApplyTo copies the given fields of the request into a copy of the task.
+func (tr *TaskRequest) ApplyTo(task Task, fields []string, loc *time.Location) (Task, error) {
+
+	for _, field := range fields {
+		switch field {
+		case FIELD_DATE:
+			t, err := tr.parseDate(loc)
+			if err != nil {
+				return Task{}, err
+			}
+			task.Date = sql.NullTime{
+				Valid: true,
+				Time:  t,
+			}
+		case FIELD_SUMMARY:
+			task.Summary = tr.Summary
+		case FIELD_CUSTOM:
+			task.Custom = tr.Custom
+		}
 	}
 
-	// validate number of charecters of summary
-	if len(tr.Summary) > MAX_SUMMARY_CHARS {
-		return errors.New("summary max size is 2500 characters")
+	return task, nil
+}
+
+// parseDate parses the date of the request in UTC.
+func (tr *TaskRequest) parseDate(loc *time.Location) (time.Time, error) {
+
+	if tr.TimeZone != "" {
+		requestLoc, err := LoadLocation(tr.TimeZone)
+		if err != nil {
+			return time.Time{}, err
+		}
+		loc = requestLoc
 	}
 
-	return nil
+	return ParseDate(tr.Date, loc)
 }
 
 type TaskResponse struct {
-	Id       uuid.UUID `json:"id"`
-	WorkerId string    `json:"worker_name"`
-	Summary  string    `json:"summary"`
-	Date     string    `json:"date"`
+	Id          uuid.UUID    `json:"id"`
+	WorkerId    string       `json:"worker_id"`
+	WorkerName  string       `json:"worker_name"`
+	Summary     string       `json:"summary"`
+	SummaryHTML string       `json:"summary_html,omitempty"`
+	Date        string       `json:"date"`
+	Custom      CustomFields `json:"custom,omitempty"`
+	Version     int          `json:"version"`
+
+	// Assignee is the profile of the worker, when it is included.
+	Assignee *UserResponse `json:"assignee,omitempty"`
 }
 
 type TaskListResponse struct {
@@ -70,28 +181,108 @@ type TaskListResponse struct {
 	Metadata Metadata       `json:"metadata"`
 }
 
+// SparseTaskListResponse is a list of tasks with some of their fields.
+type SparseTaskListResponse struct {
+	Data     []map[string]interface{} `json:"data"`
+	Metadata Metadata                 `json:"metadata"`
+}
+
+// TASK_RESPONSE_FIELDS are the fields of tasks that responses can be limited
+// to. The id is always sent.
+var TASK_RESPONSE_FIELDS = []string{"id", "worker_id", "worker_name", "summary", "date", "custom", "version"}
+
+// Sparse returns only the given fields of the task, with the id, its html
+// summary along with the summary and its assignee when it is included.
+func (tr TaskResponse) Sparse(fields []string) map[string]interface{} {
+
+	sparse := map[string]interface{}{"id": tr.Id}
+
+	for _, field := range fields {
+		switch field {
+		case "worker_id":
+			sparse[field] = tr.WorkerId
+		case "worker_name":
+			sparse[field] = tr.WorkerName
+		case "summary":
+			sparse[field] = tr.Summary
+			if tr.SummaryHTML != "" {
+				sparse["summary_html"] = tr.SummaryHTML
+			}
+		case "date":
+			sparse[field] = tr.Date
+		case "custom":
+			if len(tr.Custom) > 0 {
+				sparse[field] = tr.Custom
+			}
+		case "version":
+			sparse[field] = tr.Version
+		}
+	}
+
+	if tr.Assignee != nil {
+		sparse["assignee"] = tr.Assignee
+	}
+
+	return sparse
+}
+
+// Sparse returns the list with only the given fields of its tasks.
+func (tlr TaskListResponse) Sparse(fields []string) SparseTaskListResponse {
+
+	data := make([]map[string]interface{}, 0, len(tlr.Data))
+	for _, task := range tlr.Data {
+		data = append(data, task.Sparse(fields))
+	}
+
+	return SparseTaskListResponse{
+		Data:     data,
+		Metadata: tlr.Metadata,
+	}
+}
+
 type Metadata struct {
-	Page     int `json:"page"`
-	PageSize int `json:"page_size"`
+	Page       int         `json:"page,omitempty"`
+	PageSize   int         `json:"page_size"`
+	HasNext    bool        `json:"has_next"`
+	TotalCount *int64      `json:"total_count,omitempty"`
+	Next       string      `json:"next,omitempty"`
+	Prev       string      `json:"prev,omitempty"`
+	Links      *Links      `json:"links,omitempty"`
+	Dates      *DateBounds `json:"dates,omitempty"`
 }
 
-func ToListResponse(tasks []Task, page int, pageSize int) TaskListResponse {
+// Links are the urls of the pages around a page of a list.
+type Links struct {
+	Self  string `json:"self"`
+	First string `json:"first"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
 
-	tasksResponse := make([]TaskResponse, 0)
+// RenderSummaryHTML adds the summary rendered as sanitized HTML.
+func (tr *TaskResponse) RenderSummaryHTML() {
+	tr.SummaryHTML = markdown.ToHTML(tr.Summary)
+}
 
-	if len(tasks) > pageSize {
-		tasks = tasks[:len(tasks)-1]
+// RenderSummaryHTML adds the rendered summary to every task of the list.
+func (tlr *TaskListResponse) RenderSummaryHTML() {
+	for i := range tlr.Data {
+		tlr.Data[i].RenderSummaryHTML()
 	}
+}
+
+// ToListResponse builds the response of a page of tasks, the page must not
+// have the extra row read for pagination.
+func ToListResponse(tasks []Task, metadata Metadata, loc *time.Location) TaskListResponse {
+
+	tasksResponse := make([]TaskResponse, 0)
 
 	for _, t := range tasks {
-		tasksResponse = append(tasksResponse, t.ToResponse())
+		tasksResponse = append(tasksResponse, t.ToResponseIn(loc))
 	}
 
 	return TaskListResponse{
-		Data: tasksResponse,
-		Metadata: Metadata{
-			Page:     page,
-			PageSize: pageSize,
-		},
+		Data:     tasksResponse,
+		Metadata: metadata,
 	}
 }
@@ -1,8 +1,8 @@
 package models
 
 import (
-	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -24,7 +24,7 @@ func TestTimeFormatValidation(t *testing.T) {
 
 	err = taskr.Validate()
 	if assert.Error(t, err) {
-		assert.Equal(t, errors.New("invalid date format, use yyyy-mm-dd hh:mm:ssPM"), err)
+		assert.ErrorIs(t, err, ErrInvalidDate)
 	}
 }
 
@@ -45,7 +45,7 @@ func TestSummaryConstraintValidation(t *testing.T) {
 
 	err = taskr.Validate()
 	if assert.Error(t, err) {
-		assert.Equal(t, errors.New("summary max size is 2500 characters"), err)
+		assert.EqualError(t, err, "summary max size is 2500 characters")
 	}
 }
 
@@ -56,7 +56,7 @@ func TestTaskRequestToTask(t *testing.T) {
 		Date:    "2006-01-02 03:04:05PM",
 	}
 
-	task, err := tr.ToTask("mocked_worker_id")
+	task, err := tr.ToTask("mocked_worker_id", "mocked_worker_id", time.UTC)
 	assert.Nil(t, err)
 
 	assert.Equal(t, task.Summary, tr.Summary)
@@ -75,15 +75,155 @@ func TestToTaskResponseList(t *testing.T) {
 	tasks := make([]Task, 0)
 
 	for i := 0; i < 5; i++ {
-		task, err := tr.ToTask("mocked_worker_id")
+		task, err := tr.ToTask("mocked_worker_id", "mocked_worker_id", time.UTC)
 		assert.Nil(t, err)
 
 		tasks = append(tasks, task)
 	}
 
-	tRespList := ToListResponse(tasks, 1, 10)
+	tRespList := ToListResponse(tasks, Metadata{Page: 1, PageSize: 10}, time.UTC)
 
 	assert.Equal(t, len(tRespList.Data), 5)
 	assert.Equal(t, tRespList.Metadata.Page, 1)
 	assert.Equal(t, tRespList.Metadata.PageSize, 10)
 }
+
+func TestTaskRequestPartialValidation(t *testing.T) {
+
+	taskr := TaskRequest{
+		Summary: "mock_summary",
+		Date:    "",
+	}
+
+	err := taskr.ValidateFields([]string{FIELD_SUMMARY}, DEFAULT_LIMITS)
+	assert.Nil(t, err)
+
+	err = taskr.ValidateFields([]string{FIELD_SUMMARY, FIELD_DATE}, DEFAULT_LIMITS)
+	if assert.Error(t, err) {
+		assert.ErrorIs(t, err, ErrInvalidDate)
+	}
+}
+
+func TestTaskRequestApplyChangedFields(t *testing.T) {
+
+	tr := TaskRequest{
+		Summary: "mock_request",
+		Date:    "2006-01-02 03:04:05PM",
+	}
+
+	task, err := tr.ToTask("mocked_worker_id", "mocked_worker_id", time.UTC)
+	assert.Nil(t, err)
+
+	original := task.ToRequest()
+	assert.Equal(t, original.Summary, tr.Summary)
+	assert.Equal(t, original.Date, "2006-01-02T15:04:05Z")
+
+	patched := original
+	patched.Date = "2007-01-02T15:04:05Z"
+
+	fields := original.ChangedFields(patched)
+	assert.Equal(t, fields, []string{FIELD_DATE})
+
+	patchedTask, err := patched.ApplyTo(task, fields, time.UTC)
+	assert.Nil(t, err)
+	assert.Equal(t, patchedTask.Summary, task.Summary)
+	assert.Equal(t, patchedTask.Date.Time.Year(), 2007)
+}
+
+func TestTaskRequestChangedTimeZoneNeedsTheDate(t *testing.T) {
+
+	original := TaskRequest{Summary: "mock_request", Date: "2022-05-23T14:33:01Z"}
+
+	// a time zone alone would change nothing, dates are stored in UTC
+	patched := original
+	patched.TimeZone = "Europe/Lisbon"
+
+	fields := original.ChangedFields(patched)
+	assert.Equal(t, []string{FIELD_TIME_ZONE}, fields)
+
+	fieldErrors, ok := AsFieldErrors(patched.ValidateFields(fields, DEFAULT_LIMITS))
+	if assert.True(t, ok) && assert.Len(t, fieldErrors, 1) {
+		assert.Equal(t, FIELD_TIME_ZONE, fieldErrors[0].Field)
+		assert.Equal(t, "date_required", fieldErrors[0].Code)
+	}
+
+	// with the date, the date is read in the time zone
+	patched.Date = "2022-05-23 03:33:01PM"
+
+	fields = original.ChangedFields(patched)
+	assert.Equal(t, []string{FIELD_DATE, FIELD_TIME_ZONE}, fields)
+	assert.Nil(t, patched.ValidateFields(fields, DEFAULT_LIMITS))
+
+	task, err := patched.ApplyTo(Task{}, fields, time.UTC)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2022, 5, 23, 14, 33, 1, 0, time.UTC), task.Date.Time)
+}
+
+func TestTaskRequestToTaskTimeZones(t *testing.T) {
+
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	assert.Nil(t, err)
+
+	// RFC 3339 dates carry their own offset
+	tr := TaskRequest{Summary: "mock_request", Date: "2022-05-23T15:33:01+02:00"}
+	task, err := tr.ToTask("mocked_worker_id", "mocked_worker_id", lisbon)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2022, time.May, 23, 13, 33, 1, 0, time.UTC), task.Date.Time)
+
+	// legacy dates are read in the default zone
+	tr = TaskRequest{Summary: "mock_request", Date: "2022-05-23 03:33:01PM"}
+	task, err = tr.ToTask("mocked_worker_id", "mocked_worker_id", lisbon)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2022, time.May, 23, 14, 33, 1, 0, time.UTC), task.Date.Time)
+
+	// or in the zone of the request
+	tr = TaskRequest{Summary: "mock_request", Date: "2022-05-23 03:33:01PM", TimeZone: "America/New_York"}
+	task, err = tr.ToTask("mocked_worker_id", "mocked_worker_id", lisbon)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2022, time.May, 23, 19, 33, 1, 0, time.UTC), task.Date.Time)
+
+	tr = TaskRequest{Summary: "mock_request", Date: "2022-05-23 03:33:01PM", TimeZone: "Mars/Olympus"}
+	assert.ErrorIs(t, tr.Validate(), ErrInvalidTimeZone)
+
+	response := task.ToResponseIn(lisbon)
+	assert.Equal(t, "2022-05-23T20:33:01+01:00", response.Date)
+}
+
+func TestTaskResponseRenderSummaryHTML(t *testing.T) {
+
+	task := Task{Summary: "# Service\n\n- **pump**\n- filter"}
+
+	response := task.ToResponse()
+	assert.Equal(t, "", response.SummaryHTML)
+
+	response.RenderSummaryHTML()
+	assert.Equal(t, "<h1>Service</h1>\n<ul>\n<li><strong>pump</strong></li>\n<li>filter</li>\n</ul>\n", response.SummaryHTML)
+	assert.Equal(t, "Service\n\n- pump\n- filter", task.SummaryText())
+}
+
+func TestSparseTaskResponse(t *testing.T) {
+
+	response := TaskResponse{
+		WorkerId:    "auth0|1",
+		WorkerName:  "joseph",
+		Summary:     "**done**",
+		SummaryHTML: "<p><strong>done</strong></p>\n",
+		Date:        "2022-05-23T15:33:01Z",
+		Version:     2,
+		Assignee:    &UserResponse{Id: "auth0|1"},
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"id":           response.Id,
+		"summary":      "**done**",
+		"summary_html": "<p><strong>done</strong></p>\n",
+		"version":      2,
+		"assignee":     response.Assignee,
+	}, response.Sparse([]string{"summary", "version", "custom"}))
+
+	list := TaskListResponse{Data: []TaskResponse{response}, Metadata: Metadata{PageSize: 20}}
+	sparse := list.Sparse([]string{"worker_id"})
+	assert.Equal(t, 20, sparse.Metadata.PageSize)
+	assert.Equal(t, "auth0|1", sparse.Data[0]["worker_id"])
+	assert.NotContains(t, sparse.Data[0], "summary")
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// User is a local copy of an identity provider user, keyed by the stable
+// token subject and refreshed from the token claims on every request.
+// Preferences are local to this service and never overwritten by claims.
+type User struct {
+	Id                   string `gorm:"primary_key;column:id;type:varchar(191)"`
+	Nickname             string `gorm:"column:nickname;type:varchar(191);index"`
+	Role                 string `gorm:"column:role;type:varchar(191);index"`
+	Team                 string `gorm:"column:team;type:varchar(191);index"`
+	TimeZone             string `gorm:"column:time_zone"`
+	NotificationsEnabled bool   `gorm:"column:notifications_enabled;not null;default:true"`
+	DefaultTimeZone      string `gorm:"column:default_time_zone"`
+	CalendarSummaries    bool   `gorm:"column:calendar_summaries;not null;default:false"`
+	// CalendarTokenHash is the hash of the token of the calendar feed of the
+	// user, empty when there is no feed.
+	CalendarTokenHash string    `gorm:"column:calendar_token_hash;type:varchar(64);index"`
+	CreatedAt         time.Time `gorm:"column:created_at"`
+	UpdatedAt         time.Time `gorm:"column:updated_at"`
+}
+
+// ClaimsChanged reports whether the values synced from the token differ.
+func (u *User) ClaimsChanged(other User) bool {
+	return u.Nickname != other.Nickname ||
+		u.Role != other.Role ||
+		u.Team != other.Team ||
+		u.TimeZone != other.TimeZone
+}
+
+func (u *User) ToResponse() UserResponse {
+	return UserResponse{
+		Id:          u.Id,
+		DisplayName: u.Nickname,
+		Role:        u.Role,
+		Team:        u.Team,
+		TimeZone:    u.TimeZone,
+	}
+}
+
+// ToProfileResponse is the response of the user itself, with its preferences.
+func (u *User) ToProfileResponse() UserResponse {
+	response := u.ToResponse()
+	response.Preferences = &UserPreferences{
+		NotificationsEnabled: u.NotificationsEnabled,
+		DefaultTimeZone:      u.DefaultTimeZone,
+		CalendarSummaries:    u.CalendarSummaries,
+	}
+	return response
+}
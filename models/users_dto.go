@@ -0,0 +1,100 @@
+package models
+
+type UserResponse struct {
+	Id          string           `json:"id"`
+	DisplayName string           `json:"display_name"`
+	Role        string           `json:"role"`
+	Team        string           `json:"team"`
+	TimeZone    string           `json:"time_zone"`
+	Preferences *UserPreferences `json:"preferences,omitempty"`
+}
+
+type UserPreferences struct {
+	NotificationsEnabled bool   `json:"notifications_enabled"`
+	DefaultTimeZone      string `json:"default_time_zone"`
+	// CalendarSummaries puts the summaries of the tasks in the calendar feed,
+	// which third party apps read.
+	CalendarSummaries bool `json:"calendar_summaries"`
+}
+
+// UserPreferencesRequest only changes the preferences that are present.
+type UserPreferencesRequest struct {
+	NotificationsEnabled *bool   `json:"notifications_enabled"`
+	DefaultTimeZone      *string `json:"default_time_zone"`
+	CalendarSummaries    *bool   `json:"calendar_summaries"`
+}
+
+type UserListResponse struct {
+	Data     []UserResponse `json:"data"`
+	Metadata Metadata       `json:"metadata"`
+}
+
+func (upr *UserPreferencesRequest) Validate() error {
+
+	if upr.DefaultTimeZone == nil {
+		return nil
+	}
+
+	return Validate(
+		map[string]interface{}{"default_time_zone": *upr.DefaultTimeZone},
+		Rules{"default_time_zone": {TimeZone()}},
+		[]string{"default_time_zone"})
+}
+
+// ApplyTo copies the present preferences into a copy of the user.
+func (upr *UserPreferencesRequest) ApplyTo(u User) User {
+
+	if upr.NotificationsEnabled != nil {
+		u.NotificationsEnabled = *upr.NotificationsEnabled
+	}
+
+	if upr.DefaultTimeZone != nil {
+		u.DefaultTimeZone = *upr.DefaultTimeZone
+	}
+
+	if upr.CalendarSummaries != nil {
+		u.CalendarSummaries = *upr.CalendarSummaries
+	}
+
+	return u
+}
+
+func ToUserListResponse(users []User, page int, pageSize int) UserListResponse {
+
+	usersResponse := make([]UserResponse, 0)
+
+	hasNext := len(users) > pageSize
+	if hasNext {
+		users = users[:len(users)-1]
+	}
+
+	for _, u := range users {
+		usersResponse = append(usersResponse, u.ToResponse())
+	}
+
+	return UserListResponse{
+		Data: usersResponse,
+		Metadata: Metadata{
+			Page:     page,
+			PageSize: pageSize,
+			HasNext:  hasNext,
+		},
+	}
+}
+
+// TaskClaimRequest links the legacy tasks of a nickname to a user.
+type TaskClaimRequest struct {
+	Nickname string `json:"nickname"`
+	UserId   string `json:"user_id"`
+}
+
+type TaskClaimResponse struct {
+	Claimed int64 `json:"claimed"`
+}
+
+func (tcr *TaskClaimRequest) Validate() error {
+	return Validate(
+		map[string]interface{}{"nickname": tcr.Nickname, "user_id": tcr.UserId},
+		Rules{"nickname": {Required()}, "user_id": {Required()}},
+		[]string{"nickname", "user_id"})
+}
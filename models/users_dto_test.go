@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserPreferencesRequest(t *testing.T) {
+
+	user := User{
+		Id:                   "mocked_worker_id",
+		NotificationsEnabled: true,
+		DefaultTimeZone:      "UTC",
+	}
+
+	timeZone := "Europe/Lisbon"
+	upr := UserPreferencesRequest{DefaultTimeZone: &timeZone}
+	assert.Nil(t, upr.Validate())
+
+	updatedUser := upr.ApplyTo(user)
+	assert.Equal(t, updatedUser.DefaultTimeZone, "Europe/Lisbon")
+	assert.True(t, updatedUser.NotificationsEnabled)
+	assert.False(t, updatedUser.CalendarSummaries)
+
+	calendarSummaries := true
+	upr = UserPreferencesRequest{CalendarSummaries: &calendarSummaries}
+	updatedUser = upr.ApplyTo(user)
+	assert.True(t, updatedUser.CalendarSummaries)
+	assert.Equal(t, updatedUser.DefaultTimeZone, "UTC")
+
+	invalidTimeZone := "Mars/Olympus"
+	upr = UserPreferencesRequest{DefaultTimeZone: &invalidTimeZone}
+	assert.Error(t, upr.Validate())
+}
+
+func TestToUserListResponse(t *testing.T) {
+
+	users := []User{{Id: "1", Nickname: "a"}, {Id: "2", Nickname: "b"}, {Id: "3", Nickname: "c"}}
+
+	response := ToUserListResponse(users, 1, 2)
+
+	assert.Equal(t, len(response.Data), 2)
+	assert.Equal(t, response.Data[0].DisplayName, "a")
+	assert.Nil(t, response.Data[0].Preferences)
+	assert.Equal(t, response.Metadata.PageSize, 2)
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofrs/uuid"
+)
+
+// Rule checks the value of a field, it returns nil when the value is valid.
+// The returned error only needs Code and Err, Validate sets the Field.
+type Rule func(field string, value interface{}) *FieldError
+
+// Rules are the rules of the fields of a request, by field name. The rules of
+// a field are checked in order and stop at the first violation.
+type Rules map[string][]Rule
+
+// Validate checks the given fields against their rules and reports all the
+// violations as FieldErrors, in the order of fields.
+func Validate(values map[string]interface{}, rules Rules, fields []string) error {
+
+	errs := make(FieldErrors, 0)
+
+	for _, field := range fields {
+		for _, rule := range rules[field] {
+			if fe := rule(field, values[field]); fe != nil {
+				fe.Field = field
+				errs = append(errs, *fe)
+				break
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// violation builds the result of a failed rule.
+func violation(code string, format string, args ...interface{}) *FieldError {
+	fe := NewFieldError("", code, fmt.Sprintf(format, args...))
+	return &fe
+}
+
+// Required fails for empty strings, nil ids and nil pointers.
+func Required() Rule {
+	return func(field string, value interface{}) *FieldError {
+		if isEmpty(value) {
+			return violation("required", "%s is required", field)
+		}
+		return nil
+	}
+}
+
+// NotEmpty fails for empty lists.
+func NotEmpty() Rule {
+	return func(field string, value interface{}) *FieldError {
+		if isEmpty(value) {
+			return violation("empty", "%s must not be empty", field)
+		}
+		return nil
+	}
+}
+
+// MaxChars fails for strings longer than max characters, not bytes.
+func MaxChars(max int) Rule {
+	return func(field string, value interface{}) *FieldError {
+		s, _ := value.(string)
+		if utf8.RuneCountInString(s) > max {
+			return violation("too_long", "%s max size is %d characters", field, max)
+		}
+		return nil
+	}
+}
+
+// MaxItems fails for lists longer than max.
+func MaxItems(max int) Rule {
+	return func(field string, value interface{}) *FieldError {
+		v := reflect.ValueOf(value)
+		if v.Kind() == reflect.Slice && v.Len() > max {
+			return violation("too_long", "%s max size is %d", field, max)
+		}
+		return nil
+	}
+}
+
+// Between fails for numbers out of [min, max].
+func Between(min int, max int) Rule {
+	return func(field string, value interface{}) *FieldError {
+		n, _ := value.(int)
+		if n < min || n > max {
+			return violation("out_of_range", "%s must be between %d and %d", field, min, max)
+		}
+		return nil
+	}
+}
+
+// OneOf fails for strings that are not one of values.
+func OneOf(values ...string) Rule {
+	return func(field string, value interface{}) *FieldError {
+		s, _ := value.(string)
+		for _, v := range values {
+			if s == v {
+				return nil
+			}
+		}
+		return violation("invalid", "%s must be one of %s", field, strings.Join(values, ", "))
+	}
+}
+
+// Date fails for strings that are not RFC 3339 or legacy dates, it is the
+// parsing used when the request is converted.
+func Date() Rule {
+	return func(field string, value interface{}) *FieldError {
+		s, _ := value.(string)
+		if _, err := ParseDate(s, nil); err != nil {
+			return &FieldError{Code: "invalid_date", Err: err}
+		}
+		return nil
+	}
+}
+
+// TimeZone fails for strings that are not IANA time zones, empty is UTC.
+func TimeZone() Rule {
+	return func(field string, value interface{}) *FieldError {
+		s, _ := value.(string)
+		if _, err := LoadLocation(s); err != nil {
+			return &FieldError{Code: "invalid_time_zone", Err: err}
+		}
+		return nil
+	}
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case uuid.UUID:
+		return v == uuid.Nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	case reflect.Map, reflect.Slice:
+		return rv.Len() == 0
+	}
+
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReportsAllViolations(t *testing.T) {
+
+	tr := TaskRequest{
+		Summary:  strings.Repeat("a", MAX_SUMMARY_CHARS+1),
+		Date:     "yesterday",
+		TimeZone: "Mars/Olympus",
+	}
+
+	fieldErrors, ok := AsFieldErrors(tr.Validate())
+	if assert.True(t, ok) {
+		assert.Len(t, fieldErrors, 3)
+		assert.Equal(t, FIELD_SUMMARY, fieldErrors[0].Field)
+		assert.Equal(t, "too_long", fieldErrors[0].Code)
+		assert.Equal(t, FIELD_DATE, fieldErrors[1].Field)
+		assert.ErrorIs(t, fieldErrors[1], ErrInvalidDate)
+		assert.Equal(t, FIELD_TIME_ZONE, fieldErrors[2].Field)
+		assert.ErrorIs(t, fieldErrors[2], ErrInvalidTimeZone)
+	}
+}
+
+func TestMaxCharsCountsCharacters(t *testing.T) {
+
+	// 2500 characters of 2 bytes each
+	tr := TaskRequest{
+		Summary: strings.Repeat("ç", MAX_SUMMARY_CHARS),
+		Date:    "2006-01-02T15:04:05Z",
+	}
+	assert.Nil(t, tr.Validate())
+
+	tr.Summary += "ç"
+	assert.EqualError(t, tr.Validate(), "summary max size is 2500 characters")
+}
+
+func TestValidateWithLimits(t *testing.T) {
+
+	tr := TaskRequest{Summary: "mock_summary"}
+
+	err := tr.ValidateFields([]string{FIELD_SUMMARY}, Limits{MaxSummaryChars: 4})
+	assert.EqualError(t, err, "summary max size is 4 characters")
+
+	assert.Nil(t, tr.ValidateFields([]string{FIELD_SUMMARY}, Limits{MaxSummaryChars: 20}))
+}
+
+func TestFieldErrorsWithPrefix(t *testing.T) {
+
+	fieldErrors := FieldErrors{NewFieldError("id", "required", "id is required")}.WithPrefix("operations[1]")
+
+	assert.Equal(t, "operations[1].id", fieldErrors[0].Field)
+	assert.Equal(t, "required", fieldErrors[0].Code)
+	assert.EqualError(t, fieldErrors, "operations[1]: id is required")
+}
+
+func TestFieldErrorsMatchTheirErrors(t *testing.T) {
+
+	err := fmt.Errorf("task: %w", FieldErrors{
+		NewFieldError(FIELD_SUMMARY, "required", "summary is required"),
+		{Field: FIELD_DATE, Code: "invalid_date", Err: ErrInvalidDate},
+	})
+
+	assert.True(t, errors.Is(err, ErrInvalidDate))
+	assert.False(t, errors.Is(err, ErrInvalidTimeZone))
+
+	var fieldError FieldError
+	if assert.True(t, errors.As(err, &fieldError)) {
+		assert.Equal(t, FIELD_SUMMARY, fieldError.Field)
+	}
+}
+
+func TestRules(t *testing.T) {
+
+	assert.NotNil(t, Required()("id", ""))
+	assert.NotNil(t, Required()("task", (*TaskRequest)(nil)))
+	assert.Nil(t, Required()("task", &TaskRequest{}))
+
+	assert.NotNil(t, Between(1, 10)("max", 0))
+	assert.Nil(t, Between(1, 10)("max", 10))
+
+	assert.Nil(t, OneOf("a", "b")("op", "a"))
+	assert.Equal(t, "op must be one of a, b", OneOf("a", "b")("op", "c").Error())
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+const MAX_VIEW_NAME_CHARS = 100
+
+const FIELD_VIEW_NAME = "name"
+const FIELD_VIEW_QUERY = "query"
+
+// VIEW_PARAMS are the parameters of the task list a view can save, along
+// with the custom.<name> filters. Pages, cursors and presentation choices
+// such as the time zone belong to the request. The q search isn't saved, its
+// words are words of summaries, which only reach the database encrypted or
+// blind indexed.
+var VIEW_PARAMS = []string{
+	"worker_name", "worker_id", "before", "after", "on", "range", "filter",
+	"sort", "sort_by", "sort_order", "page_size", "fields", "include",
+}
+
+// View is a saved set of task list parameters, it is visible to its owner,
+// and to its team when it is shared.
+type View struct {
+	Id        uuid.UUID `gorm:"primary_key;"`
+	Name      string    `gorm:"column:name"`
+	OwnerId   string    `gorm:"column:owner_id;type:varchar(191);index"`
+	Team      string    `gorm:"column:team;type:varchar(191);index"`
+	Shared    bool      `gorm:"column:shared"`
+	Query     string    `gorm:"column:query;type:text"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+// ViewRequest creates or replaces a view. The query holds one value per
+// parameter.
+type ViewRequest struct {
+	Name   string            `json:"name"`
+	Query  map[string]string `json:"query"`
+	Shared bool              `json:"shared"`
+}
+
+type ViewResponse struct {
+	Id        uuid.UUID         `json:"id"`
+	Name      string            `json:"name"`
+	OwnerId   string            `json:"owner_id"`
+	Team      string            `json:"team"`
+	Shared    bool              `json:"shared"`
+	Query     map[string]string `json:"query"`
+	CreatedAt string            `json:"created_at"`
+	UpdatedAt string            `json:"updated_at"`
+}
+
+type ViewListResponse struct {
+	Data []ViewResponse `json:"data"`
+}
+
+// Params are the saved parameters of the view, without those views no
+// longer save.
+func (v *View) Params() url.Values {
+	params, _ := url.ParseQuery(v.Query)
+	for key := range params {
+		if !IsViewParam(key) {
+			params.Del(key)
+		}
+	}
+	return params
+}
+
+// VisibleTo tells whether a user of the team can see the view.
+func (v *View) VisibleTo(userId string, team string) bool {
+	return v.OwnerId == userId || (v.Shared && team != "" && v.Team == team)
+}
+
+func (v *View) ToResponse() ViewResponse {
+
+	params := v.Params()
+	query := make(map[string]string, len(params))
+	for key := range params {
+		query[key] = params.Get(key)
+	}
+
+	return ViewResponse{
+		Id:        v.Id,
+		Name:      v.Name,
+		OwnerId:   v.OwnerId,
+		Team:      v.Team,
+		Shared:    v.Shared,
+		Query:     query,
+		CreatedAt: FormatDate(v.CreatedAt, time.UTC),
+		UpdatedAt: FormatDate(v.UpdatedAt, time.UTC),
+	}
+}
+
+func ToViewListResponse(views []View) ViewListResponse {
+
+	data := make([]ViewResponse, 0, len(views))
+	for _, v := range views {
+		data = append(data, v.ToResponse())
+	}
+
+	return ViewListResponse{Data: data}
+}
+
+// Validate checks the name and that the query only has parameters a view can
+// save, the values are checked by the task list.
+func (vr *ViewRequest) Validate() error {
+
+	err := Validate(
+		map[string]interface{}{FIELD_VIEW_NAME: strings.TrimSpace(vr.Name), FIELD_VIEW_QUERY: vr.Query},
+		Rules{
+			FIELD_VIEW_NAME:  {Required(), MaxChars(MAX_VIEW_NAME_CHARS)},
+			FIELD_VIEW_QUERY: {NotEmpty()},
+		},
+		[]string{FIELD_VIEW_NAME, FIELD_VIEW_QUERY})
+
+	errs, _ := AsFieldErrors(err)
+
+	keys := make([]string, 0, len(vr.Query))
+	for key := range vr.Query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !IsViewParam(key) {
+			errs = append(errs, NewFieldError(FIELD_VIEW_QUERY+"."+key, "invalid", fmt.Sprintf("%s can't be saved in a view", key)))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// IsViewParam tells whether a view can save the parameter.
+func IsViewParam(key string) bool {
+
+	if strings.HasPrefix(key, "custom.") {
+		return CUSTOM_FIELD_NAME.MatchString(strings.TrimPrefix(key, "custom."))
+	}
+
+	return contains(VIEW_PARAMS, key)
+}
+
+// Params are the parameters of the request, as saved in a view.
+func (vr *ViewRequest) Params() url.Values {
+
+	params := url.Values{}
+	for key, value := range vr.Query {
+		params.Set(key, value)
+	}
+
+	return params
+}
+
+// ToView builds a new view of the owner.
+func (vr *ViewRequest) ToView(ownerId string, team string) View {
+
+	now := time.Now().UTC()
+
+	return View{
+		Id:        uuid.Must(uuid.NewV4()),
+		Name:      strings.TrimSpace(vr.Name),
+		OwnerId:   ownerId,
+		Team:      team,
+		Shared:    vr.Shared,
+		Query:     vr.Params().Encode(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// ApplyTo replaces the name, query and sharing of a copy of the view.
+func (vr *ViewRequest) ApplyTo(v View) View {
+	v.Name = strings.TrimSpace(vr.Name)
+	v.Query = vr.Params().Encode()
+	v.Shared = vr.Shared
+	v.UpdatedAt = time.Now().UTC()
+	return v
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViewRequestValidation(t *testing.T) {
+
+	vr := ViewRequest{Name: "hvac week", Query: map[string]string{"worker_name": "ana", "custom.status": "open"}}
+	assert.Nil(t, vr.Validate())
+
+	vr = ViewRequest{Name: " ", Query: map[string]string{"page": "2", "tz": "UTC"}}
+	errs, ok := AsFieldErrors(vr.Validate())
+	if assert.True(t, ok) && assert.Len(t, errs, 3) {
+		assert.Equal(t, FIELD_VIEW_NAME, errs[0].Field)
+		assert.Equal(t, "query.page", errs[1].Field)
+		assert.Equal(t, "query.tz", errs[2].Field)
+	}
+
+	vr = ViewRequest{Name: "empty"}
+	assert.Error(t, vr.Validate())
+
+	// searches would store summary words in plain text
+	vr = ViewRequest{Name: "pumps", Query: map[string]string{"q": "pump"}}
+	errs, ok = AsFieldErrors(vr.Validate())
+	if assert.True(t, ok) && assert.Len(t, errs, 1) {
+		assert.Equal(t, "query.q", errs[0].Field)
+	}
+}
+
+func TestViewParamsSkipSearchesOfOldViews(t *testing.T) {
+
+	v := View{Query: "q=pump&worker_name=ana"}
+	assert.Equal(t, "worker_name=ana", v.Params().Encode())
+	assert.Equal(t, map[string]string{"worker_name": "ana"}, v.ToResponse().Query)
+}
+
+func TestViewVisibility(t *testing.T) {
+
+	vr := ViewRequest{Name: "hvac week", Query: map[string]string{"sort": "worker_name"}}
+	v := vr.ToView("auth0|1", "hvac")
+
+	assert.True(t, v.VisibleTo("auth0|1", ""))
+	assert.False(t, v.VisibleTo("auth0|2", "hvac"))
+
+	v.Shared = true
+	assert.True(t, v.VisibleTo("auth0|2", "hvac"))
+	assert.False(t, v.VisibleTo("auth0|3", "plumbing"))
+	assert.Equal(t, map[string]string{"sort": "worker_name"}, v.ToResponse().Query)
+}
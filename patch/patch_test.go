@@ -0,0 +1,60 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+
+	doc := []byte(`{"summary":"old summary","date":"2022-05-23 03:33:01PM","custom":{"a":1,"b":2}}`)
+
+	patched, err := MergePatch(doc, []byte(`{"summary":"new summary","custom":{"a":null,"c":3}}`))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"summary":"new summary","date":"2022-05-23 03:33:01PM","custom":{"b":2,"c":3}}`, string(patched))
+
+	patched, err = MergePatch(doc, []byte(`{"date":null}`))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"summary":"old summary","custom":{"a":1,"b":2}}`, string(patched))
+
+	_, err = MergePatch(doc, []byte(`{"date":`))
+	assert.Equal(t, ErrInvalidPatch, err)
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+
+	doc := []byte(`{"summary":"old summary","tags":["a","b"],"custom":{"a":1}}`)
+
+	patched, err := ApplyJSONPatch(doc, []byte(`[
+		{"op":"test","path":"/summary","value":"old summary"},
+		{"op":"replace","path":"/summary","value":"new summary"},
+		{"op":"add","path":"/tags/1","value":"c"},
+		{"op":"add","path":"/tags/-","value":"d"},
+		{"op":"remove","path":"/tags/0"},
+		{"op":"copy","from":"/custom/a","path":"/custom/b"},
+		{"op":"move","from":"/custom/a","path":"/custom/c"}
+	]`))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"summary":"new summary","tags":["c","b","d"],"custom":{"b":1,"c":1}}`, string(patched))
+}
+
+func TestApplyJSONPatchErrors(t *testing.T) {
+
+	doc := []byte(`{"summary":"old summary","tags":["a"]}`)
+
+	_, err := ApplyJSONPatch(doc, []byte(`[{"op":"test","path":"/summary","value":"other"}]`))
+	assert.Error(t, err)
+
+	_, err = ApplyJSONPatch(doc, []byte(`[{"op":"remove","path":"/missing"}]`))
+	assert.Error(t, err)
+
+	_, err = ApplyJSONPatch(doc, []byte(`[{"op":"add","path":"/tags/5","value":"b"}]`))
+	assert.Error(t, err)
+
+	_, err = ApplyJSONPatch(doc, []byte(`[{"op":"explode","path":"/summary"}]`))
+	assert.Error(t, err)
+
+	_, err = ApplyJSONPatch(doc, []byte(`{"op":"remove"}`))
+	assert.Equal(t, ErrInvalidPatch, err)
+}
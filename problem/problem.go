@@ -0,0 +1,209 @@
+// Package problem implements the RFC 7807 error responses of the API.
+package problem
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const CONTENT_TYPE = "application/problem+json"
+
+// TYPE_PREFIX is prepended to the code of a problem to build its type URI.
+const TYPE_PREFIX = "urn:supervisorapi:problem:"
+
+// Machine readable problem codes.
+const (
+	CODE_MALFORMED_REQUEST       = "malformed_request"
+	CODE_VALIDATION_FAILED       = "validation_failed"
+	CODE_INVALID_QUERY           = "invalid_query"
+	CODE_INVALID_ID              = "invalid_id"
+	CODE_NOT_FOUND               = "not_found"
+	CODE_UNAUTHORIZED            = "unauthorized"
+	CODE_CONFLICT                = "conflict"
+	CODE_PRECONDITION_FAILED     = "precondition_failed"
+	CODE_UNSUPPORTED_MEDIA_TYPE  = "unsupported_media_type"
+	CODE_TOO_LARGE               = "request_entity_too_large"
+	CODE_INVALID_PATCH           = "invalid_patch"
+	CODE_ROLLED_BACK             = "rolled_back"
+	CODE_NOT_EXECUTED            = "not_executed"
+	CODE_IDEMPOTENCY_KEY_REUSED  = "idempotency_key_reused"
+	CODE_IDEMPOTENCY_IN_PROGRESS = "idempotency_in_progress"
+	CODE_INTERNAL_ERROR          = "internal_error"
+)
+
+// Problem is an RFC 7807 problem detail. It is also an error, so it can be
+// returned by handlers and rendered by the HTTPErrorHandler.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Code     string       `json:"code"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError points at the field of the request that failed validation.
+// Position is the 1-based character of the error in fields holding
+// expressions.
+type FieldError struct {
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Position int    `json:"position,omitempty"`
+}
+
+func New(status int, code string, detail string) *Problem {
+	return &Problem{
+		Type:   TYPE_PREFIX + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+func (p *Problem) Error() string {
+	return p.Detail
+}
+
+// WithErrors adds field errors to a copy of the problem.
+func (p *Problem) WithErrors(errs ...FieldError) *Problem {
+	withErrors := *p
+	withErrors.Errors = append(append([]FieldError{}, p.Errors...), errs...)
+	return &withErrors
+}
+
+func BadRequest(code string, detail string) *Problem {
+	return New(http.StatusBadRequest, code, detail)
+}
+
+func Malformed(detail string) *Problem {
+	return New(http.StatusBadRequest, CODE_MALFORMED_REQUEST, detail)
+}
+
+// Validation is a problem reporting all the invalid fields of a request.
+func Validation(detail string, errs ...FieldError) *Problem {
+	return New(http.StatusBadRequest, CODE_VALIDATION_FAILED, detail).WithErrors(errs...)
+}
+
+func NotFound(detail string) *Problem {
+	return New(http.StatusNotFound, CODE_NOT_FOUND, detail)
+}
+
+func InvalidId(detail string) *Problem {
+	return New(http.StatusNotFound, CODE_INVALID_ID, detail)
+}
+
+func Unauthorized() *Problem {
+	return New(http.StatusUnauthorized, CODE_UNAUTHORIZED, "you are not allowed to access this resource")
+}
+
+func Conflict(detail string) *Problem {
+	return New(http.StatusConflict, CODE_CONFLICT, detail)
+}
+
+func PreconditionFailed() *Problem {
+	return New(http.StatusPreconditionFailed, CODE_PRECONDITION_FAILED, "the task was modified, fetch it again and retry")
+}
+
+func Internal() *Problem {
+	return New(http.StatusInternalServerError, CODE_INTERNAL_ERROR, "unexpected error, try again later")
+}
+
+// Write sends the problem as the response.
+func Write(c echo.Context, p *Problem) error {
+	response := *p
+	if response.Instance == "" {
+		response.Instance = c.Request().URL.Path
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, CONTENT_TYPE)
+	return c.JSON(response.Status, response)
+}
+
+// Mapping turns an error returned by a handler into a problem.
+type Mapping struct {
+	Err    error
+	Status int
+	Code   string
+}
+
+func Map(err error, status int, code string) Mapping {
+	return Mapping{
+		Err:    err,
+		Status: status,
+		Code:   code,
+	}
+}
+
+// HTTPErrorHandler renders the errors returned by handlers and middleware as
+// problems. Problems are sent as they are, the mapped errors get their status
+// and code, echo errors keep their status and anything else is a 500.
+func HTTPErrorHandler(mappings ...Mapping) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+
+		if c.Response().Committed {
+			return
+		}
+
+		p := FromError(err, mappings...)
+		if p.Status >= http.StatusInternalServerError {
+			log.Printf("%s %s: %v", c.Request().Method, c.Request().URL.Path, err)
+		}
+
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(p.Status)
+		} else {
+			err = Write(c, p)
+		}
+		if err != nil {
+			log.Printf("unable to write problem: %v", err)
+		}
+	}
+}
+
+// FromError converts an error into a problem.
+func FromError(err error, mappings ...Mapping) *Problem {
+
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+
+	for _, m := range mappings {
+		if errors.Is(err, m.Err) {
+			return New(m.Status, m.Code, err.Error())
+		}
+	}
+
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return New(he.Code, codeOf(he.Code), messageOf(he))
+	}
+
+	return Internal()
+}
+
+// codeOf is the code of the problems raised by echo and its middleware.
+func codeOf(status int) string {
+	switch {
+	case status == http.StatusBadRequest:
+		return CODE_MALFORMED_REQUEST
+	case status >= http.StatusInternalServerError:
+		return CODE_INTERNAL_ERROR
+	}
+
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+func messageOf(he *echo.HTTPError) string {
+	if message, ok := he.Message.(string); ok {
+		return message
+	}
+	return http.StatusText(he.Code)
+}
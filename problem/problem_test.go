@@ -0,0 +1,62 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var errMockedNotFound = errors.New("record not found")
+
+func serveError(err error) (*httptest.ResponseRecorder, Problem) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/v1/tasks", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	HTTPErrorHandler(Map(errMockedNotFound, http.StatusNotFound, CODE_NOT_FOUND))(err, c)
+
+	var p Problem
+	json.Unmarshal(rec.Body.Bytes(), &p)
+	return rec, p
+}
+
+func TestHTTPErrorHandlerWritesProblems(t *testing.T) {
+	rec, p := serveError(Validation("summary max size is 2500 characters", FieldError{Field: "summary", Code: "too_long", Message: "summary max size is 2500 characters"}))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, CONTENT_TYPE, rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, TYPE_PREFIX+CODE_VALIDATION_FAILED, p.Type)
+	assert.Equal(t, "Bad Request", p.Title)
+	assert.Equal(t, "/v1/tasks", p.Instance)
+	assert.Equal(t, []FieldError{{Field: "summary", Code: "too_long", Message: "summary max size is 2500 characters"}}, p.Errors)
+}
+
+func TestHTTPErrorHandlerMapsErrors(t *testing.T) {
+	rec, p := serveError(fmt.Errorf("get task: %w", errMockedNotFound))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, CODE_NOT_FOUND, p.Code)
+
+	rec, p = serveError(echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired jwt"))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, CODE_UNAUTHORIZED, p.Code)
+	assert.Equal(t, "invalid or expired jwt", p.Detail)
+
+	rec, p = serveError(echo.ErrMethodNotAllowed)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "method_not_allowed", p.Code)
+}
+
+func TestHTTPErrorHandlerHidesUnexpectedErrors(t *testing.T) {
+	rec, p := serveError(errors.New("dial tcp 10.0.0.1:3306: connection refused"))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, CODE_INTERNAL_ERROR, p.Code)
+	assert.NotContains(t, p.Detail, "10.0.0.1")
+}
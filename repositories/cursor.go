@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm/clause"
+)
+
+const CURSOR_NEXT = "next"
+const CURSOR_PREV = "prev"
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at a row of a list, by its values of the sort fields and its
+// id as a tie-breaker. Pages start after the row, or end before it going back.
+// It is sent to clients as an opaque token.
+type Cursor struct {
+	Sort      string            `json:"s"`
+	Values    []json.RawMessage `json:"v"`
+	Id        uuid.UUID         `json:"i"`
+	Direction string            `json:"d"`
+}
+
+// Encode returns the token of the cursor.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor reads a token made by Encode.
+func DecodeCursor(token string) (Cursor, error) {
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	if c.Direction != CURSOR_NEXT && c.Direction != CURSOR_PREV {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	sort, err := ParseSort(c.Sort)
+	if err != nil || len(sort) != len(c.Values) {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	for i, key := range sort {
+		if _, err := key.sqlValue(c.Values[i]); err != nil {
+			return Cursor{}, ErrInvalidCursor
+		}
+	}
+
+	return c, nil
+}
+
+// taskCursor builds the cursor of a task for the given sort.
+func taskCursor(task models.Task, sort Sort, direction string) Cursor {
+
+	values := make([]json.RawMessage, 0, len(sort))
+	for _, key := range sort {
+		values = append(values, key.value(task))
+	}
+
+	return Cursor{
+		Sort:      sort.String(),
+		Values:    values,
+		Id:        task.Id,
+		Direction: direction,
+	}
+}
+
+// keyset returns the condition of the rows after the cursor in its direction,
+// and the sort they are read in. A row is after the cursor when it is after
+// it on a field and equal on the fields before, the id comes last.
+func (c Cursor) keyset(sort Sort) (clause.Expr, Sort) {
+
+	// going back reads the rows in reverse
+	if c.Direction == CURSOR_PREV {
+		sort = sort.reverse()
+	}
+
+	terms := make([]string, 0, len(sort)+1)
+	vars := make([]interface{}, 0)
+
+	equal := make([]string, 0, len(sort))
+	equalVars := make([]interface{}, 0)
+
+	for i, key := range sort {
+		value, _ := key.sqlValue(c.Values[i])
+
+		after, afterVars := afterValue(key, value)
+		terms = append(terms, "("+strings.Join(append(append([]string{}, equal...), after), " AND ")+")")
+		vars = append(append(vars, equalVars...), afterVars...)
+
+		eq, eqVars := equalValue(key, value)
+		equal = append(equal, eq)
+		equalVars = append(equalVars, eqVars...)
+	}
+
+	op := ">"
+	if sort[len(sort)-1].Order == SORT_DESC {
+		op = "<"
+	}
+	terms = append(terms, "("+strings.Join(append(equal, "id "+op+" ?"), " AND ")+")")
+	vars = append(append(vars, equalVars...), c.Id)
+
+	return clause.Expr{SQL: "(" + strings.Join(terms, " OR ") + ")", Vars: vars}, sort
+}
+
+// afterValue is the condition of the values after value in the order of the
+// key. Nulls come first in ascending order and last in descending order.
+func afterValue(key SortKey, value *clause.Expr) (string, []interface{}) {
+
+	column := key.column()
+
+	if value == nil {
+		if key.Order == SORT_ASC {
+			return column + " IS NOT NULL", nil
+		}
+		return "1 = 0", nil
+	}
+
+	op := ">"
+	if key.Order == SORT_DESC {
+		op = "<"
+	}
+
+	condition := column + " " + op + " " + value.SQL
+	if key.isCustom() && key.Order == SORT_DESC {
+		condition = "(" + condition + " OR " + column + " IS NULL)"
+	}
+
+	return condition, value.Vars
+}
+
+// equalValue is the condition of the values equal to value.
+func equalValue(key SortKey, value *clause.Expr) (string, []interface{}) {
+
+	if value == nil {
+		return key.column() + " IS NULL", nil
+	}
+
+	return key.column() + " = " + value.SQL, value.Vars
+}
+
+func reverse(order string) string {
+	if order == SORT_DESC {
+		return SORT_ASC
+	}
+	return SORT_DESC
+}
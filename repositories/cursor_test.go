@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func cursorTestTasks(n int) []models.Task {
+
+	tasks := make([]models.Task, 0, n)
+	for i := 0; i < n; i++ {
+		tasks = append(tasks, models.Task{
+			Id:   uuid.Must(uuid.NewV4()),
+			Date: sql.NullTime{Valid: true, Time: time.Date(2022, time.May, 23-i, 15, 33, 1, 0, time.UTC)},
+		})
+	}
+
+	return tasks
+}
+
+func TestCursorEncodeDecode(t *testing.T) {
+
+	cursor := Cursor{
+		Sort:      "-date,custom.priority",
+		Values:    []json.RawMessage{json.RawMessage(`"2022-05-23T15:33:01Z"`), json.RawMessage(`3`)},
+		Id:        uuid.Must(uuid.NewV4()),
+		Direction: CURSOR_NEXT,
+	}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	assert.Nil(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	_, err = DecodeCursor("not a cursor")
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	summary := cursor
+	summary.Sort = "-summary,custom.priority"
+	_, err = DecodeCursor(summary.Encode())
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	missing := cursor
+	missing.Values = missing.Values[:1]
+	_, err = DecodeCursor(missing.Encode())
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	badDate := cursor
+	badDate.Values = []json.RawMessage{json.RawMessage(`"yesterday"`), json.RawMessage(`3`)}
+	_, err = DecodeCursor(badDate.Encode())
+	assert.Equal(t, ErrInvalidCursor, err)
+}
+
+func TestAddCursorMustMatchTheQuery(t *testing.T) {
+
+	token := Cursor{Sort: "-date", Values: []json.RawMessage{json.RawMessage(`"2022-05-23T15:33:01Z"`)}, Direction: CURSOR_NEXT}.Encode()
+
+	query := NewListQuery()
+	assert.Nil(t, query.AddPageAndPageSize("", "10"))
+	assert.Nil(t, query.AddSorting("", ""))
+	assert.Nil(t, query.AddCursor(token))
+	assert.NotNil(t, query.Pagination.Cursor)
+
+	offset, limit := query.GetOffsetLimit()
+	assert.Equal(t, 0, offset)
+	assert.Equal(t, 11, limit)
+
+	query = NewListQuery()
+	assert.Nil(t, query.AddPageAndPageSize("", "10"))
+	assert.Nil(t, query.AddSorting("name", "asc"))
+	assert.EqualError(t, query.AddCursor(token), "cursor doesn't match the sort")
+
+	query = NewListQuery()
+	assert.Nil(t, query.AddPageAndPageSize("2", "10"))
+	assert.Nil(t, query.AddSorting("", ""))
+	assert.EqualError(t, query.AddCursor(token), "page and cursor can't be used together")
+}
+
+func TestPageTasksCursors(t *testing.T) {
+
+	tasks := cursorTestTasks(4)
+
+	// first page with a row to spare
+	query := NewListQuery()
+	assert.Nil(t, query.AddPageAndPageSize("1", "3"))
+	assert.Nil(t, query.AddSorting("", ""))
+
+	page, next, prev := query.PageTasks(tasks)
+	assert.Equal(t, tasks[:3], page)
+	assert.Equal(t, "", prev)
+	cursor, err := DecodeCursor(next)
+	assert.Nil(t, err)
+	assert.Equal(t, tasks[2].Id, cursor.Id)
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`"2022-05-21T15:33:01Z"`)}, cursor.Values)
+	assert.Equal(t, CURSOR_NEXT, cursor.Direction)
+
+	// last page after the cursor
+	assert.Nil(t, query.AddCursor(next))
+	page, next, prev = query.PageTasks(tasks[3:])
+	assert.Equal(t, tasks[3:], page)
+	assert.Equal(t, "", next)
+	cursor, err = DecodeCursor(prev)
+	assert.Nil(t, err)
+	assert.Equal(t, tasks[3].Id, cursor.Id)
+	assert.Equal(t, CURSOR_PREV, cursor.Direction)
+
+	// going back the rows come in reverse, with a row to spare
+	query = NewListQuery()
+	assert.Nil(t, query.AddPageAndPageSize("", "2"))
+	assert.Nil(t, query.AddSorting("", ""))
+	assert.Nil(t, query.AddCursor(prev))
+	page, next, prev = query.PageTasks([]models.Task{tasks[2], tasks[1], tasks[0]})
+	assert.Equal(t, tasks[1:3], page)
+	assert.NotEqual(t, "", next)
+	assert.NotEqual(t, "", prev)
+}
+
+func TestNextBatchMovesAfterTheBatch(t *testing.T) {
+
+	tasks := cursorTestTasks(5)
+
+	query := NewListQuery()
+	assert.Nil(t, query.AddPageAndPageSize("3", "2"))
+	assert.Nil(t, query.AddSorting("", ""))
+
+	// batches start from the top, whatever the page
+	query.StartBatches(2)
+	offset, limit := query.GetOffsetLimit()
+	assert.Equal(t, 0, offset)
+	assert.Equal(t, 3, limit)
+
+	batch, more := query.NextBatch(tasks[:3])
+	assert.Equal(t, tasks[:2], batch)
+	assert.True(t, more)
+	if assert.NotNil(t, query.Pagination.Cursor) {
+		assert.Equal(t, tasks[1].Id, query.Pagination.Cursor.Id)
+		assert.Equal(t, CURSOR_NEXT, query.Pagination.Cursor.Direction)
+	}
+
+	batch, more = query.NextBatch(tasks[2:4])
+	assert.Equal(t, tasks[2:4], batch)
+	assert.False(t, more)
+}
+
+func TestListTasksWithCursorDoesNotSkipOrRepeat(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	created := make([]uuid.UUID, 0)
+	for i := 0; i < 5; i++ {
+		task, err := mockedTaskRequest.ToTask("auth0|1", "joseph", time.UTC)
+		assert.Nil(t, err)
+		_, err = mockedRepo.CreateTask(task)
+		assert.Nil(t, err)
+		created = append(created, task.Id)
+	}
+
+	query := NewListQuery()
+	assert.Nil(t, query.AddPageAndPageSize("", "2"))
+	assert.Nil(t, query.AddSorting("", ""))
+
+	tasks, err := mockedRepo.ListTasks(query)
+	assert.Nil(t, err)
+	firstPage, next, _ := query.PageTasks(tasks)
+	assert.Len(t, firstPage, 2)
+
+	// a task created between page loads doesn't shift the next pages
+	task, err := mockedTaskRequest.ToTask("auth0|1", "joseph", time.UTC)
+	assert.Nil(t, err)
+	_, err = mockedRepo.CreateTask(task)
+	assert.Nil(t, err)
+
+	seen := make(map[uuid.UUID]bool)
+	for _, task := range firstPage {
+		seen[task.Id] = true
+	}
+
+	for next != "" {
+		assert.Nil(t, query.AddCursor(next))
+		tasks, err := mockedRepo.ListTasks(query)
+		assert.Nil(t, err)
+
+		var page []models.Task
+		page, next, _ = query.PageTasks(tasks)
+		for _, task := range page {
+			assert.False(t, seen[task.Id])
+			seen[task.Id] = true
+		}
+	}
+
+	for _, id := range created {
+		assert.True(t, seen[id])
+	}
+}
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"github.com/MrBolas/SupervisorAPI/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CustomFieldsRepository interface {
+	GetCustomFieldSchema(team string) (models.CustomFieldSchema, error)
+	SaveCustomFieldSchema(schema models.CustomFieldSchema) (models.CustomFieldSchema, error)
+}
+
+type CustomFieldRepository struct {
+	db *gorm.DB
+}
+
+func NewCustomFieldsRepository(db *gorm.DB) *CustomFieldRepository {
+	return &CustomFieldRepository{
+		db: db,
+	}
+}
+
+func (r CustomFieldRepository) GetCustomFieldSchema(team string) (models.CustomFieldSchema, error) {
+	var schema models.CustomFieldSchema
+
+	if err := r.db.Where("team = ?", team).First(&schema).Error; err != nil {
+		return models.CustomFieldSchema{}, err
+	}
+
+	return schema, nil
+}
+
+// SaveCustomFieldSchema creates or replaces the schema of a team.
+func (r CustomFieldRepository) SaveCustomFieldSchema(schema models.CustomFieldSchema) (models.CustomFieldSchema, error) {
+
+	err := r.db.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&schema).Error
+	if err != nil {
+		return models.CustomFieldSchema{}, err
+	}
+
+	return r.GetCustomFieldSchema(schema.Team)
+}
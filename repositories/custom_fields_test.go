@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSaveCustomFieldSchemaReplacesSchema(t *testing.T) {
+
+	mockedRepo := NewCustomFieldsRepository(db)
+	defer teardown(t)
+
+	_, err := mockedRepo.GetCustomFieldSchema("hvac")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	_, err = mockedRepo.SaveCustomFieldSchema(models.CustomFieldSchema{
+		Team:      "hvac",
+		Schema:    `{"type":"object"}`,
+		UpdatedBy: "auth0|1",
+		UpdatedAt: time.Now().UTC(),
+	})
+	assert.Nil(t, err)
+
+	schema, err := mockedRepo.SaveCustomFieldSchema(models.CustomFieldSchema{
+		Team:      "hvac",
+		Schema:    `{"type":"object","properties":{"work_order":{"type":"string"}}}`,
+		UpdatedBy: "auth0|2",
+		UpdatedAt: time.Now().UTC(),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, schema.UpdatedBy, "auth0|2")
+	assert.Equal(t, schema.Schema, `{"type":"object","properties":{"work_order":{"type":"string"}}}`)
+}
+
+func TestListTasksFiltersByCustomField(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	taskRequest := mockedTaskRequest
+	taskRequest.Custom = models.CustomFields{"work_order": "WO-1"}
+	task, err := taskRequest.ToTask("auth0|1", "joseph", time.UTC)
+	assert.Nil(t, err)
+	_, err = mockedRepo.CreateTask(task)
+	assert.Nil(t, err)
+
+	task, err = mockedTaskRequest.ToTask("auth0|1", "joseph", time.UTC)
+	assert.Nil(t, err)
+	_, err = mockedRepo.CreateTask(task)
+	assert.Nil(t, err)
+
+	query := NewListQuery()
+	query.CustomFilters["work_order"] = "WO-1"
+	query.Sort = Sort{{Field: "date", Order: "asc"}}
+	query.Pagination = Pagination{Page: 1, PageSize: 10}
+
+	tasks, err := mockedRepo.ListTasks(query)
+	assert.Nil(t, err)
+	assert.Len(t, tasks, 1)
+}
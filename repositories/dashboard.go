@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"github.com/MrBolas/SupervisorAPI/models"
+	"gorm.io/gorm"
+)
+
+// RECENT_ACTIVITY_SIZE is the number of tasks of the activity feed.
+const RECENT_ACTIVITY_SIZE = 10
+
+const ROLE_TECHNICIAN = "technician"
+
+type DashboardRepository interface {
+	GetDashboard(team string, today models.DateRange, week models.DateRange, day string) (models.Dashboard, error)
+}
+
+type DashboardRepo struct {
+	db *gorm.DB
+}
+
+func NewDashboardRepository(db *gorm.DB) *DashboardRepo {
+	return &DashboardRepo{
+		db: db,
+	}
+}
+
+// GetDashboard summarizes the tasks of the workers of the team, or of every
+// worker when the team is empty. Tasks are overdue when their due date is
+// before day and they aren't done.
+func (r DashboardRepo) GetDashboard(team string, today models.DateRange, week models.DateRange, day string) (models.Dashboard, error) {
+
+	var dashboard models.Dashboard
+
+	err := r.tasks(team).Where("date >= ? AND date < ?", today.After, today.Before).Count(&dashboard.TasksToday).Error
+	if err != nil {
+		return models.Dashboard{}, err
+	}
+
+	err = r.tasks(team).Where("date >= ? AND date < ?", week.After, week.Before).Count(&dashboard.TasksThisWeek).Error
+	if err != nil {
+		return models.Dashboard{}, err
+	}
+
+	err = r.tasks(team).
+		Where("JSON_UNQUOTE(JSON_EXTRACT(custom, ?)) < ?", "$."+models.DUE_DATE_FIELD, day).
+		Where("COALESCE(JSON_UNQUOTE(JSON_EXTRACT(custom, ?)), '') <> ?", "$.status", models.STATUS_DONE).
+		Count(&dashboard.Overdue).Error
+	if err != nil {
+		return models.Dashboard{}, err
+	}
+
+	err = r.tasks(team).
+		Where("JSON_UNQUOTE(JSON_EXTRACT(custom, ?)) = ?", "$.status", models.STATUS_PENDING_REVIEW).
+		Count(&dashboard.PendingReview).Error
+	if err != nil {
+		return models.Dashboard{}, err
+	}
+
+	// technicians without tasks today
+	active := r.db.Model(&models.Task{}).Select("worker_id").
+		Where("worker_id IS NOT NULL").
+		Where("date >= ? AND date < ?", today.After, today.Before)
+	idle := r.db.Where("role = ?", ROLE_TECHNICIAN).Where("id NOT IN (?)", active)
+	if team != "" {
+		idle = idle.Where("team = ?", team)
+	}
+	dashboard.IdleTechnicians = make([]models.User, 0)
+	if err := idle.Order("nickname asc").Order("id asc").Find(&dashboard.IdleTechnicians).Error; err != nil {
+		return models.Dashboard{}, err
+	}
+
+	dashboard.RecentActivity = make([]models.Task, 0)
+	err = r.tasks(team).Order("date desc").Order("id desc").Limit(RECENT_ACTIVITY_SIZE).Find(&dashboard.RecentActivity).Error
+	if err != nil {
+		return models.Dashboard{}, err
+	}
+
+	return dashboard, nil
+}
+
+// tasks are the tasks of the workers of the team.
+func (r DashboardRepo) tasks(team string) *gorm.DB {
+
+	q := r.db.Model(&models.Task{})
+	if team != "" {
+		q = q.Where("worker_id IN (?)", r.db.Model(&models.User{}).Select("id").Where("team = ?", team))
+	}
+
+	return q
+}
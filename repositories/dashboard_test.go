@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDashboardSummarizesTheTeam(t *testing.T) {
+
+	tasksRepo := NewTasksRepository(db)
+	usersRepo := NewUsersRepository(db)
+	dashboardRepo := NewDashboardRepository(db)
+	defer teardown(t)
+
+	for _, user := range []models.User{
+		{Id: "auth0|1", Nickname: "ana", Role: ROLE_TECHNICIAN, Team: "hvac"},
+		{Id: "auth0|2", Nickname: "bob", Role: ROLE_TECHNICIAN, Team: "hvac"},
+		{Id: "auth0|3", Nickname: "eve", Role: ROLE_TECHNICIAN, Team: "plumbing"},
+	} {
+		_, err := usersRepo.SyncUser(user)
+		assert.Nil(t, err)
+	}
+
+	now := time.Date(2024, time.March, 6, 10, 0, 0, 0, time.UTC)
+	today, _ := models.NamedRange(models.RANGE_TODAY, now, time.UTC)
+	week, _ := models.NamedRange(models.RANGE_THIS_WEEK, now, time.UTC)
+
+	tasks := []struct {
+		workerId string
+		date     time.Time
+		custom   models.CustomFields
+	}{
+		{"auth0|1", now, models.CustomFields{"status": models.STATUS_PENDING_REVIEW}},
+		{"auth0|1", now.AddDate(0, 0, -1), models.CustomFields{"due_date": "2024-03-01"}},
+		{"auth0|2", now.AddDate(0, 0, -7), models.CustomFields{"due_date": "2024-03-01", "status": models.STATUS_DONE}},
+		{"auth0|3", now, models.CustomFields{"status": models.STATUS_PENDING_REVIEW}},
+	}
+	for _, task := range tasks {
+		request := mockedTaskRequest
+		request.Custom = task.custom
+		newTask, err := request.ToTask(task.workerId, task.workerId, time.UTC)
+		assert.Nil(t, err)
+		newTask.Date.Time = task.date
+		_, err = tasksRepo.CreateTask(newTask)
+		assert.Nil(t, err)
+	}
+
+	summary, err := dashboardRepo.GetDashboard("hvac", today, week, "2024-03-06")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), summary.TasksToday)
+	assert.Equal(t, int64(2), summary.TasksThisWeek)
+	assert.Equal(t, int64(1), summary.Overdue)
+	assert.Equal(t, int64(1), summary.PendingReview)
+	if assert.Len(t, summary.IdleTechnicians, 1) {
+		assert.Equal(t, "auth0|2", summary.IdleTechnicians[0].Id)
+	}
+	assert.Len(t, summary.RecentActivity, 3)
+
+	summary, err = dashboardRepo.GetDashboard("", today, week, "2024-03-06")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), summary.TasksToday)
+	assert.Len(t, summary.RecentActivity, 4)
+}
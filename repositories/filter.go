@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/filter"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"gorm.io/gorm/clause"
+)
+
+// TASK_FILTER_SCHEMA is the whitelist of the task fields of filter
+// expressions. Custom fields are filtered as custom.<name>.
+var TASK_FILTER_SCHEMA = filter.Schema{
+	Fields: map[string]filter.Type{
+		"worker_id":   filter.TYPE_STRING,
+		"worker_name": filter.TYPE_STRING,
+		"date":        filter.TYPE_DATE,
+		"version":     filter.TYPE_NUMBER,
+	},
+	Families: map[string]filter.Family{
+		CUSTOM_FILTER_PREFIX: {Type: filter.TYPE_ANY, Name: models.CUSTOM_FIELD_NAME},
+	},
+}
+
+// FILTER_OPERATORS are the SQL operators of the filter operators.
+var FILTER_OPERATORS = map[string]string{
+	filter.OP_EQ: "=",
+	filter.OP_NE: "<>",
+	filter.OP_GT: ">",
+	filter.OP_GE: ">=",
+	filter.OP_LT: "<",
+	filter.OP_LE: "<=",
+	filter.OP_IN: "IN",
+}
+
+// AddFilter adds a filter expression, tasks must match it as well as the
+// other filters. Dates without zone are read in loc.
+func (lq *ListQuery) AddFilter(expression string, loc *time.Location) error {
+
+	if strings.TrimSpace(expression) == "" {
+		return nil
+	}
+
+	node, err := filter.Parse(expression, TASK_FILTER_SCHEMA, loc)
+	if err != nil {
+		return err
+	}
+	lq.Filter = node
+
+	return nil
+}
+
+// filterClause translates a filter expression into a where clause. Field
+// names come from the whitelist and values are always bound as parameters.
+func filterClause(node filter.Node) clause.Expr {
+
+	switch n := node.(type) {
+	case filter.And:
+		return joinClauses("AND", filterClause(n.Left), filterClause(n.Right))
+
+	case filter.Or:
+		return joinClauses("OR", filterClause(n.Left), filterClause(n.Right))
+
+	case filter.Not:
+		inner := filterClause(n.Node)
+		return clause.Expr{SQL: "NOT (" + inner.SQL + ")", Vars: inner.Vars}
+
+	case filter.Comparison:
+		column, vars := filterColumn(n.Field)
+		if n.Op == filter.OP_IN {
+			return clause.Expr{SQL: column + " IN ?", Vars: append(vars, n.Values)}
+		}
+		return clause.Expr{
+			SQL:  fmt.Sprintf("%s %s ?", column, FILTER_OPERATORS[n.Op]),
+			Vars: append(vars, n.Values[0]),
+		}
+	}
+
+	return clause.Expr{SQL: "1 = 1"}
+}
+
+// filterColumn is the SQL of a field, custom fields are read from the json
+// column.
+func filterColumn(field string) (string, []interface{}) {
+
+	if strings.HasPrefix(field, CUSTOM_FILTER_PREFIX) {
+		return "JSON_UNQUOTE(JSON_EXTRACT(custom, ?))", []interface{}{"$." + strings.TrimPrefix(field, CUSTOM_FILTER_PREFIX)}
+	}
+
+	return field, nil
+}
+
+func joinClauses(operator string, left clause.Expr, right clause.Expr) clause.Expr {
+	return clause.Expr{
+		SQL:  "(" + left.SQL + " " + operator + " " + right.SQL + ")",
+		Vars: append(append([]interface{}{}, left.Vars...), right.Vars...),
+	}
+}
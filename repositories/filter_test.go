@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/filter"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterClause(t *testing.T) {
+
+	query := NewListQuery()
+	err := query.AddFilter("worker_name eq 'ana' and (date ge 2024-01-01 or not custom.tag in ('hvac','electrical'))", time.UTC)
+	assert.Nil(t, err)
+
+	expr := filterClause(query.Filter)
+	assert.Equal(t, "(worker_name = ? AND (date >= ? OR NOT (JSON_UNQUOTE(JSON_EXTRACT(custom, ?)) IN ?)))", expr.SQL)
+	assert.Equal(t, []interface{}{
+		"ana",
+		time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		"$.tag",
+		[]interface{}{"hvac", "electrical"},
+	}, expr.Vars)
+}
+
+func TestAddFilterRejectsFieldsOutsideTheWhitelist(t *testing.T) {
+
+	query := NewListQuery()
+	err := query.AddFilter("summary eq 'x'", time.UTC)
+	if assert.Error(t, err) {
+		assert.Equal(t, filter.CODE_UNKNOWN_FIELD, err.(*filter.Error).Code)
+	}
+	assert.Nil(t, query.Filter)
+
+	err = query.AddFilter("custom.x') or 1=1 -- eq 'x'", time.UTC)
+	assert.Error(t, err)
+
+	assert.Nil(t, query.AddFilter("  ", time.UTC))
+	assert.Nil(t, query.Filter)
+}
+
+func TestListTasksWithFilter(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	tagged := mockedTaskRequest
+	tagged.Custom = models.CustomFields{"tag": "hvac"}
+	task, err := tagged.ToTask("auth0|1", "joseph", time.UTC)
+	assert.Nil(t, err)
+	_, err = mockedRepo.CreateTask(task)
+	assert.Nil(t, err)
+
+	other, err := mockedTaskRequest.ToTask("auth0|2", "robert", time.UTC)
+	assert.Nil(t, err)
+	_, err = mockedRepo.CreateTask(other)
+	assert.Nil(t, err)
+
+	query := NewListQuery()
+	query.Sort = Sort{{Field: "date", Order: "asc"}}
+	query.Pagination = Pagination{Page: 1, PageSize: 10}
+	assert.Nil(t, query.AddFilter("custom.tag in ('hvac', 'electrical') or worker_name eq 'nobody'", time.UTC))
+
+	tasks, err := mockedRepo.ListTasks(query)
+	assert.Nil(t, err)
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, task.Id, tasks[0].Id)
+	}
+
+	count, err := mockedRepo.CountTasks(query)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+
+	query.Filter = nil
+	assert.Nil(t, query.AddFilter("not worker_name eq 'joseph' and version ge 1", time.UTC))
+
+	tasks, err = mockedRepo.ListTasks(query)
+	assert.Nil(t, err)
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, other.Id, tasks[0].Id)
+	}
+}
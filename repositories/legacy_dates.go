@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"gorm.io/gorm"
+)
+
+// LEGACY_DATES_MIGRATION is the name of the conversion of the legacy dates
+// in the schema migrations.
+const LEGACY_DATES_MIGRATION = "legacy_dates_to_utc"
+
+var ErrLegacyDatesConverted = errors.New("legacy dates were converted already")
+
+// ConvertLegacyDates converts the task dates written in the local time of
+// loc, by servers that ran in loc before dates were stored in UTC, to UTC.
+// Only tasks.date predates UTC storage, the other tables were always written
+// in UTC. The conversion is recorded with the dates, in the same
+// transaction, and ErrLegacyDatesConverted refuses it afterwards: converted
+// dates would move again. Wall times repeated when clocks go back are read
+// with the offset before the change.
+func ConvertLegacyDates(db *gorm.DB, loc *time.Location) (int64, error) {
+
+	// tables are created outside the transaction, MySQL commits on DDL
+	if err := db.AutoMigrate(&models.SchemaMigration{}); err != nil {
+		return 0, err
+	}
+
+	var converted int64
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+
+		var runs int64
+		err := tx.Model(&models.SchemaMigration{}).Where("name = ?", LEGACY_DATES_MIGRATION).Count(&runs).Error
+		if err != nil {
+			return err
+		}
+		if runs > 0 {
+			return ErrLegacyDatesConverted
+		}
+
+		// a second run waits on the key and fails, it doesn't convert twice
+		err = tx.Create(&models.SchemaMigration{
+			Name:      LEGACY_DATES_MIGRATION,
+			Detail:    "from " + loc.String(),
+			AppliedAt: time.Now().UTC(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		var from, to sql.NullTime
+		err = tx.Model(&models.Task{}).Select("MIN(date), MAX(date)").Row().Scan(&from, &to)
+		if err != nil {
+			return err
+		}
+		if !from.Valid {
+			return nil
+		}
+
+		// wall times are read as UTC, a day around them covers any offset
+		spans := splitZone(from.Time.Add(-24*time.Hour), to.Time.Add(24*time.Hour), loc)
+		expr, args := utcDate("date", spans, loc)
+
+		result := tx.Model(&models.Task{}).Where("date IS NOT NULL").UpdateColumn("date", gorm.Expr(expr, args...))
+		converted = result.RowsAffected
+		return result.Error
+	})
+
+	return converted, err
+}
+
+// utcDate is the expression of the UTC date of a column of wall times of
+// loc, each span converted with its own offset. Spans end at the wall time
+// of their change, with their own offset.
+func utcDate(column string, spans []zoneSpan, loc *time.Location) (string, []interface{}) {
+
+	last := spans[len(spans)-1]
+	if len(spans) == 1 {
+		return "CONVERT_TZ(" + column + ", ?, '+00:00')", []interface{}{last.Offset}
+	}
+
+	var b strings.Builder
+	args := make([]interface{}, 0, len(spans)*2)
+
+	b.WriteString("CASE")
+	for _, span := range spans[:len(spans)-1] {
+		offset := zoneOffset(span.Until.Add(-time.Second), loc)
+		b.WriteString(" WHEN " + column + " < ? THEN CONVERT_TZ(" + column + ", ?, '+00:00')")
+		args = append(args, span.Until.Add(time.Duration(offset)*time.Second), span.Offset)
+	}
+	b.WriteString(" ELSE CONVERT_TZ(" + column + ", ?, '+00:00') END")
+	args = append(args, last.Offset)
+
+	return b.String(), args
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUtcDate(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	expr, args := utcDate("date", []zoneSpan{{Offset: "+01:00"}}, berlin)
+	assert.Equal(t, "CONVERT_TZ(date, ?, '+00:00')", expr)
+	assert.Equal(t, []interface{}{"+01:00"}, args)
+
+	// spans end at the wall time of the change: 02:00 in March, 03:00 in October
+	spans := splitZone(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, time.November, 30, 0, 0, 0, 0, time.UTC), berlin)
+	expr, args = utcDate("changed_at", spans, berlin)
+	assert.Equal(t, "CASE WHEN changed_at < ? THEN CONVERT_TZ(changed_at, ?, '+00:00') WHEN changed_at < ? THEN CONVERT_TZ(changed_at, ?, '+00:00') ELSE CONVERT_TZ(changed_at, ?, '+00:00') END", expr)
+	assert.Equal(t, []interface{}{
+		time.Date(2024, time.March, 31, 2, 0, 0, 0, time.UTC), "+01:00",
+		time.Date(2024, time.October, 27, 3, 0, 0, 0, time.UTC), "+02:00",
+		"+01:00",
+	}, args)
+}
+
+func TestConvertLegacyDatesInDatabase(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	// wall times of Berlin, as servers in Berlin wrote them
+	dates := map[string]time.Time{
+		"2024-03-04T10:00:00Z": time.Date(2024, time.March, 4, 9, 0, 0, 0, time.UTC),
+		"2024-07-01T10:00:00Z": time.Date(2024, time.July, 1, 8, 0, 0, 0, time.UTC),
+	}
+
+	created := make(map[string]time.Time)
+	for date, want := range dates {
+		request := mockedTaskRequest
+		request.Date = date
+		task, err := request.ToTask("auth0|1", "ana", time.UTC)
+		assert.Nil(t, err)
+		task, err = mockedRepo.CreateTask(task)
+		assert.Nil(t, err)
+		created[task.Id.String()] = want
+	}
+
+	converted, err := ConvertLegacyDates(db, berlin)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(dates)), converted)
+
+	// a second run would move the dates again
+	_, err = ConvertLegacyDates(db, berlin)
+	assert.Equal(t, ErrLegacyDatesConverted, err)
+	defer db.Where("name = ?", LEGACY_DATES_MIGRATION).Delete(&models.SchemaMigration{})
+
+	tasks, err := mockedRepo.ListTasks(NewListQuery())
+	assert.Nil(t, err)
+	for _, task := range tasks {
+		if want, ok := created[task.Id.String()]; ok {
+			assert.True(t, want.Equal(task.Date.Time), task.Date.Time.String())
+		}
+	}
+}
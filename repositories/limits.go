@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"github.com/MrBolas/SupervisorAPI/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LimitsRepository interface {
+	GetTeamLimits(team string) (models.TeamLimits, error)
+	SaveTeamLimits(limits models.TeamLimits) (models.TeamLimits, error)
+}
+
+type LimitRepository struct {
+	db *gorm.DB
+}
+
+func NewLimitsRepository(db *gorm.DB) *LimitRepository {
+	return &LimitRepository{
+		db: db,
+	}
+}
+
+func (r LimitRepository) GetTeamLimits(team string) (models.TeamLimits, error) {
+	var limits models.TeamLimits
+
+	if err := r.db.Where("team = ?", team).First(&limits).Error; err != nil {
+		return models.TeamLimits{}, err
+	}
+
+	return limits, nil
+}
+
+// SaveTeamLimits creates or replaces the limits of a team.
+func (r LimitRepository) SaveTeamLimits(limits models.TeamLimits) (models.TeamLimits, error) {
+
+	err := r.db.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&limits).Error
+	if err != nil {
+		return models.TeamLimits{}, err
+	}
+
+	return r.GetTeamLimits(limits.Team)
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSaveTeamLimitsReplacesLimits(t *testing.T) {
+
+	mockedRepo := NewLimitsRepository(db)
+	defer teardown(t)
+
+	_, err := mockedRepo.GetTeamLimits("hvac")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	request := models.TeamLimitsRequest{MaxSummaryChars: 500}
+	_, err = mockedRepo.SaveTeamLimits(request.ToTeamLimits("hvac", "auth0|1"))
+	assert.Nil(t, err)
+
+	request.MaxSummaryChars = 5000
+	limits, err := mockedRepo.SaveTeamLimits(request.ToTeamLimits("hvac", "auth0|2"))
+	assert.Nil(t, err)
+	assert.Equal(t, limits.UpdatedBy, "auth0|2")
+	assert.Equal(t, limits.ToLimits().MaxSummaryChars, 5000)
+}
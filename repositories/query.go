@@ -5,37 +5,52 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/filter"
+	"github.com/MrBolas/SupervisorAPI/models"
 )
 
 const DEFAULT_PAGE = 1
 const DEFAULT_PAGE_SIZE = 20
 const MAX_PAGE_SIZE = 40
+const CUSTOM_FILTER_PREFIX = "custom."
+
+const QUERY_AFTER = "after"
+const QUERY_BEFORE = "before"
+const QUERY_ON = "on"
+const QUERY_RANGE = "range"
+
+// INTERVAL_FROM is the interval filter of the start of on and range, which
+// includes its bound. The after parameter excludes it.
+const INTERVAL_FROM = "from"
 
 type ListQuery struct {
+	Search          string
+	SearchTokens    []string
 	Filters         map[string]interface{}
 	IntervalFilters map[string]interface{}
+	CustomFilters   map[string]string
+	Filter          filter.Node
 	Sort            Sort
 	Pagination      Pagination
 }
 
-type Sort struct {
-	By    string
-	Order string
-}
-
+// Pagination is either a page number, or a cursor when Cursor is not nil.
 type Pagination struct {
 	Page     int
 	PageSize int
+	Cursor   *Cursor
 }
 
 func NewListQuery() ListQuery {
 	return ListQuery{
 		Filters:         make(map[string]interface{}),
 		IntervalFilters: make(map[string]interface{}),
-		Sort: Sort{
-			By:    "",
-			Order: "",
-		},
+		CustomFilters:   make(map[string]string),
+		Sort:            Sort{},
 		Pagination: Pagination{
 			Page:     0,
 			PageSize: 0,
@@ -43,7 +58,9 @@ func NewListQuery() ListQuery {
 	}
 }
 
-func (lq *ListQuery) AddListTaskFilters(queryParamaters url.Values, isManager bool) error {
+// AddListTaskFilters adds the task filters of the query parameters. Dates
+// without zone are read in loc.
+func (lq *ListQuery) AddListTaskFilters(queryParamaters url.Values, isManager bool, loc *time.Location) error {
 
 	for key, val := range queryParamaters {
 
@@ -56,16 +73,141 @@ func (lq *ListQuery) AddListTaskFilters(queryParamaters url.Values, isManager bo
 			if key == "worker_name" {
 				lq.Filters["worker_name"] = val[0]
 			}
+
+			if key == "worker_id" {
+				lq.Filters["worker_id"] = val[0]
+			}
+		}
+
+		// custom fields are filtered as custom.<name>=<value>
+		if strings.HasPrefix(key, CUSTOM_FILTER_PREFIX) {
+			name := strings.TrimPrefix(key, CUSTOM_FILTER_PREFIX)
+			if !models.CUSTOM_FIELD_NAME.MatchString(name) {
+				return fmt.Errorf("invalid custom field filter %s", key)
+			}
+			lq.CustomFilters[name] = val[0]
+		}
+	}
+
+	return lq.AddDateFilters(queryParamaters, time.Now(), loc)
+}
+
+// AddDateFilters adds the date range of the query parameters: before and
+// after, a whole day with on, or a named range. Relative dates and ranges are
+// resolved at now in loc.
+func (lq *ListQuery) AddDateFilters(queryParamaters url.Values, now time.Time, loc *time.Location) error {
+
+	var dates models.DateRange
+	var err error
+
+	given := make([]string, 0)
+	for _, key := range []string{QUERY_RANGE, QUERY_ON, QUERY_AFTER, QUERY_BEFORE} {
+		if queryParamaters.Get(key) != "" {
+			given = append(given, key)
+		}
+	}
+
+	// ranges and days have both bounds
+	if len(given) > 1 && (given[0] == QUERY_RANGE || given[0] == QUERY_ON) {
+		return fmt.Errorf("%s can't be used together with %s", given[0], given[1])
+	}
+
+	switch {
+	case queryParamaters.Get(QUERY_RANGE) != "":
+		dates, err = models.NamedRange(queryParamaters.Get(QUERY_RANGE), now, loc)
+		if err != nil {
+			return err
+		}
+
+	case queryParamaters.Get(QUERY_ON) != "":
+		dates, err = models.ParseDay(queryParamaters.Get(QUERY_ON), loc)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %s", QUERY_ON, err.Error())
+		}
+
+	default:
+		if value := queryParamaters.Get(QUERY_AFTER); value != "" {
+			dates.After, err = models.ParseDateBound(value, now, loc)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %s", QUERY_AFTER, err.Error())
+			}
+		}
+
+		if value := queryParamaters.Get(QUERY_BEFORE); value != "" {
+			dates.Before, err = models.ParseDateBound(value, now, loc)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %s", QUERY_BEFORE, err.Error())
+			}
+		}
+
+		if !dates.After.IsZero() && !dates.Before.IsZero() && !dates.After.Before(dates.Before) {
+			return fmt.Errorf("%s must be earlier than %s", QUERY_AFTER, QUERY_BEFORE)
+		}
+	}
+
+	if !dates.After.IsZero() {
+		if len(given) > 0 && (given[0] == QUERY_RANGE || given[0] == QUERY_ON) {
+			lq.IntervalFilters[INTERVAL_FROM] = dates.After
+		} else {
+			lq.IntervalFilters[QUERY_AFTER] = dates.After
+		}
+	}
+	if !dates.Before.IsZero() {
+		lq.IntervalFilters[QUERY_BEFORE] = dates.Before
+	}
+
+	return nil
+}
+
+// DateRange is the range of dates the query is filtered by.
+func (lq *ListQuery) DateRange() models.DateRange {
+
+	var dates models.DateRange
+	if after, ok := lq.IntervalFilters[QUERY_AFTER].(time.Time); ok {
+		dates.After = after
+	}
+	if from, ok := lq.IntervalFilters[INTERVAL_FROM].(time.Time); ok {
+		dates.After = from
+	}
+	if before, ok := lq.IntervalFilters[QUERY_BEFORE].(time.Time); ok {
+		dates.Before = before
+	}
+
+	return dates
+}
+
+// AddSearch adds the blind index tokens of the words of a search, tasks must
+// contain all of them.
+func (lq *ListQuery) AddSearch(search string, index encryption.BlindIndex) error {
+
+	if search == "" {
+		return nil
+	}
+
+	tokens := index.Tokens(search)
+	if len(tokens) == 0 {
+		return fmt.Errorf("q must have a word of at least %d characters", encryption.MIN_TOKEN_CHARS)
+	}
+	lq.SearchTokens = tokens
+
+	return nil
+}
+
+func (lq *ListQuery) AddListUserFilters(queryParamaters url.Values) error {
+
+	for key, val := range queryParamaters {
+
+		// the value is an array of data, only use if something is there
+		if len(val) == 0 {
+			continue
 		}
 
-		// validate date
-		if key == "before" {
-			lq.IntervalFilters["before"] = val[0]
+		if key == "role" || key == "team" {
+			lq.Filters[key] = val[0]
 		}
 
-		// validate date
-		if key == "after" {
-			lq.IntervalFilters["after"] = val[0]
+		if key == "q" {
+			lq.Search = val[0]
 		}
 	}
 
@@ -102,6 +244,8 @@ func (lq *ListQuery) AddPageAndPageSize(pageParam string, pageSizeParam string)
 	return nil
 }
 
+// AddSorting sorts by a single field with sort_by and sort_order, sort_by is
+// name or the date by default.
 func (lq *ListQuery) AddSorting(sortBy string, order string) error {
 
 	if order != "asc" && order != "desc" && order != "" {
@@ -119,12 +263,126 @@ func (lq *ListQuery) AddSorting(sortBy string, order string) error {
 		dbSortBy = "worker_name"
 	}
 
-	lq.Sort.By = dbSortBy
-	lq.Sort.Order = dbOrder
+	lq.Sort = Sort{{Field: dbSortBy, Order: dbOrder}}
+
+	return nil
+}
+
+// AddSort sorts by the fields of a sort spec, see ParseSort. It replaces the
+// sort of AddSorting.
+func (lq *ListQuery) AddSort(spec string) error {
+
+	if spec == "" {
+		return nil
+	}
+
+	sort, err := ParseSort(spec)
+	if err != nil {
+		return err
+	}
+	lq.Sort = sort
+
+	return nil
+}
+
+// AddCursor switches to keyset pagination from the row of the cursor. It must
+// follow AddSorting and AddSort, the cursor must be for the same sort.
+func (lq *ListQuery) AddCursor(token string) error {
+
+	if token == "" {
+		return nil
+	}
+
+	if lq.Pagination.Page > 1 {
+		return errors.New("page and cursor can't be used together")
+	}
+
+	cursor, err := DecodeCursor(token)
+	if err != nil {
+		return err
+	}
+
+	if cursor.Sort != lq.Sort.String() {
+		return errors.New("cursor doesn't match the sort")
+	}
+
+	lq.Pagination.Page = 0
+	lq.Pagination.Cursor = &cursor
 
 	return nil
 }
 
 func (lq *ListQuery) GetOffsetLimit() (int, int) {
+
+	// cursors start after their row
+	if lq.Pagination.Cursor != nil {
+		return 0, lq.Pagination.PageSize + 1
+	}
+
 	return (lq.Pagination.Page - 1) * lq.Pagination.PageSize, lq.Pagination.PageSize + 1 // this plus one is used for pagination
 }
+
+// PageTasks takes the tasks read for the query, with the extra row used for
+// pagination, and returns the page in sort order with the tokens of the
+// cursors of the next and previous pages. Tokens are empty at the ends.
+func (lq *ListQuery) PageTasks(tasks []models.Task) ([]models.Task, string, string) {
+
+	cursor := lq.Pagination.Cursor
+	hasMore := len(tasks) > lq.Pagination.PageSize
+	if hasMore {
+		tasks = tasks[:lq.Pagination.PageSize]
+	}
+
+	// pages before the cursor are read in reverse
+	backwards := cursor != nil && cursor.Direction == CURSOR_PREV
+	if backwards {
+		page := make([]models.Task, 0, len(tasks))
+		for i := len(tasks) - 1; i >= 0; i-- {
+			page = append(page, tasks[i])
+		}
+		tasks = page
+	}
+
+	if len(tasks) == 0 {
+		return tasks, "", ""
+	}
+
+	hasNext := hasMore
+	hasPrev := lq.Pagination.Page > 1 || cursor != nil
+	if backwards {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	next, prev := "", ""
+	if hasNext {
+		next = taskCursor(tasks[len(tasks)-1], lq.Sort, CURSOR_NEXT).Encode()
+	}
+	if hasPrev {
+		prev = taskCursor(tasks[0], lq.Sort, CURSOR_PREV).Encode()
+	}
+
+	return tasks, next, prev
+}
+
+// StartBatches makes the query read the whole list in batches of batchSize
+// tasks, from its start regardless of its pagination. See NextBatch.
+func (lq *ListQuery) StartBatches(batchSize int) {
+	lq.Pagination = Pagination{Page: DEFAULT_PAGE, PageSize: batchSize}
+}
+
+// NextBatch takes the tasks read for the query, with the extra row, and
+// returns the batch. When there are more tasks the query moves after the
+// batch, so it reads the next one.
+func (lq *ListQuery) NextBatch(tasks []models.Task) ([]models.Task, bool) {
+
+	if len(tasks) <= lq.Pagination.PageSize {
+		return tasks, false
+	}
+
+	tasks = tasks[:lq.Pagination.PageSize]
+	cursor := taskCursor(tasks[len(tasks)-1], lq.Sort, CURSOR_NEXT)
+	lq.Pagination.Page = 0
+	lq.Pagination.Cursor = &cursor
+
+	return tasks, true
+}
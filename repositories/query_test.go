@@ -3,8 +3,12 @@ package repositories
 import (
 	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/MrBolas/SupervisorAPI/models"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,8 +23,7 @@ func TestCreateDefaultQuery(t *testing.T) {
 	assert.Equal(t, query.IntervalFilters, make(map[string]interface{}))
 	assert.Equal(t, query.Pagination.Page, 1)
 	assert.Equal(t, query.Pagination.PageSize, 20)
-	assert.Equal(t, query.Sort.By, "date")
-	assert.Equal(t, query.Sort.Order, "desc")
+	assert.Equal(t, query.Sort, Sort{{Field: "date", Order: "desc"}})
 }
 
 func TestPaginationErrorHandling(t *testing.T) {
@@ -50,13 +53,11 @@ func TestSortingOptions(t *testing.T) {
 	assert.Nil(t, err)
 	err = query.AddSorting("", "")
 	assert.Nil(t, err)
-	assert.Equal(t, query.Sort.By, "date")
-	assert.Equal(t, query.Sort.Order, "desc")
+	assert.Equal(t, query.Sort, Sort{{Field: "date", Order: "desc"}})
 
 	err = query.AddSorting("name", "asc")
 	assert.Nil(t, err)
-	assert.Equal(t, query.Sort.By, "worker_name")
-	assert.Equal(t, query.Sort.Order, "asc")
+	assert.Equal(t, query.Sort, Sort{{Field: "worker_name", Order: "asc"}})
 }
 
 func TestGetOffsetLimit(t *testing.T) {
@@ -71,3 +72,66 @@ func TestGetOffsetLimit(t *testing.T) {
 	assert.Equal(t, offset, 0)
 	assert.Equal(t, limit, 21)
 }
+
+func TestDateFiltersAreParsedInLocation(t *testing.T) {
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	assert.Nil(t, err)
+
+	query := NewListQuery()
+	err = query.AddListTaskFilters(url.Values{
+		"after":  []string{"2022-05-23 03:33:01PM"},
+		"before": []string{"2022-05-24T00:00:00Z"},
+	}, false, lisbon)
+	assert.Nil(t, err)
+	assert.Equal(t, query.IntervalFilters["after"], time.Date(2022, time.May, 23, 14, 33, 1, 0, time.UTC))
+	assert.Equal(t, query.IntervalFilters["before"], time.Date(2022, time.May, 24, 0, 0, 0, 0, time.UTC))
+
+	query = NewListQuery()
+	err = query.AddListTaskFilters(url.Values{"after": []string{"yesterday"}}, false, lisbon)
+	assert.Error(t, err)
+}
+
+func TestAddDateFiltersResolvesRanges(t *testing.T) {
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	assert.Nil(t, err)
+
+	now := time.Date(2024, time.March, 6, 10, 0, 0, 0, time.UTC)
+
+	query := NewListQuery()
+	err = query.AddDateFilters(url.Values{"range": []string{"last_month"}}, now, lisbon)
+	assert.Nil(t, err)
+	assert.Equal(t, models.DateRange{
+		After:  time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		Before: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+	}, query.DateRange())
+	// ranges include their start, after doesn't
+	assert.NotNil(t, query.IntervalFilters[INTERVAL_FROM])
+	assert.Nil(t, query.IntervalFilters["after"])
+
+	query = NewListQuery()
+	err = query.AddDateFilters(url.Values{"after": []string{"-7d"}}, now, lisbon)
+	assert.Nil(t, err)
+	assert.Equal(t, models.DateRange{After: time.Date(2024, time.February, 28, 0, 0, 0, 0, time.UTC)}, query.DateRange())
+	assert.Nil(t, query.IntervalFilters["before"])
+	assert.Nil(t, query.IntervalFilters[INTERVAL_FROM])
+}
+
+func TestAddDateFiltersRejectsInvalidRanges(t *testing.T) {
+
+	now := time.Date(2024, time.March, 6, 10, 0, 0, 0, time.UTC)
+
+	cases := map[string]url.Values{
+		"range can't be used together with after":                             {"range": {"today"}, "after": {"-7d"}},
+		"on can't be used together with before":                               {"on": {"2024-03-05"}, "before": {"-7d"}},
+		"range can't be used together with on":                                {"range": {"today"}, "on": {"2024-03-05"}},
+		"invalid on: " + models.ErrInvalidDay.Error():                         {"on": {"-1d"}},
+		"invalid after: " + models.ErrInvalidBound.Error():                    {"after": {"last week"}},
+		"after must be earlier than before":                                   {"after": {"-1d"}, "before": {"-7d"}},
+		`unknown range "fortnight", use ` + strings.Join(models.RANGES, ", "): {"range": {"fortnight"}},
+	}
+
+	for message, params := range cases {
+		query := NewListQuery()
+		assert.EqualError(t, query.AddDateFilters(params, now, time.UTC), message)
+	}
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SearchIndexRepository maintains the blind index of the task summaries.
+type SearchIndexRepository interface {
+	ListUnindexedTasks(after uuid.UUID, limit int) ([]models.Task, error)
+	IndexTask(task models.Task, tokens []string) error
+}
+
+// ListUnindexedTasks lists the tasks without search tokens by id, starting
+// after the given id, so a backfill can go through them in batches.
+func (r TaskRepository) ListUnindexedTasks(after uuid.UUID, limit int) ([]models.Task, error) {
+
+	var tasks []models.Task
+
+	err := r.db.
+		Where("id > ?", after).
+		Where("NOT EXISTS (?)", r.db.Model(&models.TaskSearchToken{}).Select("1").Where("task_search_tokens.task_id = tasks.id")).
+		Order("id asc").
+		Limit(limit).
+		Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// IndexTask replaces the search tokens of a task read at task.Version, the
+// tokens of its summary then. The task is locked at that version, otherwise
+// ErrVersionConflict is returned: an update changed the summary meanwhile,
+// and its tokens are kept.
+func (r TaskRepository) IndexTask(task models.Task, tokens []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+
+		var locked []models.Task
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ? AND version = ?", task.Id, task.Version).
+			Find(&locked).Error
+		if err != nil {
+			return err
+		}
+		if len(locked) == 0 {
+			return ErrVersionConflict
+		}
+
+		return saveSearchTokens(tx, models.Task{Id: task.Id, SearchTokens: tokens})
+	})
+}
+
+// saveSearchTokens replaces the search tokens of the task when it has them.
+func saveSearchTokens(tx *gorm.DB, t models.Task) error {
+
+	if t.SearchTokens == nil {
+		return nil
+	}
+
+	if err := tx.Where("task_id = ?", t.Id).Delete(&models.TaskSearchToken{}).Error; err != nil {
+		return err
+	}
+
+	if len(t.SearchTokens) == 0 {
+		return nil
+	}
+
+	return tx.Create(models.ToSearchTokens(t.Id, t.SearchTokens)).Error
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListTasksSearchesByTokens(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	index := encryption.NewBlindIndex("J8s0pQ2mXv7LcN4rT1wZ6yB3hF9kD5gA")
+
+	task, err := mockedTaskRequest.ToTask("auth0|1", "joseph", time.UTC)
+	assert.Nil(t, err)
+	task.SearchTokens = index.Tokens("replaced the compressor")
+	_, err = mockedRepo.CreateTask(task)
+	assert.Nil(t, err)
+
+	other, err := mockedTaskRequest.ToTask("auth0|1", "joseph", time.UTC)
+	assert.Nil(t, err)
+	other.SearchTokens = index.Tokens("replaced the filter")
+	_, err = mockedRepo.CreateTask(other)
+	assert.Nil(t, err)
+
+	query := NewListQuery()
+	query.Sort = Sort{{Field: "date", Order: "asc"}}
+	query.Pagination = Pagination{Page: 1, PageSize: 10}
+
+	assert.Nil(t, query.AddSearch("Compressor replaced", index))
+	tasks, err := mockedRepo.ListTasks(query)
+	assert.Nil(t, err)
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, task.Id, tasks[0].Id)
+	}
+
+	// tokens follow the summary on update and go away on delete
+	updated := task
+	updated.SearchTokens = index.Tokens("replaced the pump")
+	_, err = mockedRepo.UpdateTask(task.Id, task, updated, "auth0|1")
+	assert.Nil(t, err)
+
+	tasks, err = mockedRepo.ListTasks(query)
+	assert.Nil(t, err)
+	assert.Len(t, tasks, 0)
+
+	assert.Nil(t, mockedRepo.DeleteTask(other.Id, 0))
+	var count int64
+	db.Table("task_search_tokens").Where("task_id = ?", other.Id).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestListUnindexedTasks(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	task, err := mockedTaskRequest.ToTask("auth0|1", "joseph", time.UTC)
+	assert.Nil(t, err)
+	_, err = mockedRepo.CreateTask(task)
+	assert.Nil(t, err)
+
+	tasks, err := mockedRepo.ListUnindexedTasks(uuid.Nil, 10)
+	assert.Nil(t, err)
+	assert.Len(t, tasks, 1)
+
+	// an update since the task was read keeps its own tokens
+	stale := tasks[0]
+	stale.Version--
+	assert.Equal(t, ErrVersionConflict, mockedRepo.IndexTask(stale, []string{"0123456789abcdef0123456789abcdef"}))
+
+	assert.Nil(t, mockedRepo.IndexTask(tasks[0], []string{"0123456789abcdef0123456789abcdef"}))
+
+	tasks, err = mockedRepo.ListUnindexedTasks(uuid.Nil, 10)
+	assert.Nil(t, err)
+	assert.Len(t, tasks, 0)
+}
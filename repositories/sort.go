@@ -0,0 +1,207 @@
+package repositories
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"gorm.io/gorm/clause"
+)
+
+const SORT_ASC = "asc"
+const SORT_DESC = "desc"
+
+// MAX_SORT_FIELDS is the most fields a sort can have.
+const MAX_SORT_FIELDS = 4
+
+// SORT_FIELDS is the whitelist of sortable task fields, custom fields are
+// sorted as custom.<name>.
+var SORT_FIELDS = []string{"date", "worker_name", "worker_id", "version"}
+
+// SORT_ALIASES are the names of sort fields kept from the sort_by parameter.
+var SORT_ALIASES = map[string]string{"name": "worker_name"}
+
+// SortKey is a field of a sort and its order.
+type SortKey struct {
+	Field string
+	Order string
+}
+
+// Sort is the list of fields tasks are sorted by. The id always breaks the
+// remaining ties, in the order of the last field, so the order is stable.
+type Sort []SortKey
+
+// ParseSort parses a sort like -date,worker_name: fields separated by commas,
+// descending when prefixed by a minus.
+func ParseSort(spec string) (Sort, error) {
+
+	fields := strings.Split(spec, ",")
+	if len(fields) > MAX_SORT_FIELDS {
+		return nil, fmt.Errorf("sort can't have more than %d fields", MAX_SORT_FIELDS)
+	}
+
+	sort := make(Sort, 0, len(fields))
+	seen := make(map[string]bool)
+
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+
+		key := SortKey{Field: field, Order: SORT_ASC}
+		if strings.HasPrefix(field, "-") {
+			key = SortKey{Field: field[1:], Order: SORT_DESC}
+		}
+		if alias, ok := SORT_ALIASES[key.Field]; ok {
+			key.Field = alias
+		}
+
+		if !isSortField(key.Field) {
+			return nil, fmt.Errorf("can't sort by %q, sort by %s or custom.<name>", key.Field, strings.Join(SORT_FIELDS, ", "))
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("sort has %s more than once", key.Field)
+		}
+		seen[key.Field] = true
+
+		sort = append(sort, key)
+	}
+
+	return sort, nil
+}
+
+func isSortField(field string) bool {
+
+	if strings.HasPrefix(field, CUSTOM_FILTER_PREFIX) {
+		return models.CUSTOM_FIELD_NAME.MatchString(strings.TrimPrefix(field, CUSTOM_FILTER_PREFIX))
+	}
+
+	for _, f := range SORT_FIELDS {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String is the spec of the sort, as parsed by ParseSort.
+func (s Sort) String() string {
+
+	fields := make([]string, 0, len(s))
+	for _, key := range s {
+		if key.Order == SORT_DESC {
+			fields = append(fields, "-"+key.Field)
+			continue
+		}
+		fields = append(fields, key.Field)
+	}
+
+	return strings.Join(fields, ",")
+}
+
+// reverse is the sort in the opposite order, to read pages backwards.
+func (s Sort) reverse() Sort {
+
+	reversed := make(Sort, 0, len(s))
+	for _, key := range s {
+		reversed = append(reversed, SortKey{Field: key.Field, Order: reverse(key.Order)})
+	}
+
+	return reversed
+}
+
+// orderBy is the ORDER BY of the sort, with the id as a tie-breaker.
+func (s Sort) orderBy() []string {
+
+	columns := make([]string, 0, len(s)+1)
+	for _, key := range s {
+		columns = append(columns, key.column()+" "+key.Order)
+	}
+
+	return append(columns, "id "+s[len(s)-1].Order)
+}
+
+func (k SortKey) isCustom() bool {
+	return strings.HasPrefix(k.Field, CUSTOM_FILTER_PREFIX)
+}
+
+// column is the SQL of the field. Custom fields are compared as json so
+// numbers sort as numbers, their names are checked by ParseSort so they can
+// be part of the path.
+func (k SortKey) column() string {
+
+	if k.isCustom() {
+		return fmt.Sprintf("JSON_EXTRACT(custom, '$.%s')", strings.TrimPrefix(k.Field, CUSTOM_FILTER_PREFIX))
+	}
+
+	return k.Field
+}
+
+// value is the value of the field of a task, as stored in cursors. Missing
+// custom fields are null.
+func (k SortKey) value(task models.Task) json.RawMessage {
+
+	var value interface{}
+
+	switch {
+	case k.isCustom():
+		value = task.Custom[strings.TrimPrefix(k.Field, CUSTOM_FILTER_PREFIX)]
+	case k.Field == "date":
+		value = task.Date.Time.UTC().Format(time.RFC3339Nano)
+	case k.Field == "worker_name":
+		value = task.WorkerName
+	case k.Field == "worker_id":
+		value = task.WorkerId
+	case k.Field == "version":
+		value = task.Version
+	}
+
+	b, _ := json.Marshal(value)
+	return b
+}
+
+// sqlValue converts a cursor value of the field to an SQL expression, or
+// returns nil for null.
+func (k SortKey) sqlValue(raw json.RawMessage) (*clause.Expr, error) {
+
+	if k.isCustom() {
+		if !json.Valid(raw) {
+			return nil, ErrInvalidCursor
+		}
+		if string(raw) == "null" {
+			return nil, nil
+		}
+		return &clause.Expr{SQL: "CAST(? AS JSON)", Vars: []interface{}{string(raw)}}, nil
+	}
+
+	var value interface{}
+
+	switch k.Field {
+	case "date":
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		date, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		value = date
+	case "version":
+		n, err := strconv.Atoi(string(raw))
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		value = n
+	default:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		value = s
+	}
+
+	return &clause.Expr{SQL: "?", Vars: []interface{}{value}}, nil
+}
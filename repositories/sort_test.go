@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSort(t *testing.T) {
+
+	sort, err := ParseSort("-date, name,custom.priority")
+	assert.Nil(t, err)
+	assert.Equal(t, Sort{
+		{Field: "date", Order: SORT_DESC},
+		{Field: "worker_name", Order: SORT_ASC},
+		{Field: "custom.priority", Order: SORT_ASC},
+	}, sort)
+	assert.Equal(t, "-date,worker_name,custom.priority", sort.String())
+
+	_, err = ParseSort("-summary")
+	assert.EqualError(t, err, `can't sort by "summary", sort by date, worker_name, worker_id, version or custom.<name>`)
+
+	_, err = ParseSort("custom.bad-name")
+	assert.Error(t, err)
+
+	_, err = ParseSort("date,-date")
+	assert.EqualError(t, err, "sort has date more than once")
+
+	_, err = ParseSort("date,")
+	assert.Error(t, err)
+
+	_, err = ParseSort("date,worker_name,worker_id,version,custom.a")
+	assert.EqualError(t, err, "sort can't have more than 4 fields")
+}
+
+func TestSortOrderBy(t *testing.T) {
+
+	sort, err := ParseSort("custom.priority,-date")
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"JSON_EXTRACT(custom, '$.priority') asc", "date desc", "id desc"}, sort.orderBy())
+	assert.Equal(t, []string{"JSON_EXTRACT(custom, '$.priority') desc", "date asc", "id asc"}, sort.reverse().orderBy())
+}
+
+func TestCursorKeyset(t *testing.T) {
+
+	sort, err := ParseSort("-date,custom.priority")
+	assert.Nil(t, err)
+
+	date := time.Date(2022, time.May, 23, 15, 33, 1, 0, time.UTC)
+	id := uuid.Must(uuid.NewV4())
+	task := models.Task{Id: id, Custom: models.CustomFields{"priority": 2}}
+	task.Date.Time = date
+
+	cursor := taskCursor(task, sort, CURSOR_NEXT)
+	condition, readSort := cursor.keyset(sort)
+	assert.Equal(t, sort, readSort)
+	assert.Equal(t,
+		"((date < ?) OR (date = ? AND JSON_EXTRACT(custom, '$.priority') > CAST(? AS JSON)) "+
+			"OR (date = ? AND JSON_EXTRACT(custom, '$.priority') = CAST(? AS JSON) AND id > ?))",
+		condition.SQL)
+	assert.Equal(t, []interface{}{date, date, "2", date, "2", id}, condition.Vars)
+
+	// going back nulls come last, and missing custom fields are null
+	task.Custom = nil
+	cursor = taskCursor(task, sort, CURSOR_PREV)
+	assert.Equal(t, json.RawMessage("null"), cursor.Values[1])
+	condition, readSort = cursor.keyset(sort)
+	assert.Equal(t, sort.reverse(), readSort)
+	assert.Equal(t,
+		"((date > ?) OR (date = ? AND 1 = 0) "+
+			"OR (date = ? AND JSON_EXTRACT(custom, '$.priority') IS NULL AND id < ?))",
+		condition.SQL)
+	assert.Equal(t, []interface{}{date, date, date, id}, condition.Vars)
+}
+
+func TestAddSort(t *testing.T) {
+
+	query := NewListQuery()
+	assert.Nil(t, query.AddSorting("name", "asc"))
+	assert.Nil(t, query.AddSort(""))
+	assert.Equal(t, Sort{{Field: "worker_name", Order: SORT_ASC}}, query.Sort)
+
+	assert.Nil(t, query.AddSort("-version,date"))
+	assert.Equal(t, Sort{{Field: "version", Order: SORT_DESC}, {Field: "date", Order: SORT_ASC}}, query.Sort)
+
+	assert.Error(t, query.AddSort("priority"))
+}
+
+func TestListTasksByMultipleFieldsWithCursor(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	created := make(map[uuid.UUID]bool)
+	for i, priority := range []interface{}{2, 1, nil, 2, 10} {
+		request := mockedTaskRequest
+		if priority != nil {
+			request.Custom = models.CustomFields{"priority": priority}
+		}
+		task, err := request.ToTask("auth0|1", []string{"ana", "bob"}[i%2], time.UTC)
+		assert.Nil(t, err)
+		_, err = mockedRepo.CreateTask(task)
+		assert.Nil(t, err)
+		created[task.Id] = true
+	}
+
+	query := NewListQuery()
+	assert.Nil(t, query.AddPageAndPageSize("", "2"))
+	assert.Nil(t, query.AddSort("-custom.priority,worker_name"))
+
+	priorities := make([]interface{}, 0)
+	seen := make(map[uuid.UUID]bool)
+	next := ""
+	for {
+		tasks, err := mockedRepo.ListTasks(query)
+		assert.Nil(t, err)
+
+		var page []models.Task
+		page, next, _ = query.PageTasks(tasks)
+		for _, task := range page {
+			assert.False(t, seen[task.Id])
+			seen[task.Id] = true
+			priorities = append(priorities, task.Custom["priority"])
+		}
+
+		if next == "" {
+			break
+		}
+		assert.Nil(t, query.AddCursor(next))
+	}
+
+	// numbers sort as numbers and tasks without priority come last
+	assert.Equal(t, []interface{}{float64(10), float64(2), float64(2), float64(1), nil}, priorities)
+	assert.Equal(t, created, seen)
+}
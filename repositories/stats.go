@@ -0,0 +1,368 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+)
+
+const STATS_WORKER = "worker"
+const STATS_DAY = "day"
+const STATS_WEEK = "week"
+const STATS_MONTH = "month"
+
+const METRIC_COUNT = "count"
+const METRIC_DURATION = "duration"
+
+// DURATION_FIELD is the numeric custom field summed by the duration metric.
+const DURATION_FIELD = "duration"
+
+const MAX_GROUP_BY = 3
+
+// STATS_DIMENSIONS are the dimensions tasks can be grouped by, along with
+// custom.<name> and the aliases of STATS_ALIASES.
+var STATS_DIMENSIONS = []string{STATS_WORKER, STATS_DAY, STATS_WEEK, STATS_MONTH}
+
+// STATS_ALIASES are dimensions kept in custom fields by the teams.
+var STATS_ALIASES = map[string]string{
+	"status": CUSTOM_FILTER_PREFIX + "status",
+	"tag":    CUSTOM_FILTER_PREFIX + "tag",
+	"site":   CUSTOM_FILTER_PREFIX + "site",
+}
+
+var STATS_METRICS = []string{METRIC_COUNT, METRIC_DURATION}
+
+// MAX_ZONE_SPANS is the most offset changes of a time zone followed by the
+// periods of the stats, about 50 years of daylight saving time.
+const MAX_ZONE_SPANS = 100
+
+// Stats are the dimensions and metrics of the statistics of the tasks.
+type Stats struct {
+	GroupBy []string
+	Metrics []string
+}
+
+// statsRow is a group of the aggregation, columns of dimensions that aren't
+// grouped by are empty.
+type statsRow struct {
+	WorkerId   string
+	WorkerName string
+	Period     sql.NullString
+	G0         sql.NullString
+	G1         sql.NullString
+	G2         sql.NullString
+	Count      int64
+	Duration   float64
+}
+
+// ParseStats parses group_by and metrics, lists separated by commas. Without
+// metrics tasks are counted.
+func ParseStats(groupBy string, metrics string) (Stats, error) {
+
+	stats := Stats{GroupBy: []string{}, Metrics: []string{METRIC_COUNT}}
+	periods := 0
+
+	if groupBy != "" {
+		for _, dimension := range strings.Split(groupBy, ",") {
+			dimension = strings.TrimSpace(dimension)
+
+			switch {
+			case contains(STATS_DIMENSIONS, dimension):
+				if dimension != STATS_WORKER {
+					periods++
+				}
+			case STATS_ALIASES[dimension] != "":
+			case strings.HasPrefix(dimension, CUSTOM_FILTER_PREFIX) && models.CUSTOM_FIELD_NAME.MatchString(strings.TrimPrefix(dimension, CUSTOM_FILTER_PREFIX)):
+			default:
+				return Stats{}, fmt.Errorf("can't group by %q, group by %s, %s or custom.<name>",
+					dimension, strings.Join(STATS_DIMENSIONS, ", "), strings.Join(aliasNames(), ", "))
+			}
+
+			if contains(stats.GroupBy, dimension) {
+				return Stats{}, fmt.Errorf("%s is grouped by more than once", dimension)
+			}
+			stats.GroupBy = append(stats.GroupBy, dimension)
+		}
+	}
+
+	if periods > 1 {
+		return Stats{}, errors.New("group by a single period, day, week or month")
+	}
+
+	if len(stats.GroupBy) > MAX_GROUP_BY {
+		return Stats{}, fmt.Errorf("group by up to %d dimensions", MAX_GROUP_BY)
+	}
+
+	if metrics != "" {
+		stats.Metrics = []string{}
+		for _, metric := range strings.Split(metrics, ",") {
+			metric = strings.TrimSpace(metric)
+			if !contains(STATS_METRICS, metric) {
+				return Stats{}, fmt.Errorf("unknown metric %q, use %s", metric, strings.Join(STATS_METRICS, ", "))
+			}
+			if !contains(stats.Metrics, metric) {
+				stats.Metrics = append(stats.Metrics, metric)
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// period is the period dimension of the stats, if any.
+func (s Stats) period() string {
+	for _, dimension := range s.GroupBy {
+		if dimension == STATS_DAY || dimension == STATS_WEEK || dimension == STATS_MONTH {
+			return dimension
+		}
+	}
+	return ""
+}
+
+// customFields are the names of the custom fields grouped by, in order.
+func (s Stats) customFields() []string {
+	names := make([]string, 0)
+	for _, dimension := range s.GroupBy {
+		if alias, ok := STATS_ALIASES[dimension]; ok {
+			dimension = alias
+		}
+		if strings.HasPrefix(dimension, CUSTOM_FILTER_PREFIX) {
+			names = append(names, strings.TrimPrefix(dimension, CUSTOM_FILTER_PREFIX))
+		}
+	}
+	return names
+}
+
+// TaskStats aggregates the tasks matching the filters of the query, regardless
+// of pagination. Periods start at midnight in loc, and weeks on Monday.
+func (r TaskRepository) TaskStats(query ListQuery, stats Stats, loc *time.Location) ([]models.StatsGroup, error) {
+
+	columns := []string{"COUNT(*) AS count"}
+	args := []interface{}{}
+	group := []string{}
+
+	if contains(stats.Metrics, METRIC_DURATION) {
+		columns = append(columns, "COALESCE(SUM(JSON_EXTRACT(custom, ?)), 0) AS duration")
+		args = append(args, "$."+DURATION_FIELD)
+	}
+
+	if contains(stats.GroupBy, STATS_WORKER) {
+		columns = append(columns, "worker_id", "MAX(worker_name) AS worker_name")
+		group = append(group, "worker_id")
+	}
+
+	if period := stats.period(); period != "" {
+		spans, err := r.zoneSpans(query, loc)
+		if err != nil {
+			return nil, err
+		}
+		expr, periodArgs := periodExpr(period, spans)
+		columns = append(columns, expr+" AS period")
+		args = append(args, periodArgs...)
+		group = append(group, "period")
+	}
+
+	for i, name := range stats.customFields() {
+		alias := fmt.Sprintf("g%d", i)
+		columns = append(columns, "JSON_UNQUOTE(JSON_EXTRACT(custom, ?)) AS "+alias)
+		args = append(args, "$."+name)
+		group = append(group, alias)
+	}
+
+	q := r.filterTasks(query).Model(&models.Task{}).Select(strings.Join(columns, ", "), args...)
+	if len(group) > 0 {
+		q = q.Group(strings.Join(group, ", "))
+	}
+
+	var rows []statsRow
+	if err := q.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	return toStatsGroups(rows, stats), nil
+}
+
+// zoneSpans are the offsets of loc over the dates of the tasks of the query.
+func (r TaskRepository) zoneSpans(query ListQuery, loc *time.Location) ([]zoneSpan, error) {
+
+	var from, to sql.NullTime
+	err := r.filterTasks(query).Model(&models.Task{}).Select("MIN(date), MAX(date)").Row().Scan(&from, &to)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitZone(from.Time, to.Time, loc), nil
+}
+
+// zoneSpan is a span of time with a single offset from UTC, up to the start
+// of the next span.
+type zoneSpan struct {
+	Until  time.Time
+	Offset string
+}
+
+// splitZone splits the time from from to to into the spans of the offsets of
+// loc, the database only converts dates by offset.
+func splitZone(from time.Time, to time.Time, loc *time.Location) []zoneSpan {
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	spans := make([]zoneSpan, 0)
+	offset := zoneOffset(from, loc)
+
+	for t := from; t.Before(to) && len(spans) < MAX_ZONE_SPANS; {
+		next := t.Add(24 * time.Hour)
+		if zoneOffset(next, loc) == offset {
+			t = next
+			continue
+		}
+
+		// the change is within the day, offsets change on whole seconds
+		low, high := t.Unix(), next.Unix()
+		for high-low > 1 {
+			mid := low + (high-low)/2
+			if zoneOffset(time.Unix(mid, 0), loc) == offset {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+
+		change := time.Unix(high, 0).UTC()
+		spans = append(spans, zoneSpan{Until: change, Offset: formatOffset(offset)})
+		offset = zoneOffset(change, loc)
+		t = change
+	}
+
+	return append(spans, zoneSpan{Offset: formatOffset(offset)})
+}
+
+func zoneOffset(t time.Time, loc *time.Location) int {
+	_, offset := t.In(loc).Zone()
+	return offset
+}
+
+// formatOffset formats an offset in seconds as +hh:mm.
+func formatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("%s%02d:%02d", sign, offset/3600, offset%3600/60)
+}
+
+// localDate is the expression of the date of the tasks in the time zone of
+// the spans, each span converted with its own offset.
+func localDate(spans []zoneSpan) (string, []interface{}) {
+
+	last := spans[len(spans)-1]
+	if len(spans) == 1 {
+		return "CONVERT_TZ(date, '+00:00', ?)", []interface{}{last.Offset}
+	}
+
+	var b strings.Builder
+	args := make([]interface{}, 0, len(spans)*2)
+
+	b.WriteString("CASE")
+	for _, span := range spans[:len(spans)-1] {
+		b.WriteString(" WHEN date < ? THEN CONVERT_TZ(date, '+00:00', ?)")
+		args = append(args, span.Until, span.Offset)
+	}
+	b.WriteString(" ELSE CONVERT_TZ(date, '+00:00', ?) END")
+	args = append(args, last.Offset)
+
+	return b.String(), args
+}
+
+// periodExpr is the expression of the first day of the period of the tasks,
+// as yyyy-mm-dd, or yyyy-mm for months. Tasks without date have no period.
+func periodExpr(period string, spans []zoneSpan) (string, []interface{}) {
+
+	local, args := localDate(spans)
+
+	switch period {
+	case STATS_WEEK:
+		return "DATE_FORMAT(DATE_SUB(" + local + ", INTERVAL WEEKDAY(" + local + ") DAY), '%Y-%m-%d')", append(args, args...)
+	case STATS_MONTH:
+		return "DATE_FORMAT(" + local + ", '%Y-%m')", args
+	default:
+		return "DATE_FORMAT(" + local + ", '%Y-%m-%d')", args
+	}
+}
+
+// toStatsGroups makes the groups of the rows, sorted by their dimensions.
+func toStatsGroups(rows []statsRow, stats Stats) []models.StatsGroup {
+
+	period := stats.period()
+	groups := make([]models.StatsGroup, 0, len(rows))
+
+	for _, row := range rows {
+
+		values := make(map[string]string, len(stats.GroupBy)+1)
+		custom := []sql.NullString{row.G0, row.G1, row.G2}
+
+		for _, dimension := range stats.GroupBy {
+			switch {
+			case dimension == STATS_WORKER:
+				values["worker_id"] = row.WorkerId
+				values["worker_name"] = row.WorkerName
+			case dimension == period:
+				values[dimension] = row.Period.String
+			default:
+				values[dimension] = custom[0].String
+				custom = custom[1:]
+			}
+		}
+
+		group := models.StatsGroup{Group: values}
+		group.Add(stats.Metrics, row.Count, row.Duration)
+		groups = append(groups, group)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		for _, dimension := range stats.GroupBy {
+			a, b := sortValue(groups[i], dimension), sortValue(groups[j], dimension)
+			if a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+
+	return groups
+}
+
+// sortValue is the value of a dimension of a group to sort by, workers are
+// sorted by name.
+func sortValue(group models.StatsGroup, dimension string) string {
+	if dimension == STATS_WORKER {
+		return group.Group["worker_name"] + "\x00" + group.Group["worker_id"]
+	}
+	return group.Group[dimension]
+}
+
+func aliasNames() []string {
+	names := make([]string, 0, len(STATS_ALIASES))
+	for name := range STATS_ALIASES {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStats(t *testing.T) {
+
+	stats, err := ParseStats("", "")
+	assert.Nil(t, err)
+	assert.Equal(t, Stats{GroupBy: []string{}, Metrics: []string{METRIC_COUNT}}, stats)
+
+	stats, err = ParseStats("worker, week,site", "duration,count")
+	assert.Nil(t, err)
+	assert.Equal(t, Stats{GroupBy: []string{"worker", "week", "site"}, Metrics: []string{"duration", "count"}}, stats)
+	assert.Equal(t, STATS_WEEK, stats.period())
+	assert.Equal(t, []string{"site"}, stats.customFields())
+
+	cases := map[string][2]string{
+		`can't group by "priority", group by worker, day, week, month, site, status, tag or custom.<name>`: {"priority", ""},
+		"group by a single period, day, week or month":                                                     {"day,month", ""},
+		"worker is grouped by more than once":                                                              {"worker,worker", ""},
+		"group by up to 3 dimensions":                                                                      {"worker,day,status,custom.area", ""},
+		`unknown metric "sum", use count, duration`:                                                        {"", "sum"},
+	}
+
+	for message, params := range cases {
+		_, err := ParseStats(params[0], params[1])
+		assert.EqualError(t, err, message)
+	}
+}
+
+func TestSplitZoneFollowsDaylightSavingTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	from := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, time.November, 30, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, []zoneSpan{
+		{Until: time.Date(2024, time.March, 31, 1, 0, 0, 0, time.UTC), Offset: "+01:00"},
+		{Until: time.Date(2024, time.October, 27, 1, 0, 0, 0, time.UTC), Offset: "+02:00"},
+		{Offset: "+01:00"},
+	}, splitZone(from, to, berlin))
+
+	assert.Equal(t, []zoneSpan{{Offset: "+00:00"}}, splitZone(from, to, time.UTC))
+
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	assert.Nil(t, err)
+	assert.Equal(t, []zoneSpan{{Offset: "+05:30"}}, splitZone(from, to, kolkata))
+
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+	assert.Equal(t, "-05:00", splitZone(from, from, newYork)[0].Offset)
+}
+
+func TestPeriodExpr(t *testing.T) {
+
+	single := []zoneSpan{{Offset: "+01:00"}}
+
+	expr, args := periodExpr(STATS_DAY, single)
+	assert.Equal(t, "DATE_FORMAT(CONVERT_TZ(date, '+00:00', ?), '%Y-%m-%d')", expr)
+	assert.Equal(t, []interface{}{"+01:00"}, args)
+
+	expr, args = periodExpr(STATS_WEEK, single)
+	assert.Equal(t, "DATE_FORMAT(DATE_SUB(CONVERT_TZ(date, '+00:00', ?), INTERVAL WEEKDAY(CONVERT_TZ(date, '+00:00', ?)) DAY), '%Y-%m-%d')", expr)
+	assert.Equal(t, []interface{}{"+01:00", "+01:00"}, args)
+
+	change := time.Date(2024, time.March, 31, 1, 0, 0, 0, time.UTC)
+	expr, args = periodExpr(STATS_MONTH, []zoneSpan{{Until: change, Offset: "+01:00"}, {Offset: "+02:00"}})
+	assert.Equal(t, "DATE_FORMAT(CASE WHEN date < ? THEN CONVERT_TZ(date, '+00:00', ?) ELSE CONVERT_TZ(date, '+00:00', ?) END, '%Y-%m')", expr)
+	assert.Equal(t, []interface{}{change, "+01:00", "+02:00"}, args)
+}
+
+func TestToStatsGroupsSortsByDimensions(t *testing.T) {
+
+	period := func(value string) sql.NullString {
+		return sql.NullString{String: value, Valid: true}
+	}
+
+	stats := Stats{GroupBy: []string{"worker", "day"}, Metrics: []string{"count", "duration"}}
+	rows := []statsRow{
+		{WorkerId: "auth0|2", WorkerName: "bob", Period: period("2024-03-05"), Count: 1, Duration: 30},
+		{WorkerId: "auth0|1", WorkerName: "ana", Period: period("2024-03-06"), Count: 1},
+		{WorkerId: "auth0|1", WorkerName: "ana", Period: period("2024-03-05"), Count: 3, Duration: 75},
+		{WorkerId: "auth0|1", WorkerName: "ana", Count: 4},
+	}
+
+	count := func(n int64) *int64 { return &n }
+	duration := func(d float64) *float64 { return &d }
+
+	assert.Equal(t, []models.StatsGroup{
+		{Group: map[string]string{"worker_id": "auth0|1", "worker_name": "ana", "day": ""}, Count: count(4), Duration: duration(0)},
+		{Group: map[string]string{"worker_id": "auth0|1", "worker_name": "ana", "day": "2024-03-05"}, Count: count(3), Duration: duration(75)},
+		{Group: map[string]string{"worker_id": "auth0|1", "worker_name": "ana", "day": "2024-03-06"}, Count: count(1), Duration: duration(0)},
+		{Group: map[string]string{"worker_id": "auth0|2", "worker_name": "bob", "day": "2024-03-05"}, Count: count(1), Duration: duration(30)},
+	}, toStatsGroups(rows, stats))
+
+	months := toStatsGroups([]statsRow{{Period: period("2024-03"), Count: 3}}, Stats{GroupBy: []string{"month"}, Metrics: []string{"count"}})
+	if assert.Len(t, months, 1) {
+		assert.Equal(t, "2024-03", months[0].Group["month"])
+		assert.Nil(t, months[0].Duration)
+	}
+}
+
+func TestTaskStatsGroupsByLocalPeriodInDatabase(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	// Berlin is UTC+1 before March 31 and UTC+2 after
+	for _, date := range []string{"2024-03-04T23:15:00Z", "2024-03-05T08:00:00Z", "2024-04-01T22:30:00Z", "2024-04-02T12:00:00Z"} {
+		request := mockedTaskRequest
+		request.Date = date
+		task, err := request.ToTask("auth0|1", "ana", time.UTC)
+		assert.Nil(t, err)
+		_, err = mockedRepo.CreateTask(task)
+		assert.Nil(t, err)
+	}
+
+	stats, err := ParseStats("day", "count")
+	assert.Nil(t, err)
+
+	groups, err := mockedRepo.TaskStats(NewListQuery(), stats, berlin)
+	assert.Nil(t, err)
+	if assert.Len(t, groups, 2) {
+		assert.Equal(t, "2024-03-05", groups[0].Group["day"])
+		assert.Equal(t, int64(2), *groups[0].Count)
+		assert.Equal(t, "2024-04-02", groups[1].Group["day"])
+		assert.Equal(t, int64(2), *groups[1].Count)
+	}
+}
+
+func TestTaskStatsAggregatesInDatabase(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	for i, site := range []string{"lisbon", "porto", "lisbon"} {
+		request := mockedTaskRequest
+		request.Custom = models.CustomFields{"site": site, "duration": 30 * (i + 1)}
+		task, err := request.ToTask("auth0|1", "ana", time.UTC)
+		assert.Nil(t, err)
+		_, err = mockedRepo.CreateTask(task)
+		assert.Nil(t, err)
+	}
+
+	query := NewListQuery()
+	stats, err := ParseStats("worker,site", "count,duration")
+	assert.Nil(t, err)
+
+	groups, err := mockedRepo.TaskStats(query, stats, time.UTC)
+	assert.Nil(t, err)
+	if assert.Len(t, groups, 2) {
+		assert.Equal(t, map[string]string{"worker_id": "auth0|1", "worker_name": "ana", "site": "lisbon"}, groups[0].Group)
+		assert.Equal(t, int64(2), *groups[0].Count)
+		assert.Equal(t, float64(120), *groups[0].Duration)
+		assert.Equal(t, "porto", groups[1].Group["site"])
+	}
+}
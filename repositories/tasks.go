@@ -1,17 +1,30 @@
 package repositories
 
 import (
+	"errors"
+	"time"
+
 	"github.com/MrBolas/SupervisorAPI/models"
 	"github.com/gofrs/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrVersionConflict is returned when a task changed since it was read.
+var ErrVersionConflict = errors.New("task version conflict")
+
 type Repository interface {
 	GetTaskById(id uuid.UUID) (models.Task, error)
 	CreateTask(t models.Task) (models.Task, error)
-	UpdateTask(id uuid.UUID, oldTask models.Task, newTask models.Task) (models.Task, error)
+	CreateTasks(tasks []models.Task) error
+	UpdateTask(id uuid.UUID, oldTask models.Task, newTask models.Task, changedBy string) (models.Task, error)
 	ListTasks(filters ListQuery) ([]models.Task, error)
-	DeleteTask(id uuid.UUID) error
+	CountTasks(filters ListQuery) (int64, error)
+	TaskStats(filters ListQuery, stats Stats, loc *time.Location) ([]models.StatsGroup, error)
+	DeleteTask(id uuid.UUID, version int) error
+	ListTaskRevisions(taskId uuid.UUID) ([]models.TaskRevision, error)
+	GetTaskRevision(taskId uuid.UUID, revision int) (models.TaskRevision, error)
+	Transaction(fn func(repo Repository) error) error
 }
 
 type TaskRepository struct {
@@ -24,6 +37,14 @@ func NewTasksRepository(db *gorm.DB) *TaskRepository {
 	}
 }
 
+// Transaction runs fn with a repository bound to a database transaction. The
+// transaction is committed if fn returns nil and rolled back otherwise.
+func (r TaskRepository) Transaction(fn func(repo Repository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(NewTasksRepository(tx))
+	})
+}
+
 func (r TaskRepository) GetTaskById(id uuid.UUID) (models.Task, error) {
 	var task models.Task
 
@@ -36,19 +57,93 @@ func (r TaskRepository) GetTaskById(id uuid.UUID) (models.Task, error) {
 
 func (r TaskRepository) CreateTask(t models.Task) (models.Task, error) {
 
-	if err := r.db.Create(&t).Error; err != nil {
+	if t.Version == 0 {
+		t.Version = 1
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&t).Error; err != nil {
+			return err
+		}
+		return saveSearchTokens(tx, t)
+	})
+	if err != nil {
 		return models.Task{}, err
 	}
 
 	return t, nil
 }
 
+// CreateTasks inserts many new tasks and their search tokens in a single
+// transaction, either all of them are created or none.
+func (r TaskRepository) CreateTasks(tasks []models.Task) error {
+
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	tokens := make([]models.TaskSearchToken, 0)
+	for i := range tasks {
+		if tasks[i].Version == 0 {
+			tasks[i].Version = 1
+		}
+		tokens = append(tokens, models.ToSearchTokens(tasks[i].Id, tasks[i].SearchTokens)...)
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&tasks).Error; err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(tokens, 1000).Error
+	})
+}
+
 func (r TaskRepository) ListTasks(query ListQuery) ([]models.Task, error) {
 
 	offset, limit := query.GetOffsetLimit()
 
 	var tasks []models.Task
 
+	q := r.filterTasks(query)
+
+	sort := query.Sort
+	if cursor := query.Pagination.Cursor; cursor != nil {
+		var condition clause.Expr
+		condition, sort = cursor.keyset(sort)
+		q.Where(condition)
+	}
+
+	// the id breaks ties so the order is stable
+	for _, column := range sort.orderBy() {
+		q.Order(column)
+	}
+
+	if err := q.Offset(offset).Limit(limit).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// CountTasks counts all the tasks matching the filters of the query,
+// regardless of pagination.
+func (r TaskRepository) CountTasks(query ListQuery) (int64, error) {
+
+	var count int64
+
+	if err := r.filterTasks(query).Model(&models.Task{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// filterTasks applies the filters of the query.
+func (r TaskRepository) filterTasks(query ListQuery) *gorm.DB {
+
 	q := r.db.Where(query.Filters)
 
 	if query.IntervalFilters["before"] != nil {
@@ -59,36 +154,142 @@ func (r TaskRepository) ListTasks(query ListQuery) ([]models.Task, error) {
 		q.Where("date > ?", query.IntervalFilters["after"])
 	}
 
-	q.Order(query.Sort.By + " " + query.Sort.Order)
+	// days and ranges include their start, midnight
+	if query.IntervalFilters[INTERVAL_FROM] != nil {
+		q.Where("date >= ?", query.IntervalFilters[INTERVAL_FROM])
+	}
+
+	// every word of the search must be in the summary
+	for _, token := range query.SearchTokens {
+		q.Where("id IN (?)", r.db.Model(&models.TaskSearchToken{}).Select("task_id").Where("token = ?", token))
+	}
 
-	if err := q.Offset(offset).Limit(limit).Find(&tasks).Error; err != nil {
-		return nil, err
+	for name, value := range query.CustomFilters {
+		q.Where("JSON_UNQUOTE(JSON_EXTRACT(custom, ?)) = ?", "$."+name, value)
 	}
 
-	return tasks, nil
+	if query.Filter != nil {
+		q.Where(filterClause(query.Filter))
+	}
+
+	return q
 }
 
-func (r TaskRepository) UpdateTask(id uuid.UUID, oldTask models.Task, newTask models.Task) (models.Task, error) {
+// UpdateTask stores the previous values of the task as a new revision and
+// overwrites the task, both in the same transaction. The task is only
+// overwritten if it is still at the version of oldTask, otherwise
+// ErrVersionConflict is returned.
+func (r TaskRepository) UpdateTask(id uuid.UUID, oldTask models.Task, newTask models.Task, changedBy string) (models.Task, error) {
 
 	newTask.Id = id
+	newTask.Version = oldTask.Version + 1
+	oldTask.Id = id
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		update := tx.Model(&models.Task{}).
+			Where("id = ? AND version = ?", id, oldTask.Version).
+			Select("*").
+			Updates(&newTask)
+		if update.Error != nil {
+			return update.Error
+		}
 
-	if err := r.db.Save(&newTask).Error; err != nil {
+		if update.RowsAffected <= 0 {
+			return ErrVersionConflict
+		}
+
+		var lastRevision int
+		err := tx.Model(&models.TaskRevision{}).
+			Select("COALESCE(MAX(revision), 0)").
+			Where("task_id = ?", id).
+			Scan(&lastRevision).Error
+		if err != nil {
+			return err
+		}
+
+		revision, err := models.NewTaskRevision(oldTask, lastRevision+1, changedBy)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+
+		return saveSearchTokens(tx, newTask)
+	})
+	if err != nil {
 		return models.Task{}, err
 	}
 
 	return newTask, nil
 }
 
-func (r TaskRepository) DeleteTask(id uuid.UUID) error {
+// DeleteTask deletes the task. When version is bigger than 0 the task is only
+// deleted if it is still at that version, otherwise ErrVersionConflict is returned.
+func (r TaskRepository) DeleteTask(id uuid.UUID, version int) error {
+
+	var rowsAffected int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		q := tx.Where("id = ?", id)
+		if version > 0 {
+			q = q.Where("version = ?", version)
+		}
+
+		deleted := q.Delete(&models.Task{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+
+		rowsAffected = deleted.RowsAffected
+		if rowsAffected == 0 {
+			return nil
+		}
 
-	tx := r.db.Delete(&models.Task{}, id)
-	if tx.Error != nil {
-		return tx.Error
+		return tx.Where("task_id = ?", id).Delete(&models.TaskSearchToken{}).Error
+	})
+	if err != nil {
+		return err
 	}
 
-	if tx.RowsAffected <= 0 {
-		return gorm.ErrRecordNotFound
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	if version > 0 {
+		var count int64
+		if err := r.db.Model(&models.Task{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return ErrVersionConflict
+		}
+	}
+
+	return gorm.ErrRecordNotFound
+}
+
+func (r TaskRepository) ListTaskRevisions(taskId uuid.UUID) ([]models.TaskRevision, error) {
+
+	var revisions []models.TaskRevision
+
+	if err := r.db.Where("task_id = ?", taskId).Order("revision asc").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (r TaskRepository) GetTaskRevision(taskId uuid.UUID, revision int) (models.TaskRevision, error) {
+
+	var taskRevision models.TaskRevision
+
+	if err := r.db.Where("task_id = ? AND revision = ?", taskId, revision).First(&taskRevision).Error; err != nil {
+		return models.TaskRevision{}, err
 	}
 
-	return nil
+	return taskRevision, nil
 }
@@ -2,8 +2,10 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"testing"
 	"time"
@@ -19,8 +21,9 @@ import (
 var db *gorm.DB
 
 var mockedTask = models.Task{
-	WorkerId: "mocked_worker_name",
-	Summary:  "mocked_summary",
+	WorkerId:   "mocked_worker_id",
+	WorkerName: "mocked_worker_name",
+	Summary:    "mocked_summary",
 	Date: sql.NullTime{
 		Time:  time.Date(2020, time.April, 21, 10, 10, 10, 0, time.UTC),
 		Valid: true,
@@ -69,7 +72,7 @@ func TestMain(m *testing.M) {
 			return err
 		}
 
-		db.AutoMigrate(models.Task{})
+		db.AutoMigrate(models.Task{}, models.TaskRevision{}, models.User{}, models.CustomFieldSchema{}, models.TeamLimits{}, models.TaskSearchToken{}, models.View{})
 
 		return nil
 	}); err != nil {
@@ -91,6 +94,18 @@ func teardown(t *testing.T) {
 	sql, _ := db.DB()
 	_, err := sql.Exec("DELETE FROM tasks")
 	assert.Nil(t, err)
+	_, err = sql.Exec("DELETE FROM task_revisions")
+	assert.Nil(t, err)
+	_, err = sql.Exec("DELETE FROM users")
+	assert.Nil(t, err)
+	_, err = sql.Exec("DELETE FROM custom_field_schemas")
+	assert.Nil(t, err)
+	_, err = sql.Exec("DELETE FROM team_limits")
+	assert.Nil(t, err)
+	_, err = sql.Exec("DELETE FROM task_search_tokens")
+	assert.Nil(t, err)
+	_, err = sql.Exec("DELETE FROM views")
+	assert.Nil(t, err)
 }
 
 func TestCreateNewTask(t *testing.T) {
@@ -107,6 +122,41 @@ func TestCreateNewTask(t *testing.T) {
 	assert.Equal(t, createdTask.Date.Valid, mockedTask.Date.Valid)
 }
 
+func TestCreateTasksInsertsBatchWithSearchTokens(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	tasks := make([]models.Task, 0)
+	for i := 0; i < 3; i++ {
+		task, err := mockedTaskRequest.ToTask("auth0|1", "joseph", time.UTC)
+		assert.Nil(t, err)
+		task.SearchTokens = []string{"token"}
+		tasks = append(tasks, task)
+	}
+
+	err := mockedRepo.CreateTasks(tasks)
+	assert.Nil(t, err)
+
+	count, err := mockedRepo.CountTasks(NewListQuery())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+
+	var tokens int64
+	db.Table("task_search_tokens").Where("token = ?", "token").Count(&tokens)
+	assert.Equal(t, int64(3), tokens)
+
+	// a task that already exists fails the whole batch
+	task, err := mockedTaskRequest.ToTask("auth0|1", "joseph", time.UTC)
+	assert.Nil(t, err)
+	err = mockedRepo.CreateTasks([]models.Task{task, tasks[0]})
+	assert.NotNil(t, err)
+
+	count, err = mockedRepo.CountTasks(NewListQuery())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
 func TestGetTaskById(t *testing.T) {
 
 	mockedRepo := NewTasksRepository(db)
@@ -131,7 +181,7 @@ func TestGetTaskList(t *testing.T) {
 	defer teardown(t)
 
 	for i := 0; i < 10; i++ {
-		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name")
+		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name", "mocked_worker_name", time.UTC)
 		assert.Nil(t, err)
 
 		_, err = mockedRepo.CreateTask(newMockedTaskRequest)
@@ -154,7 +204,7 @@ func TestGetTaskListByWorkerName(t *testing.T) {
 	defer teardown(t)
 
 	for i := 0; i < 5; i++ {
-		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_1")
+		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_1", "mocked_worker_name_1", time.UTC)
 		assert.Nil(t, err)
 
 		_, err = mockedRepo.CreateTask(newMockedTaskRequest)
@@ -162,7 +212,7 @@ func TestGetTaskListByWorkerName(t *testing.T) {
 	}
 
 	for i := 0; i < 5; i++ {
-		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_2")
+		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_2", "mocked_worker_name_2", time.UTC)
 		assert.Nil(t, err)
 
 		_, err = mockedRepo.CreateTask(newMockedTaskRequest)
@@ -180,13 +230,42 @@ func TestGetTaskListByWorkerName(t *testing.T) {
 	assert.Equal(t, len(tasks), 5)
 }
 
+func TestCountTasksIgnoresPagination(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	for i := 0; i < 5; i++ {
+		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_1", "mocked_worker_name_1", time.UTC)
+		assert.Nil(t, err)
+
+		_, err = mockedRepo.CreateTask(newMockedTaskRequest)
+		assert.Nil(t, err)
+	}
+
+	newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_2", "mocked_worker_name_2", time.UTC)
+	assert.Nil(t, err)
+	_, err = mockedRepo.CreateTask(newMockedTaskRequest)
+	assert.Nil(t, err)
+
+	query := NewListQuery()
+	query.AddPageAndPageSize("2", "2")
+	query.AddSorting("", "")
+	query.Filters["worker_name"] = "mocked_worker_name_1"
+
+	count, err := mockedRepo.CountTasks(query)
+	assert.Nil(t, err)
+
+	assert.Equal(t, count, int64(5))
+}
+
 func TestGetTaskListBeforeTimestamp(t *testing.T) {
 
 	mockedRepo := NewTasksRepository(db)
 	defer teardown(t)
 
 	for i := 0; i < 5; i++ {
-		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_1")
+		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_1", "mocked_worker_name_1", time.UTC)
 		assert.Nil(t, err)
 
 		newMockedTaskRequest.Date.Time = time.Date(2020, time.April, 15, 10, 50, 0, 0, time.UTC)
@@ -195,7 +274,7 @@ func TestGetTaskListBeforeTimestamp(t *testing.T) {
 	}
 
 	for i := 0; i < 5; i++ {
-		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_2")
+		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_2", "mocked_worker_name_2", time.UTC)
 		assert.Nil(t, err)
 
 		_, err = mockedRepo.CreateTask(newMockedTaskRequest)
@@ -208,7 +287,7 @@ func TestGetTaskListBeforeTimestamp(t *testing.T) {
 
 	urlValues := make(map[string][]string, 0)
 	urlValues["before"] = []string{"2015-01-02 04:04:05PM"}
-	query.AddListTaskFilters(urlValues, false)
+	query.AddListTaskFilters(urlValues, false, time.UTC)
 
 	tasks, err := mockedRepo.ListTasks(query)
 	assert.Nil(t, err)
@@ -223,7 +302,7 @@ func TestGetTaskListAfterTimestamp(t *testing.T) {
 	defer teardown(t)
 
 	for i := 0; i < 5; i++ {
-		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_1")
+		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_1", "mocked_worker_name_1", time.UTC)
 		assert.Nil(t, err)
 
 		newMockedTaskRequest.Date.Time = time.Date(2020, time.April, 15, 10, 50, 0, 0, time.UTC)
@@ -232,7 +311,7 @@ func TestGetTaskListAfterTimestamp(t *testing.T) {
 	}
 
 	for i := 0; i < 5; i++ {
-		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_2")
+		newMockedTaskRequest, err := mockedTaskRequest.ToTask("mocked_worker_name_2", "mocked_worker_name_2", time.UTC)
 		assert.Nil(t, err)
 
 		_, err = mockedRepo.CreateTask(newMockedTaskRequest)
@@ -247,7 +326,7 @@ func TestGetTaskListAfterTimestamp(t *testing.T) {
 
 	urlValues := make(map[string][]string, 0)
 	urlValues["after"] = []string{"2015-01-02 04:04:05PM"}
-	err = query.AddListTaskFilters(urlValues, false)
+	err = query.AddListTaskFilters(urlValues, false, time.UTC)
 	assert.Nil(t, err)
 
 	tasks, err := mockedRepo.ListTasks(query)
@@ -257,12 +336,38 @@ func TestGetTaskListAfterTimestamp(t *testing.T) {
 	assert.Equal(t, tasks[0].WorkerId, "mocked_worker_name_1")
 }
 
+func TestAfterExcludesItsBoundAndOnIncludesMidnight(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	newTask, err := mockedTaskRequest.ToTask("mocked_worker_name", "mocked_worker_name", time.UTC)
+	assert.Nil(t, err)
+	newTask.Date.Time = time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)
+	_, err = mockedRepo.CreateTask(newTask)
+	assert.Nil(t, err)
+
+	after := NewListQuery()
+	err = after.AddListTaskFilters(url.Values{"after": []string{"2024-03-05T00:00:00Z"}}, true, time.UTC)
+	assert.Nil(t, err)
+	tasks, err := mockedRepo.ListTasks(after)
+	assert.Nil(t, err)
+	assert.Len(t, tasks, 0)
+
+	on := NewListQuery()
+	err = on.AddListTaskFilters(url.Values{"on": []string{"2024-03-05"}}, true, time.UTC)
+	assert.Nil(t, err)
+	tasks, err = mockedRepo.ListTasks(on)
+	assert.Nil(t, err)
+	assert.Len(t, tasks, 1)
+}
+
 func TestUpdateTask(t *testing.T) {
 
 	mockedRepo := NewTasksRepository(db)
 	defer teardown(t)
 
-	newTask, err := mockedTaskRequest.ToTask("mocked_worker_name")
+	newTask, err := mockedTaskRequest.ToTask("mocked_worker_name", "mocked_worker_name", time.UTC)
 	assert.Nil(t, err)
 
 	createdTask, err := mockedRepo.CreateTask(newTask)
@@ -271,7 +376,7 @@ func TestUpdateTask(t *testing.T) {
 	modifiedTask := createdTask
 	modifiedTask.Summary = "updated mocked summary text"
 
-	updatedTask, err := mockedRepo.UpdateTask(createdTask.Id, createdTask, modifiedTask)
+	updatedTask, err := mockedRepo.UpdateTask(createdTask.Id, createdTask, modifiedTask, "mocked_manager")
 	assert.Nil(t, err)
 
 	assert.Equal(t, modifiedTask.Id, updatedTask.Id)
@@ -280,3 +385,132 @@ func TestUpdateTask(t *testing.T) {
 	assert.Equal(t, modifiedTask.Date.Time, updatedTask.Date.Time)
 	assert.Equal(t, modifiedTask.Date.Valid, updatedTask.Date.Valid)
 }
+
+func TestUpdateTaskStoresRevision(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	newTask, err := mockedTaskRequest.ToTask("mocked_worker_name", "mocked_worker_name", time.UTC)
+	assert.Nil(t, err)
+
+	createdTask, err := mockedRepo.CreateTask(newTask)
+	assert.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		modifiedTask := createdTask
+		modifiedTask.Summary = fmt.Sprintf("updated mocked summary %d", i)
+
+		createdTask, err = mockedRepo.UpdateTask(createdTask.Id, createdTask, modifiedTask, "mocked_manager")
+		assert.Nil(t, err)
+	}
+
+	revisions, err := mockedRepo.ListTaskRevisions(createdTask.Id)
+	assert.Nil(t, err)
+
+	assert.Equal(t, len(revisions), 2)
+	assert.Equal(t, revisions[0].Revision, 1)
+	assert.Equal(t, revisions[0].Summary, mockedTaskRequest.Summary)
+	assert.Equal(t, revisions[0].ChangedBy, "mocked_manager")
+	assert.Equal(t, revisions[1].Revision, 2)
+	assert.Equal(t, revisions[1].Summary, "updated mocked summary 0")
+
+	revision, err := mockedRepo.GetTaskRevision(createdTask.Id, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, revision.Summary, "updated mocked summary 0")
+
+	_, err = mockedRepo.GetTaskRevision(createdTask.Id, 3)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestUpdateTaskVersionConflict(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	newTask, err := mockedTaskRequest.ToTask("mocked_worker_name", "mocked_worker_name", time.UTC)
+	assert.Nil(t, err)
+
+	createdTask, err := mockedRepo.CreateTask(newTask)
+	assert.Nil(t, err)
+
+	modifiedTask := createdTask
+	modifiedTask.Summary = "first update"
+
+	updatedTask, err := mockedRepo.UpdateTask(createdTask.Id, createdTask, modifiedTask, "mocked_worker_name")
+	assert.Nil(t, err)
+	assert.Equal(t, updatedTask.Version, createdTask.Version+1)
+
+	// a second update based on the stale version must not overwrite the first
+	modifiedTask.Summary = "concurrent update"
+	_, err = mockedRepo.UpdateTask(createdTask.Id, createdTask, modifiedTask, "mocked_worker_name")
+	assert.Equal(t, ErrVersionConflict, err)
+
+	fetchedTask, err := mockedRepo.GetTaskById(createdTask.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, fetchedTask.Summary, "first update")
+
+	revisions, err := mockedRepo.ListTaskRevisions(createdTask.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, len(revisions), 1)
+}
+
+func TestDeleteTaskVersionConflict(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	newTask, err := mockedTaskRequest.ToTask("mocked_worker_name", "mocked_worker_name", time.UTC)
+	assert.Nil(t, err)
+
+	createdTask, err := mockedRepo.CreateTask(newTask)
+	assert.Nil(t, err)
+
+	err = mockedRepo.DeleteTask(createdTask.Id, createdTask.Version+1)
+	assert.Equal(t, ErrVersionConflict, err)
+
+	err = mockedRepo.DeleteTask(createdTask.Id, createdTask.Version)
+	assert.Nil(t, err)
+
+	err = mockedRepo.DeleteTask(createdTask.Id, createdTask.Version)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestTransactionRollback(t *testing.T) {
+
+	mockedRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	err := mockedRepo.Transaction(func(tx Repository) error {
+		for i := 0; i < 3; i++ {
+			newTask, err := mockedTaskRequest.ToTask("mocked_worker_name", "mocked_worker_name", time.UTC)
+			assert.Nil(t, err)
+
+			_, err = tx.CreateTask(newTask)
+			assert.Nil(t, err)
+		}
+		return errors.New("rollback")
+	})
+	assert.Equal(t, errors.New("rollback"), err)
+
+	query := NewListQuery()
+	query.AddPageAndPageSize("", "")
+	query.AddSorting("", "")
+
+	tasks, err := mockedRepo.ListTasks(query)
+	assert.Nil(t, err)
+	assert.Equal(t, len(tasks), 0)
+
+	err = mockedRepo.Transaction(func(tx Repository) error {
+		newTask, err := mockedTaskRequest.ToTask("mocked_worker_name", "mocked_worker_name", time.UTC)
+		assert.Nil(t, err)
+
+		_, err = tx.CreateTask(newTask)
+		return err
+	})
+	assert.Nil(t, err)
+
+	tasks, err = mockedRepo.ListTasks(query)
+	assert.Nil(t, err)
+	assert.Equal(t, len(tasks), 1)
+}
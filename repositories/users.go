@@ -0,0 +1,282 @@
+package repositories
+
+import (
+	"strings"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UsersRepository interface {
+	GetUserById(id string) (models.User, error)
+	GetUsersByIds(ids []string) ([]models.User, error)
+	SyncUser(u models.User) (models.User, error)
+	ListUsers(query ListQuery) ([]models.User, error)
+	CountUsers(query ListQuery) (int64, error)
+	UpdateUserPreferences(u models.User) (models.User, error)
+	GetUserByCalendarToken(hash string) (models.User, error)
+	SetCalendarToken(id string, hash string) error
+	BackfillTaskWorkers() (int64, error)
+	ListTaskClaims() ([]TaskClaim, error)
+	ClaimTasks(nickname string, userId string) (int64, error)
+}
+
+// CLAIM_UNMATCHED is a nickname no user has.
+const CLAIM_UNMATCHED = "unmatched"
+
+// CLAIM_AMBIGUOUS is a nickname several users have.
+const CLAIM_AMBIGUOUS = "ambiguous"
+
+// CLAIM_PENDING is a nickname a single user took since the last backfill,
+// the next one links its tasks.
+const CLAIM_PENDING = "pending"
+
+// TaskClaim is the legacy tasks of a nickname, stored before users existed,
+// and a user with that nickname. UserId is empty when no user has it, and
+// Reason tells why the backfill left them.
+type TaskClaim struct {
+	Nickname string `json:"nickname"`
+	UserId   string `json:"user_id"`
+	Tasks    int64  `json:"tasks"`
+	Reason   string `json:"reason"`
+}
+
+type UserRepository struct {
+	db *gorm.DB
+}
+
+func NewUsersRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{
+		db: db,
+	}
+}
+
+func (r UserRepository) GetUserById(id string) (models.User, error) {
+	var user models.User
+
+	if err := r.db.Where("id = ?", id).First(&user).Error; err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// GetUsersByIds returns the users of the ids that exist, in no particular
+// order.
+func (r UserRepository) GetUsersByIds(ids []string) ([]models.User, error) {
+
+	users := make([]models.User, 0)
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	if err := r.db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// SyncUser creates or refreshes the user from the values in its token. When
+// the nickname changes the display name of its tasks follows. Tasks created
+// before users existed aren't claimed here, nicknames can be taken over, see
+// ClaimTasks.
+func (r UserRepository) SyncUser(u models.User) (models.User, error) {
+
+	existing, err := r.GetUserById(u.Id)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return models.User{}, err
+	}
+
+	if err == nil && !existing.ClaimsChanged(u) {
+		return existing, nil
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+
+		if existing.Id == "" {
+			// the first requests of a user can run at once, they all create it
+			if err := tx.Clauses(clause.OnConflict{
+				DoUpdates: clause.AssignmentColumns([]string{"nickname", "role", "team", "time_zone", "updated_at"}),
+			}).Create(&u).Error; err != nil {
+				return err
+			}
+		} else {
+			u.CreatedAt = existing.CreatedAt
+			if err := tx.Model(&existing).Updates(map[string]interface{}{
+				"nickname":  u.Nickname,
+				"role":      u.Role,
+				"team":      u.Team,
+				"time_zone": u.TimeZone,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.Task{}).
+			Where("worker_id = ?", u.Id).
+			Update("worker_name", u.Nickname).Error
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return r.GetUserById(u.Id)
+}
+
+// ListUsers lists users matching the filters, the search matches the start
+// of the nickname.
+func (r UserRepository) ListUsers(query ListQuery) ([]models.User, error) {
+
+	offset, limit := query.GetOffsetLimit()
+
+	var users []models.User
+
+	if err := r.filterUsers(query).Order("nickname asc").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// CountUsers counts all the users matching the filters of the query,
+// regardless of pagination.
+func (r UserRepository) CountUsers(query ListQuery) (int64, error) {
+
+	var count int64
+
+	if err := r.filterUsers(query).Model(&models.User{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// filterUsers applies the filters of the query.
+func (r UserRepository) filterUsers(query ListQuery) *gorm.DB {
+
+	q := r.db.Where(query.Filters)
+
+	if query.Search != "" {
+		q = q.Where("nickname LIKE ?", escapeLike(query.Search)+"%")
+	}
+
+	return q
+}
+
+// UpdateUserPreferences stores the local preferences of the user, leaving
+// the values synced from claims untouched.
+func (r UserRepository) UpdateUserPreferences(u models.User) (models.User, error) {
+
+	err := r.db.Model(&models.User{}).Where("id = ?", u.Id).Updates(map[string]interface{}{
+		"notifications_enabled": u.NotificationsEnabled,
+		"default_time_zone":     u.DefaultTimeZone,
+		"calendar_summaries":    u.CalendarSummaries,
+	}).Error
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return r.GetUserById(u.Id)
+}
+
+// BackfillTaskWorkers links the tasks without worker id to the user of
+// their nickname, when a single user has it, returning how many were linked.
+// The other nicknames are left for an admin, see ListTaskClaims.
+func (r UserRepository) BackfillTaskWorkers() (int64, error) {
+
+	unique := r.db.Model(&models.User{}).
+		Select("nickname, MIN(id) AS id").
+		Group("nickname").
+		Having("COUNT(*) = 1")
+
+	result := r.db.Exec("UPDATE tasks JOIN (?) AS owners ON owners.nickname = tasks.worker_name "+
+		"SET tasks.worker_id = owners.id "+
+		"WHERE tasks.worker_id = '' OR tasks.worker_id IS NULL", unique)
+
+	return result.RowsAffected, result.Error
+}
+
+// ListTaskClaims lists the nicknames of the tasks the backfill left without
+// worker id, with every user having that nickname. A nickname can belong to
+// several users, or to someone who took it after the worker left, so an
+// admin reviews the claims before ClaimTasks.
+func (r UserRepository) ListTaskClaims() ([]TaskClaim, error) {
+
+	claims := make([]TaskClaim, 0)
+
+	err := r.db.Table("tasks").
+		Select("tasks.worker_name AS nickname, COALESCE(users.id, '') AS user_id, COUNT(*) AS tasks").
+		Joins("LEFT JOIN users ON users.nickname = tasks.worker_name").
+		Where("tasks.worker_id = '' OR tasks.worker_id IS NULL").
+		Group("tasks.worker_name, users.id").
+		Order("tasks.worker_name, users.id").
+		Scan(&claims).Error
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[string]int)
+	for _, claim := range claims {
+		users[claim.Nickname]++
+	}
+
+	for i, claim := range claims {
+		switch {
+		case claim.UserId == "":
+			claims[i].Reason = CLAIM_UNMATCHED
+		case users[claim.Nickname] > 1:
+			claims[i].Reason = CLAIM_AMBIGUOUS
+		default:
+			claims[i].Reason = CLAIM_PENDING
+		}
+	}
+
+	return claims, nil
+}
+
+// ClaimTasks links the tasks without worker id of a nickname to a user,
+// returning how many were claimed.
+func (r UserRepository) ClaimTasks(nickname string, userId string) (int64, error) {
+
+	user, err := r.GetUserById(userId)
+	if err != nil {
+		return 0, err
+	}
+
+	result := r.db.Model(&models.Task{}).
+		Where("(worker_id = '' OR worker_id IS NULL) AND worker_name = ?", nickname).
+		Updates(map[string]interface{}{
+			"worker_id":   user.Id,
+			"worker_name": user.Nickname,
+		})
+
+	return result.RowsAffected, result.Error
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
+
+// GetUserByCalendarToken returns the user of the hash of a calendar token.
+func (r UserRepository) GetUserByCalendarToken(hash string) (models.User, error) {
+	var user models.User
+
+	if hash == "" {
+		return models.User{}, gorm.ErrRecordNotFound
+	}
+
+	if err := r.db.Where("calendar_token_hash = ?", hash).First(&user).Error; err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// SetCalendarToken replaces the hash of the calendar token of the user, an
+// empty hash removes the feed.
+func (r UserRepository) SetCalendarToken(id string, hash string) error {
+
+	return r.db.Model(&models.User{}).Where("id = ?", id).Update("calendar_token_hash", hash).Error
+}
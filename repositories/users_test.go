@@ -0,0 +1,286 @@
+package repositories
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSyncUserCreatesUser(t *testing.T) {
+
+	mockedRepo := NewUsersRepository(db)
+	defer teardown(t)
+
+	user, err := mockedRepo.SyncUser(models.User{Id: "auth0|1", Nickname: "joseph", Role: "technician"})
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, "auth0|1")
+	assert.Equal(t, user.Nickname, "joseph")
+	assert.Equal(t, user.Role, "technician")
+
+	fetchedUser, err := mockedRepo.GetUserById("auth0|1")
+	assert.Nil(t, err)
+	assert.Equal(t, fetchedUser.Nickname, "joseph")
+}
+
+func TestGetUsersByIds(t *testing.T) {
+
+	mockedRepo := NewUsersRepository(db)
+	defer teardown(t)
+
+	_, err := mockedRepo.SyncUser(models.User{Id: "auth0|1", Nickname: "joseph", Role: "technician"})
+	assert.Nil(t, err)
+	_, err = mockedRepo.SyncUser(models.User{Id: "auth0|2", Nickname: "robert", Role: "technician"})
+	assert.Nil(t, err)
+
+	users, err := mockedRepo.GetUsersByIds([]string{"auth0|2", "auth0|3"})
+	assert.Nil(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, users[0].Nickname, "robert")
+	}
+
+	users, err = mockedRepo.GetUsersByIds(nil)
+	assert.Nil(t, err)
+	assert.Len(t, users, 0)
+}
+
+func TestSyncUserRenamesTasks(t *testing.T) {
+
+	mockedRepo := NewUsersRepository(db)
+	tasksRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	_, err := mockedRepo.SyncUser(models.User{Id: "auth0|1", Nickname: "joseph", Role: "technician"})
+	assert.Nil(t, err)
+
+	newTask, err := mockedTaskRequest.ToTask("auth0|1", "joseph", time.UTC)
+	assert.Nil(t, err)
+	createdTask, err := tasksRepo.CreateTask(newTask)
+	assert.Nil(t, err)
+
+	_, err = mockedRepo.SyncUser(models.User{Id: "auth0|1", Nickname: "joe", Role: "technician"})
+	assert.Nil(t, err)
+
+	fetchedTask, err := tasksRepo.GetTaskById(createdTask.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, fetchedTask.WorkerId, "auth0|1")
+	assert.Equal(t, fetchedTask.WorkerName, "joe")
+}
+
+func TestSyncUserConcurrentFirstRequests(t *testing.T) {
+
+	mockedRepo := NewUsersRepository(db)
+	defer teardown(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := mockedRepo.SyncUser(models.User{Id: "auth0|1", Nickname: "joseph", Role: "technician"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+
+	users, err := mockedRepo.GetUsersByIds([]string{"auth0|1"})
+	assert.Nil(t, err)
+	assert.Len(t, users, 1)
+}
+
+func TestSyncUserDoesNotClaimLegacyTasks(t *testing.T) {
+
+	mockedRepo := NewUsersRepository(db)
+	tasksRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	// tasks stored before users existed only have the nickname
+	legacyTask, err := mockedTaskRequest.ToTask("", "cassandra", time.UTC)
+	assert.Nil(t, err)
+	createdTask, err := tasksRepo.CreateTask(legacyTask)
+	assert.Nil(t, err)
+
+	_, err = mockedRepo.SyncUser(models.User{Id: "auth0|2", Nickname: "cassandra", Role: "technician"})
+	assert.Nil(t, err)
+
+	fetchedTask, err := tasksRepo.GetTaskById(createdTask.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, fetchedTask.WorkerId, "")
+}
+
+func TestClaimTasks(t *testing.T) {
+
+	mockedRepo := NewUsersRepository(db)
+	tasksRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	_, err := mockedRepo.SyncUser(models.User{Id: "auth0|3", Nickname: "robert", Role: "manager"})
+	assert.Nil(t, err)
+
+	legacyTask, err := mockedTaskRequest.ToTask("", "robert", time.UTC)
+	assert.Nil(t, err)
+	createdTask, err := tasksRepo.CreateTask(legacyTask)
+	assert.Nil(t, err)
+
+	orphanTask, err := mockedTaskRequest.ToTask("", "gone", time.UTC)
+	assert.Nil(t, err)
+	_, err = tasksRepo.CreateTask(orphanTask)
+	assert.Nil(t, err)
+
+	claims, err := mockedRepo.ListTaskClaims()
+	assert.Nil(t, err)
+	assert.Equal(t, []TaskClaim{
+		{Nickname: "gone", UserId: "", Tasks: 1, Reason: CLAIM_UNMATCHED},
+		{Nickname: "robert", UserId: "auth0|3", Tasks: 1, Reason: CLAIM_PENDING},
+	}, claims)
+
+	claimed, err := mockedRepo.ClaimTasks("robert", "auth0|3")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), claimed)
+
+	fetchedTask, err := tasksRepo.GetTaskById(createdTask.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, fetchedTask.WorkerId, "auth0|3")
+
+	_, err = mockedRepo.ClaimTasks("gone", "auth0|404")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestBackfillTaskWorkersLinksUniqueNicknames(t *testing.T) {
+
+	mockedRepo := NewUsersRepository(db)
+	tasksRepo := NewTasksRepository(db)
+	defer teardown(t)
+
+	for _, user := range []models.User{
+		{Id: "auth0|1", Nickname: "ana", Role: "technician"},
+		{Id: "auth0|2", Nickname: "bob", Role: "technician"},
+		{Id: "auth0|3", Nickname: "bob", Role: "technician"},
+	} {
+		_, err := mockedRepo.SyncUser(user)
+		assert.Nil(t, err)
+	}
+
+	ids := make(map[string]string)
+	for _, nickname := range []string{"ana", "ana", "bob", "carl"} {
+		legacyTask, err := mockedTaskRequest.ToTask("", nickname, time.UTC)
+		assert.Nil(t, err)
+		createdTask, err := tasksRepo.CreateTask(legacyTask)
+		assert.Nil(t, err)
+		ids[createdTask.Id.String()] = nickname
+	}
+
+	linked, err := mockedRepo.BackfillTaskWorkers()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), linked)
+
+	tasks, err := tasksRepo.ListTasks(NewListQuery())
+	assert.Nil(t, err)
+	for _, task := range tasks {
+		if ids[task.Id.String()] == "ana" {
+			assert.Equal(t, "auth0|1", task.WorkerId)
+		} else {
+			assert.Equal(t, "", task.WorkerId)
+		}
+	}
+
+	// the other nicknames are left for review
+	claims, err := mockedRepo.ListTaskClaims()
+	assert.Nil(t, err)
+	assert.Equal(t, []TaskClaim{
+		{Nickname: "bob", UserId: "auth0|2", Tasks: 1, Reason: CLAIM_AMBIGUOUS},
+		{Nickname: "bob", UserId: "auth0|3", Tasks: 1, Reason: CLAIM_AMBIGUOUS},
+		{Nickname: "carl", UserId: "", Tasks: 1, Reason: CLAIM_UNMATCHED},
+	}, claims)
+
+	// a second backfill has nothing left to link
+	linked, err = mockedRepo.BackfillTaskWorkers()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), linked)
+}
+
+func TestListUsers(t *testing.T) {
+
+	mockedRepo := NewUsersRepository(db)
+	defer teardown(t)
+
+	_, err := mockedRepo.SyncUser(models.User{Id: "auth0|1", Nickname: "joseph", Role: "technician", Team: "hvac"})
+	assert.Nil(t, err)
+	_, err = mockedRepo.SyncUser(models.User{Id: "auth0|2", Nickname: "josephine", Role: "technician", Team: "electrical"})
+	assert.Nil(t, err)
+	_, err = mockedRepo.SyncUser(models.User{Id: "auth0|3", Nickname: "robert", Role: "manager", Team: "hvac"})
+	assert.Nil(t, err)
+
+	query := NewListQuery()
+	query.AddPageAndPageSize("", "")
+	query.AddListUserFilters(map[string][]string{"q": {"jos"}})
+
+	users, err := mockedRepo.ListUsers(query)
+	assert.Nil(t, err)
+	assert.Equal(t, len(users), 2)
+	assert.Equal(t, users[0].Nickname, "joseph")
+
+	query = NewListQuery()
+	query.AddPageAndPageSize("", "")
+	query.AddListUserFilters(map[string][]string{"team": {"hvac"}, "role": {"manager"}})
+
+	users, err = mockedRepo.ListUsers(query)
+	assert.Nil(t, err)
+	assert.Equal(t, len(users), 1)
+	assert.Equal(t, users[0].Nickname, "robert")
+}
+
+func TestUpdateUserPreferences(t *testing.T) {
+
+	mockedRepo := NewUsersRepository(db)
+	defer teardown(t)
+
+	user, err := mockedRepo.SyncUser(models.User{Id: "auth0|1", Nickname: "joseph", Role: "technician"})
+	assert.Nil(t, err)
+	assert.True(t, user.NotificationsEnabled)
+
+	user.NotificationsEnabled = false
+	user.DefaultTimeZone = "Europe/Lisbon"
+	user.Nickname = "ignored"
+
+	updatedUser, err := mockedRepo.UpdateUserPreferences(user)
+	assert.Nil(t, err)
+	assert.False(t, updatedUser.NotificationsEnabled)
+	assert.Equal(t, updatedUser.DefaultTimeZone, "Europe/Lisbon")
+	assert.Equal(t, updatedUser.Nickname, "joseph")
+}
+
+func TestCalendarToken(t *testing.T) {
+
+	mockedRepo := NewUsersRepository(db)
+	defer teardown(t)
+
+	_, err := mockedRepo.SyncUser(models.User{Id: "auth0|1", Nickname: "joseph", Role: "technician"})
+	assert.Nil(t, err)
+
+	_, err = mockedRepo.GetUserByCalendarToken("")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	err = mockedRepo.SetCalendarToken("auth0|1", "hash")
+	assert.Nil(t, err)
+
+	user, err := mockedRepo.GetUserByCalendarToken("hash")
+	assert.Nil(t, err)
+	assert.Equal(t, user.Nickname, "joseph")
+
+	// an empty hash revokes the feed
+	err = mockedRepo.SetCalendarToken("auth0|1", "")
+	assert.Nil(t, err)
+
+	_, err = mockedRepo.GetUserByCalendarToken("hash")
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
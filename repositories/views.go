@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"net/url"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type ViewsRepository interface {
+	GetViewById(id uuid.UUID) (models.View, error)
+	ListViews(ownerId string, team string) ([]models.View, error)
+	CreateView(v models.View) (models.View, error)
+	UpdateView(v models.View) (models.View, error)
+	DeleteView(id uuid.UUID) error
+}
+
+type ViewRepository struct {
+	db *gorm.DB
+}
+
+func NewViewsRepository(db *gorm.DB) *ViewRepository {
+	return &ViewRepository{
+		db: db,
+	}
+}
+
+func (r ViewRepository) GetViewById(id uuid.UUID) (models.View, error) {
+	var view models.View
+
+	if err := r.db.Where("id = ?", id).First(&view).Error; err != nil {
+		return models.View{}, err
+	}
+
+	return view, nil
+}
+
+// ListViews lists the views of the owner and the views shared with the
+// team, by name.
+func (r ViewRepository) ListViews(ownerId string, team string) ([]models.View, error) {
+
+	views := make([]models.View, 0)
+
+	q := r.db.Where("owner_id = ?", ownerId)
+	if team != "" {
+		q = q.Or("shared = ? AND team = ?", true, team)
+	}
+
+	if err := q.Order("name asc").Order("id asc").Find(&views).Error; err != nil {
+		return nil, err
+	}
+
+	return views, nil
+}
+
+func (r ViewRepository) CreateView(v models.View) (models.View, error) {
+
+	if err := r.db.Create(&v).Error; err != nil {
+		return models.View{}, err
+	}
+
+	return v, nil
+}
+
+func (r ViewRepository) UpdateView(v models.View) (models.View, error) {
+
+	err := r.db.Model(&models.View{Id: v.Id}).Updates(map[string]interface{}{
+		"name":       v.Name,
+		"query":      v.Query,
+		"shared":     v.Shared,
+		"updated_at": v.UpdatedAt,
+	}).Error
+	if err != nil {
+		return models.View{}, err
+	}
+
+	return r.GetViewById(v.Id)
+}
+
+func (r ViewRepository) DeleteView(id uuid.UUID) error {
+	return r.db.Where("id = ?", id).Delete(&models.View{}).Error
+}
+
+// PurgeViewSearches removes the q search from the views saved when views
+// kept it, so its words don't stay in plain text. It returns how many views
+// were changed.
+func (r ViewRepository) PurgeViewSearches() (int, error) {
+
+	var views []models.View
+	err := r.db.Where("query LIKE ? OR query LIKE ?", "q=%", "%&q=%").Find(&views).Error
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, v := range views {
+		params, _ := url.ParseQuery(v.Query)
+		if _, ok := params["q"]; !ok {
+			continue
+		}
+		params.Del("q")
+
+		err := r.db.Model(&models.View{Id: v.Id}).Update("query", params.Encode()).Error
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestListViewsHasOwnAndSharedViews(t *testing.T) {
+
+	mockedRepo := NewViewsRepository(db)
+	defer teardown(t)
+
+	own := models.ViewRequest{Name: "mine", Query: map[string]string{"after": "2024-03-01T00:00:00Z"}}
+	shared := models.ViewRequest{Name: "hvac week", Query: map[string]string{"sort": "worker_name"}, Shared: true}
+	private := models.ViewRequest{Name: "private", Query: map[string]string{"worker_name": "ana"}}
+	otherTeam := models.ViewRequest{Name: "other team", Query: map[string]string{"worker_name": "ana"}, Shared: true}
+
+	for _, v := range []models.View{
+		own.ToView("auth0|1", "hvac"),
+		shared.ToView("auth0|2", "hvac"),
+		private.ToView("auth0|2", "hvac"),
+		otherTeam.ToView("auth0|3", "plumbing"),
+	} {
+		_, err := mockedRepo.CreateView(v)
+		assert.Nil(t, err)
+	}
+
+	views, err := mockedRepo.ListViews("auth0|1", "hvac")
+	assert.Nil(t, err)
+	if assert.Len(t, views, 2) {
+		assert.Equal(t, "hvac week", views[0].Name)
+		assert.Equal(t, "mine", views[1].Name)
+	}
+}
+
+func TestUpdateAndDeleteView(t *testing.T) {
+
+	mockedRepo := NewViewsRepository(db)
+	defer teardown(t)
+
+	request := models.ViewRequest{Name: "mine", Query: map[string]string{"after": "2024-03-01T00:00:00Z"}}
+	view, err := mockedRepo.CreateView(request.ToView("auth0|1", "hvac"))
+	assert.Nil(t, err)
+
+	request = models.ViewRequest{Name: "renamed", Query: map[string]string{"sort": "-date"}, Shared: true}
+	updated, err := mockedRepo.UpdateView(request.ApplyTo(view))
+	assert.Nil(t, err)
+	assert.Equal(t, "renamed", updated.Name)
+	assert.Equal(t, "sort=-date", updated.Query)
+	assert.True(t, updated.Shared)
+	assert.Equal(t, "auth0|1", updated.OwnerId)
+
+	err = mockedRepo.DeleteView(view.Id)
+	assert.Nil(t, err)
+
+	_, err = mockedRepo.GetViewById(view.Id)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestPurgeViewSearches(t *testing.T) {
+
+	mockedRepo := NewViewsRepository(db)
+	defer teardown(t)
+
+	// views saved before searches were left out
+	oldRequest := models.ViewRequest{Name: "pumps", Query: map[string]string{"worker_name": "ana"}}
+	old := oldRequest.ToView("auth0|1", "hvac")
+	old.Query = "q=pump+valve&worker_name=ana"
+	_, err := mockedRepo.CreateView(old)
+	assert.Nil(t, err)
+
+	keptRequest := models.ViewRequest{Name: "kept", Query: map[string]string{"worker_name": "q=x"}}
+	kept := keptRequest.ToView("auth0|1", "hvac")
+	_, err = mockedRepo.CreateView(kept)
+	assert.Nil(t, err)
+
+	purged, err := mockedRepo.PurgeViewSearches()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, purged)
+
+	fetched, err := mockedRepo.GetViewById(old.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, "worker_name=ana", fetched.Query)
+}
@@ -0,0 +1,56 @@
+// Package search maintains the blind index that makes the encrypted task
+// summaries searchable by keyword.
+package search
+
+import (
+	"log"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/markdown"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/gofrs/uuid"
+)
+
+// BACKFILL_BATCH_SIZE is the number of tasks indexed at a time by Backfill.
+const BACKFILL_BATCH_SIZE = 500
+
+// Tokens returns the search tokens of a plain summary. The markdown is
+// removed first so only the words are indexed.
+func Tokens(index encryption.BlindIndex, summary string) []string {
+	return index.Tokens(markdown.ToText(summary))
+}
+
+// Backfill indexes the tasks that have no search tokens yet, such as the tasks
+// created before the index existed, tasks indexed already are left alone.
+// Tasks updated while they are indexed keep the tokens of the update. It
+// returns the number of tasks indexed.
+func Backfill(repo repositories.SearchIndexRepository, ce encryption.CryptoEngine, index encryption.BlindIndex, batchSize int) (int, error) {
+
+	indexed := 0
+	after := uuid.Nil
+
+	for {
+		tasks, err := repo.ListUnindexedTasks(after, batchSize)
+		if err != nil {
+			return indexed, err
+		}
+
+		for _, task := range tasks {
+			err := repo.IndexTask(task, Tokens(index, ce.Decrypt(task.Summary)))
+			if err == repositories.ErrVersionConflict {
+				continue
+			}
+			if err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+
+		if len(tasks) < batchSize {
+			return indexed, nil
+		}
+
+		after = tasks[len(tasks)-1].Id
+		log.Printf("search backfill indexed %d tasks", indexed)
+	}
+}
@@ -0,0 +1,93 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/MrBolas/SupervisorAPI/encryption"
+	"github.com/MrBolas/SupervisorAPI/models"
+	"github.com/MrBolas/SupervisorAPI/repositories"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSearchIndexRepo struct {
+	tasks   []models.Task
+	indexed map[uuid.UUID][]string
+	updated map[uuid.UUID]bool
+}
+
+func (r *fakeSearchIndexRepo) ListUnindexedTasks(after uuid.UUID, limit int) ([]models.Task, error) {
+
+	tasks := make([]models.Task, 0)
+	for _, task := range r.tasks {
+		if _, ok := r.indexed[task.Id]; ok || task.Id.String() <= after.String() {
+			continue
+		}
+		if len(tasks) == limit {
+			break
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+func (r *fakeSearchIndexRepo) IndexTask(task models.Task, tokens []string) error {
+	if r.updated[task.Id] {
+		return repositories.ErrVersionConflict
+	}
+	r.indexed[task.Id] = tokens
+	return nil
+}
+
+func TestBackfillIndexesAllTasks(t *testing.T) {
+
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	index := encryption.NewBlindIndex("J8s0pQ2mXv7LcN4rT1wZ6yB3hF9kD5gA")
+
+	repo := fakeSearchIndexRepo{indexed: make(map[uuid.UUID][]string)}
+	for i := 0; i < 5; i++ {
+		repo.tasks = append(repo.tasks, models.Task{
+			Id:      uuid.Must(uuid.FromString("a2d45497-09b4-4da1-a0d0-173d0bd12f1" + string(rune('0'+i)))),
+			Summary: ce.Encrypt("Replaced the **compressor**"),
+		})
+	}
+
+	indexed, err := Backfill(&repo, ce, index, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, indexed)
+	assert.Len(t, repo.indexed, 5)
+	assert.Contains(t, repo.indexed[repo.tasks[4].Id], index.Token("compressor"))
+
+	indexed, err = Backfill(&repo, ce, index, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, indexed)
+}
+
+func TestBackfillSkipsTasksUpdatedMeanwhile(t *testing.T) {
+
+	ce := encryption.NewCryptoEngine("Qp7LtWv8X4xEHk8OLidUOCUHURPaBmPk")
+	index := encryption.NewBlindIndex("J8s0pQ2mXv7LcN4rT1wZ6yB3hF9kD5gA")
+
+	updated := uuid.Must(uuid.FromString("a2d45497-09b4-4da1-a0d0-173d0bd12f10"))
+	repo := fakeSearchIndexRepo{
+		tasks: []models.Task{
+			{Id: updated, Summary: ce.Encrypt("Replaced the compressor")},
+			{Id: uuid.Must(uuid.FromString("a2d45497-09b4-4da1-a0d0-173d0bd12f11")), Summary: ce.Encrypt("Cleaned the filters")},
+		},
+		indexed: make(map[uuid.UUID][]string),
+		updated: map[uuid.UUID]bool{updated: true},
+	}
+
+	indexed, err := Backfill(&repo, ce, index, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, indexed)
+	assert.NotContains(t, repo.indexed, updated)
+}
+
+func TestTokensIgnoreMarkdown(t *testing.T) {
+
+	index := encryption.NewBlindIndex("J8s0pQ2mXv7LcN4rT1wZ6yB3hF9kD5gA")
+
+	assert.Equal(t, index.Tokens("compressor replaced"), Tokens(index, "**compressor** _replaced_"))
+}